@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aisalamdag23/etherstats/internal/domain"
+	"github.com/aisalamdag23/etherstats/internal/infrastructure/config"
+	"github.com/aisalamdag23/etherstats/internal/infrastructure/logger"
+	"github.com/aisalamdag23/etherstats/internal/infrastructure/registry"
+)
+
+var (
+	// CommitHash will be set at compile time with current git commit
+	CommitHash string
+	// Tag will be set at compile time with current branch or tag
+	Tag string
+)
+
+const usage = `usage: etherstats-admin <command> [flags]
+
+commands:
+  create  -name NAME [-rate N] [-burst N] [-quota N] [-chains a,b] [-admin]
+  list
+  get     -id ID
+  update  -id ID [-name NAME] [-rate N] [-burst N] [-quota N] [-chains a,b] [-admin=true|false]
+  rotate  -id ID
+  revoke  -id ID
+  usage   -id ID [-from YYYY-MM-DD] [-to YYYY-MM-DD]
+  sync-usage
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	if err := run(CommitHash, Tag, os.Args[1], os.Args[2:]); err != nil {
+		log.Fatalln(err)
+	}
+}
+
+func run(commitHash string, tag string, command string, args []string) error {
+	ctx := context.Background()
+
+	cfg, err := config.Load(commitHash, tag)
+	if err != nil {
+		return fmt.Errorf("unable to load configurations: '%v'", err)
+	}
+
+	lgr := logger.NewLogger(cfg.General.LogLevel)
+
+	svc := registry.Init(ctx, cfg, lgr).CreateAdminService()
+
+	var result interface{}
+	switch command {
+	case "create":
+		params, _, err := parseParams(command, args, false)
+		if err != nil {
+			return err
+		}
+		result, err = svc.CreateKey(ctx, params)
+		if err != nil {
+			return err
+		}
+	case "list":
+		result, err = svc.ListKeys(ctx)
+		if err != nil {
+			return err
+		}
+	case "get", "rotate", "revoke":
+		id, err := parseID(command, args)
+		if err != nil {
+			return err
+		}
+		switch command {
+		case "get":
+			result, err = svc.GetKey(ctx, id)
+		case "rotate":
+			result, err = svc.RotateKey(ctx, id)
+		default:
+			result, err = svc.RevokeKey(ctx, id)
+		}
+		if err != nil {
+			return err
+		}
+	case "update":
+		params, id, err := parseParams(command, args, true)
+		if err != nil {
+			return err
+		}
+		result, err = svc.UpdateKey(ctx, id, params)
+		if err != nil {
+			return err
+		}
+	case "usage":
+		query, err := parseUsageQuery(args)
+		if err != nil {
+			return err
+		}
+		result, err = svc.GetUsage(ctx, query)
+		if err != nil {
+			return err
+		}
+	case "sync-usage":
+		if err := svc.AggregateUsage(ctx); err != nil {
+			return err
+		}
+		result = map[string]string{"status": "ok"}
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		return fmt.Errorf("unknown command %q", command)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(result)
+}
+
+// parseParams parses the key settings of create and update. Only the flags set are returned,
+// so update leaves the other settings unchanged. The key ID is required with withID.
+func parseParams(command string, args []string, withID bool) (domain.APIKeyParams, int64, error) {
+	var params domain.APIKeyParams
+
+	fs := flag.NewFlagSet(command, flag.ContinueOnError)
+	id := fs.Int64("id", 0, "key ID")
+	name := fs.String("name", "", "key name")
+	rate := fs.Int("rate", 0, "requests per second")
+	burst := fs.Int("burst", 0, "burst size")
+	quota := fs.Int("quota", 0, "requests per UTC day, 0 is unlimited")
+	chains := fs.String("chains", "", "comma separated chains the key may query, empty is every chain")
+	admin := fs.Bool("admin", false, "grant access to the admin routes")
+	if err := fs.Parse(args); err != nil {
+		return params, 0, err
+	}
+	if withID && *id <= 0 {
+		return params, 0, fmt.Errorf("%s: -id is required", command)
+	}
+
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "name":
+			params.Name = name
+		case "rate":
+			params.RatePerSec = rate
+		case "burst":
+			params.Burst = burst
+		case "quota":
+			params.DailyQuota = quota
+		case "chains":
+			list := []string{}
+			for _, chain := range strings.Split(*chains, ",") {
+				if chain = strings.TrimSpace(chain); chain != "" {
+					list = append(list, chain)
+				}
+			}
+			params.AllowedChains = &list
+		case "admin":
+			params.IsAdmin = admin
+		}
+	})
+
+	return params, *id, nil
+}
+
+// parseID parses the required key ID of a command.
+func parseID(command string, args []string) (int64, error) {
+	fs := flag.NewFlagSet(command, flag.ContinueOnError)
+	id := fs.Int64("id", 0, "key ID")
+	if err := fs.Parse(args); err != nil {
+		return 0, err
+	}
+	if *id <= 0 {
+		return 0, fmt.Errorf("%s: -id is required", command)
+	}
+
+	return *id, nil
+}
+
+// parseUsageQuery parses the key ID and the UTC days of the usage command.
+func parseUsageQuery(args []string) (domain.UsageQuery, error) {
+	var query domain.UsageQuery
+
+	fs := flag.NewFlagSet("usage", flag.ContinueOnError)
+	id := fs.String("id", "", "key ID")
+	from := fs.String("from", "", "first day, YYYY-MM-DD")
+	to := fs.String("to", "", "last day, YYYY-MM-DD")
+	if err := fs.Parse(args); err != nil {
+		return query, err
+	}
+
+	keyID, err := strconv.ParseInt(*id, 10, 64)
+	if err != nil || keyID <= 0 {
+		return query, fmt.Errorf("usage: -id is required")
+	}
+	query.APIKeyID = keyID
+
+	for _, bound := range []struct {
+		value string
+		day   *time.Time
+	}{{*from, &query.From}, {*to, &query.To}} {
+		if bound.value == "" {
+			continue
+		}
+		day, err := time.Parse("2006-01-02", bound.value)
+		if err != nil {
+			return query, fmt.Errorf("usage: invalid day %q", bound.value)
+		}
+		*bound.day = day
+	}
+
+	return query, nil
+}
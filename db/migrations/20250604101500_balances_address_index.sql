@@ -0,0 +1,7 @@
+-- migrate:up
+
+CREATE INDEX IF NOT EXISTS balances_address_id_idx ON balances (LOWER(address), id);
+
+-- migrate:down
+
+DROP INDEX IF EXISTS balances_address_id_idx;
@@ -0,0 +1,12 @@
+-- migrate:up
+
+-- balances used to be stored as ETH strings formatted with %.18f
+ALTER TABLE balances
+    ALTER COLUMN balance TYPE NUMERIC(78,0)
+    USING ROUND(balance::NUMERIC * 1000000000000000000);
+
+-- migrate:down
+
+ALTER TABLE balances
+    ALTER COLUMN balance TYPE VARCHAR(255)
+    USING (balance / 1000000000000000000)::NUMERIC(60,18)::TEXT;
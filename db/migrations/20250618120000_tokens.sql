@@ -0,0 +1,12 @@
+-- migrate:up
+
+CREATE TABLE IF NOT EXISTS tokens (
+    contract_address VARCHAR(42) PRIMARY KEY,
+    symbol VARCHAR(255) NOT NULL,
+    decimals SMALLINT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+-- migrate:down
+
+DROP TABLE IF EXISTS tokens;
@@ -0,0 +1,23 @@
+-- migrate:up
+
+-- rows saved before multi-chain support all belong to Ethereum mainnet
+ALTER TABLE balances ADD COLUMN IF NOT EXISTS chain_id BIGINT NOT NULL DEFAULT 1;
+
+DROP INDEX IF EXISTS balances_address_id_idx;
+CREATE INDEX IF NOT EXISTS balances_chain_address_id_idx ON balances (chain_id, LOWER(address), id);
+
+ALTER TABLE tokens ADD COLUMN IF NOT EXISTS chain_id BIGINT NOT NULL DEFAULT 1;
+ALTER TABLE tokens DROP CONSTRAINT tokens_pkey;
+ALTER TABLE tokens ADD CONSTRAINT tokens_pkey PRIMARY KEY (chain_id, contract_address);
+
+-- migrate:down
+
+DELETE FROM tokens WHERE chain_id <> 1;
+ALTER TABLE tokens DROP CONSTRAINT tokens_pkey;
+ALTER TABLE tokens ADD CONSTRAINT tokens_pkey PRIMARY KEY (contract_address);
+ALTER TABLE tokens DROP COLUMN IF EXISTS chain_id;
+
+DROP INDEX IF EXISTS balances_chain_address_id_idx;
+DELETE FROM balances WHERE chain_id <> 1;
+ALTER TABLE balances DROP COLUMN IF EXISTS chain_id;
+CREATE INDEX IF NOT EXISTS balances_address_id_idx ON balances (LOWER(address), id);
@@ -0,0 +1,20 @@
+-- migrate:up
+
+CREATE TABLE IF NOT EXISTS api_keys (
+    id BIGSERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    -- prefix is the start of the key, shown to tell keys apart
+    prefix VARCHAR(16) NOT NULL,
+    -- key_hash is the hex SHA-256 of the key, the key itself is never stored
+    key_hash CHAR(64) NOT NULL UNIQUE,
+    rate_per_sec INTEGER NOT NULL,
+    burst INTEGER NOT NULL,
+    -- daily_quota is the number of requests per UTC day, 0 is unlimited
+    daily_quota INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    revoked_at TIMESTAMP WITH TIME ZONE
+);
+
+-- migrate:down
+
+DROP TABLE IF EXISTS api_keys;
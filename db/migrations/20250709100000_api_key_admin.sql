@@ -0,0 +1,21 @@
+-- migrate:up
+
+-- allowed_chains are the chain names a key may query, empty is every chain
+ALTER TABLE api_keys ADD COLUMN allowed_chains TEXT[] NOT NULL DEFAULT '{}';
+ALTER TABLE api_keys ADD COLUMN is_admin BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- api_key_usage holds the requests of every key per route and UTC day, aggregated from Redis
+CREATE TABLE IF NOT EXISTS api_key_usage (
+    api_key_id BIGINT NOT NULL REFERENCES api_keys (id),
+    day DATE NOT NULL,
+    route VARCHAR(255) NOT NULL,
+    requests BIGINT NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (api_key_id, day, route)
+);
+
+-- migrate:down
+
+DROP TABLE IF EXISTS api_key_usage;
+ALTER TABLE api_keys DROP COLUMN IF EXISTS is_admin;
+ALTER TABLE api_keys DROP COLUMN IF EXISTS allowed_chains;
@@ -0,0 +1,16 @@
+-- migrate:up
+
+-- finalized transactions never change, they are read from here instead of the provider
+CREATE TABLE IF NOT EXISTS transactions (
+    chain_id BIGINT NOT NULL,
+    hash VARCHAR(66) NOT NULL,
+    block_number BIGINT NOT NULL,
+    block_hash VARCHAR(66) NOT NULL,
+    details JSONB NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (chain_id, hash)
+);
+
+-- migrate:down
+
+DROP TABLE IF EXISTS transactions;
@@ -0,0 +1,17 @@
+-- migrate:up
+
+-- finalized blocks never change, they are read from here instead of the provider
+CREATE TABLE IF NOT EXISTS blocks (
+    chain_id BIGINT NOT NULL,
+    number BIGINT NOT NULL,
+    hash VARCHAR(66) NOT NULL,
+    details JSONB NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (chain_id, number)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS blocks_chain_hash_idx ON blocks (chain_id, hash);
+
+-- migrate:down
+
+DROP TABLE IF EXISTS blocks;
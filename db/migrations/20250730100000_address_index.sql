@@ -0,0 +1,46 @@
+-- migrate:up
+
+CREATE TABLE IF NOT EXISTS watched_addresses (
+    id BIGSERIAL PRIMARY KEY,
+    chain_id BIGINT NOT NULL,
+    -- addresses are stored checksummed, so they are compared as is
+    address VARCHAR(42) NOT NULL,
+    label VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    UNIQUE (chain_id, address)
+);
+
+-- the hashes of the blocks went through, a parent hash mismatch reveals a reorg
+CREATE TABLE IF NOT EXISTS indexed_blocks (
+    chain_id BIGINT NOT NULL,
+    number BIGINT NOT NULL,
+    hash VARCHAR(66) NOT NULL,
+    parent_hash VARCHAR(66) NOT NULL,
+    indexed_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (chain_id, number)
+);
+
+CREATE TABLE IF NOT EXISTS transfers (
+    id BIGSERIAL PRIMARY KEY,
+    chain_id BIGINT NOT NULL,
+    block_number BIGINT NOT NULL,
+    block_hash VARCHAR(66) NOT NULL,
+    block_timestamp TIMESTAMP WITH TIME ZONE NOT NULL,
+    tx_hash VARCHAR(66) NOT NULL,
+    address VARCHAR(42) NOT NULL,
+    counterparty VARCHAR(42) NOT NULL DEFAULT '',
+    direction VARCHAR(4) NOT NULL,
+    value NUMERIC(78, 0) NOT NULL,
+    status VARCHAR(7) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    UNIQUE (chain_id, tx_hash, address)
+);
+
+CREATE INDEX IF NOT EXISTS transfers_chain_address_id_idx ON transfers (chain_id, address, id);
+CREATE INDEX IF NOT EXISTS transfers_chain_block_number_idx ON transfers (chain_id, block_number);
+
+-- migrate:down
+
+DROP TABLE IF EXISTS transfers;
+DROP TABLE IF EXISTS indexed_blocks;
+DROP TABLE IF EXISTS watched_addresses;
@@ -0,0 +1,11 @@
+-- migrate:up
+
+-- webhooks belong to the API key that created them, NULL when created without authentication
+ALTER TABLE webhooks ADD COLUMN IF NOT EXISTS api_key_id BIGINT REFERENCES api_keys (id);
+
+CREATE INDEX IF NOT EXISTS webhooks_api_key_id_idx ON webhooks (api_key_id, id);
+
+-- migrate:down
+
+DROP INDEX IF EXISTS webhooks_api_key_id_idx;
+ALTER TABLE webhooks DROP COLUMN IF EXISTS api_key_id;
@@ -0,0 +1,15 @@
+-- migrate:up
+
+-- the balance each watched address was last compared at by the webhook monitor, which alone writes it
+CREATE TABLE IF NOT EXISTS webhook_balances (
+    chain_id BIGINT NOT NULL,
+    address VARCHAR(42) NOT NULL,
+    balance NUMERIC(78, 0) NOT NULL,
+    block_number BIGINT NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (chain_id, address)
+);
+
+-- migrate:down
+
+DROP TABLE IF EXISTS webhook_balances;
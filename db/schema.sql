@@ -13,6 +13,57 @@ SET default_tablespace = '';
 
 SET default_table_access_method = heap;
 
+--
+-- Name: api_keys; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.api_keys (
+    id bigint NOT NULL,
+    name character varying(255) NOT NULL,
+    prefix character varying(16) NOT NULL,
+    key_hash character(64) NOT NULL,
+    rate_per_sec integer NOT NULL,
+    burst integer NOT NULL,
+    daily_quota integer DEFAULT 0 NOT NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL,
+    revoked_at timestamp with time zone,
+    allowed_chains text[] DEFAULT '{}'::text[] NOT NULL,
+    is_admin boolean DEFAULT false NOT NULL
+);
+
+
+--
+-- Name: api_keys_id_seq; Type: SEQUENCE; Schema: public; Owner: -
+--
+
+CREATE SEQUENCE public.api_keys_id_seq
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
+
+
+--
+-- Name: api_keys_id_seq; Type: SEQUENCE OWNED BY; Schema: public; Owner: -
+--
+
+ALTER SEQUENCE public.api_keys_id_seq OWNED BY public.api_keys.id;
+
+
+--
+-- Name: api_key_usage; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.api_key_usage (
+    api_key_id bigint NOT NULL,
+    day date NOT NULL,
+    route character varying(255) NOT NULL,
+    requests bigint NOT NULL,
+    updated_at timestamp with time zone DEFAULT now() NOT NULL
+);
+
+
 --
 -- Name: balances; Type: TABLE; Schema: public; Owner: -
 --
@@ -20,8 +71,9 @@ SET default_table_access_method = heap;
 CREATE TABLE public.balances (
     id integer NOT NULL,
     address character varying(255) NOT NULL,
-    balance character varying(255) NOT NULL,
-    created_at timestamp with time zone DEFAULT now() NOT NULL
+    balance numeric(78,0) NOT NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL,
+    chain_id bigint DEFAULT 1 NOT NULL
 );
 
 
@@ -45,6 +97,32 @@ CREATE SEQUENCE public.balances_id_seq
 ALTER SEQUENCE public.balances_id_seq OWNED BY public.balances.id;
 
 
+--
+-- Name: blocks; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.blocks (
+    chain_id bigint NOT NULL,
+    number bigint NOT NULL,
+    hash character varying(66) NOT NULL,
+    details jsonb NOT NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL
+);
+
+
+--
+-- Name: indexed_blocks; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.indexed_blocks (
+    chain_id bigint NOT NULL,
+    number bigint NOT NULL,
+    hash character varying(66) NOT NULL,
+    parent_hash character varying(66) NOT NULL,
+    indexed_at timestamp with time zone DEFAULT now() NOT NULL
+);
+
+
 --
 -- Name: schema_migrations; Type: TABLE; Schema: public; Owner: -
 --
@@ -54,6 +132,197 @@ CREATE TABLE public.schema_migrations (
 );
 
 
+--
+-- Name: tokens; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.tokens (
+    contract_address character varying(42) NOT NULL,
+    symbol character varying(255) NOT NULL,
+    decimals smallint NOT NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL,
+    chain_id bigint DEFAULT 1 NOT NULL
+);
+
+
+--
+-- Name: transactions; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.transactions (
+    chain_id bigint NOT NULL,
+    hash character varying(66) NOT NULL,
+    block_number bigint NOT NULL,
+    block_hash character varying(66) NOT NULL,
+    details jsonb NOT NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL
+);
+
+
+--
+-- Name: transfers; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.transfers (
+    id bigint NOT NULL,
+    chain_id bigint NOT NULL,
+    block_number bigint NOT NULL,
+    block_hash character varying(66) NOT NULL,
+    block_timestamp timestamp with time zone NOT NULL,
+    tx_hash character varying(66) NOT NULL,
+    address character varying(42) NOT NULL,
+    counterparty character varying(42) DEFAULT ''::character varying NOT NULL,
+    direction character varying(4) NOT NULL,
+    value numeric(78,0) NOT NULL,
+    status character varying(7) NOT NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL
+);
+
+
+--
+-- Name: transfers_id_seq; Type: SEQUENCE; Schema: public; Owner: -
+--
+
+CREATE SEQUENCE public.transfers_id_seq
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
+
+
+--
+-- Name: transfers_id_seq; Type: SEQUENCE OWNED BY; Schema: public; Owner: -
+--
+
+ALTER SEQUENCE public.transfers_id_seq OWNED BY public.transfers.id;
+
+
+--
+-- Name: webhook_balances; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.webhook_balances (
+    chain_id bigint NOT NULL,
+    address character varying(42) NOT NULL,
+    balance numeric(78,0) NOT NULL,
+    block_number bigint NOT NULL,
+    updated_at timestamp with time zone DEFAULT now() NOT NULL
+);
+
+
+--
+-- Name: webhook_deliveries; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.webhook_deliveries (
+    id bigint NOT NULL,
+    webhook_id bigint NOT NULL,
+    event character varying(64) NOT NULL,
+    payload jsonb NOT NULL,
+    status character varying(16) DEFAULT 'pending'::character varying NOT NULL,
+    attempts integer DEFAULT 0 NOT NULL,
+    next_attempt_at timestamp with time zone DEFAULT now() NOT NULL,
+    last_error text DEFAULT ''::text NOT NULL,
+    response_status integer,
+    created_at timestamp with time zone DEFAULT now() NOT NULL,
+    updated_at timestamp with time zone DEFAULT now() NOT NULL
+);
+
+
+--
+-- Name: webhook_deliveries_id_seq; Type: SEQUENCE; Schema: public; Owner: -
+--
+
+CREATE SEQUENCE public.webhook_deliveries_id_seq
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
+
+
+--
+-- Name: webhook_deliveries_id_seq; Type: SEQUENCE OWNED BY; Schema: public; Owner: -
+--
+
+ALTER SEQUENCE public.webhook_deliveries_id_seq OWNED BY public.webhook_deliveries.id;
+
+
+--
+-- Name: webhooks; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.webhooks (
+    id bigint NOT NULL,
+    chain_id bigint NOT NULL,
+    address character varying(42) NOT NULL,
+    url text NOT NULL,
+    secret character varying(255) NOT NULL,
+    threshold_wei numeric(78,0),
+    created_at timestamp with time zone DEFAULT now() NOT NULL,
+    api_key_id bigint
+);
+
+
+--
+-- Name: webhooks_id_seq; Type: SEQUENCE; Schema: public; Owner: -
+--
+
+CREATE SEQUENCE public.webhooks_id_seq
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
+
+
+--
+-- Name: webhooks_id_seq; Type: SEQUENCE OWNED BY; Schema: public; Owner: -
+--
+
+ALTER SEQUENCE public.webhooks_id_seq OWNED BY public.webhooks.id;
+
+
+--
+-- Name: watched_addresses; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.watched_addresses (
+    id bigint NOT NULL,
+    chain_id bigint NOT NULL,
+    address character varying(42) NOT NULL,
+    label character varying(255) DEFAULT ''::character varying NOT NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL
+);
+
+
+--
+-- Name: watched_addresses_id_seq; Type: SEQUENCE; Schema: public; Owner: -
+--
+
+CREATE SEQUENCE public.watched_addresses_id_seq
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
+
+
+--
+-- Name: watched_addresses_id_seq; Type: SEQUENCE OWNED BY; Schema: public; Owner: -
+--
+
+ALTER SEQUENCE public.watched_addresses_id_seq OWNED BY public.watched_addresses.id;
+
+
+--
+-- Name: api_keys id; Type: DEFAULT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.api_keys ALTER COLUMN id SET DEFAULT nextval('public.api_keys_id_seq'::regclass);
+
+
 --
 -- Name: balances id; Type: DEFAULT; Schema: public; Owner: -
 --
@@ -61,6 +330,58 @@ CREATE TABLE public.schema_migrations (
 ALTER TABLE ONLY public.balances ALTER COLUMN id SET DEFAULT nextval('public.balances_id_seq'::regclass);
 
 
+--
+-- Name: transfers id; Type: DEFAULT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.transfers ALTER COLUMN id SET DEFAULT nextval('public.transfers_id_seq'::regclass);
+
+
+--
+-- Name: webhook_deliveries id; Type: DEFAULT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.webhook_deliveries ALTER COLUMN id SET DEFAULT nextval('public.webhook_deliveries_id_seq'::regclass);
+
+
+--
+-- Name: webhooks id; Type: DEFAULT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.webhooks ALTER COLUMN id SET DEFAULT nextval('public.webhooks_id_seq'::regclass);
+
+
+--
+-- Name: watched_addresses id; Type: DEFAULT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.watched_addresses ALTER COLUMN id SET DEFAULT nextval('public.watched_addresses_id_seq'::regclass);
+
+
+--
+-- Name: api_key_usage api_key_usage_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.api_key_usage
+    ADD CONSTRAINT api_key_usage_pkey PRIMARY KEY (api_key_id, day, route);
+
+
+--
+-- Name: api_keys api_keys_key_hash_key; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.api_keys
+    ADD CONSTRAINT api_keys_key_hash_key UNIQUE (key_hash);
+
+
+--
+-- Name: api_keys api_keys_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.api_keys
+    ADD CONSTRAINT api_keys_pkey PRIMARY KEY (id);
+
+
 --
 -- Name: balances balances_pkey; Type: CONSTRAINT; Schema: public; Owner: -
 --
@@ -69,6 +390,22 @@ ALTER TABLE ONLY public.balances
     ADD CONSTRAINT balances_pkey PRIMARY KEY (id);
 
 
+--
+-- Name: blocks blocks_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.blocks
+    ADD CONSTRAINT blocks_pkey PRIMARY KEY (chain_id, number);
+
+
+--
+-- Name: indexed_blocks indexed_blocks_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.indexed_blocks
+    ADD CONSTRAINT indexed_blocks_pkey PRIMARY KEY (chain_id, number);
+
+
 --
 -- Name: schema_migrations schema_migrations_pkey; Type: CONSTRAINT; Schema: public; Owner: -
 --
@@ -77,6 +414,158 @@ ALTER TABLE ONLY public.schema_migrations
     ADD CONSTRAINT schema_migrations_pkey PRIMARY KEY (version);
 
 
+--
+-- Name: tokens tokens_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.tokens
+    ADD CONSTRAINT tokens_pkey PRIMARY KEY (chain_id, contract_address);
+
+
+--
+-- Name: transactions transactions_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.transactions
+    ADD CONSTRAINT transactions_pkey PRIMARY KEY (chain_id, hash);
+
+
+--
+-- Name: transfers transfers_chain_id_tx_hash_address_key; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.transfers
+    ADD CONSTRAINT transfers_chain_id_tx_hash_address_key UNIQUE (chain_id, tx_hash, address);
+
+
+--
+-- Name: transfers transfers_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.transfers
+    ADD CONSTRAINT transfers_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: webhook_balances webhook_balances_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.webhook_balances
+    ADD CONSTRAINT webhook_balances_pkey PRIMARY KEY (chain_id, address);
+
+
+--
+-- Name: webhook_deliveries webhook_deliveries_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.webhook_deliveries
+    ADD CONSTRAINT webhook_deliveries_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: webhooks webhooks_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.webhooks
+    ADD CONSTRAINT webhooks_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: watched_addresses watched_addresses_chain_id_address_key; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.watched_addresses
+    ADD CONSTRAINT watched_addresses_chain_id_address_key UNIQUE (chain_id, address);
+
+
+--
+-- Name: watched_addresses watched_addresses_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.watched_addresses
+    ADD CONSTRAINT watched_addresses_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: balances_chain_address_id_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX balances_chain_address_id_idx ON public.balances USING btree (chain_id, lower((address)::text), id);
+
+
+--
+-- Name: blocks_chain_hash_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE UNIQUE INDEX blocks_chain_hash_idx ON public.blocks USING btree (chain_id, hash);
+
+
+--
+-- Name: transfers_chain_address_id_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX transfers_chain_address_id_idx ON public.transfers USING btree (chain_id, address, id);
+
+
+--
+-- Name: transfers_chain_block_number_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX transfers_chain_block_number_idx ON public.transfers USING btree (chain_id, block_number);
+
+
+--
+-- Name: webhook_deliveries_pending_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX webhook_deliveries_pending_idx ON public.webhook_deliveries USING btree (next_attempt_at) WHERE ((status)::text = 'pending'::text);
+
+
+--
+-- Name: webhook_deliveries_webhook_id_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX webhook_deliveries_webhook_id_idx ON public.webhook_deliveries USING btree (webhook_id, id);
+
+
+--
+-- Name: webhooks_api_key_id_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX webhooks_api_key_id_idx ON public.webhooks USING btree (api_key_id, id);
+
+
+--
+-- Name: webhooks_chain_address_idx; Type: INDEX; Schema: public; Owner: -
+--
+
+CREATE INDEX webhooks_chain_address_idx ON public.webhooks USING btree (chain_id, address);
+
+
+--
+-- Name: api_key_usage api_key_usage_api_key_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.api_key_usage
+    ADD CONSTRAINT api_key_usage_api_key_id_fkey FOREIGN KEY (api_key_id) REFERENCES public.api_keys(id);
+
+
+--
+-- Name: webhook_deliveries webhook_deliveries_webhook_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.webhook_deliveries
+    ADD CONSTRAINT webhook_deliveries_webhook_id_fkey FOREIGN KEY (webhook_id) REFERENCES public.webhooks(id) ON DELETE CASCADE;
+
+
+--
+-- Name: webhooks webhooks_api_key_id_fkey; Type: FK CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.webhooks
+    ADD CONSTRAINT webhooks_api_key_id_fkey FOREIGN KEY (api_key_id) REFERENCES public.api_keys(id);
+
+
 --
 -- PostgreSQL database dump complete
 --
@@ -87,4 +576,16 @@ ALTER TABLE ONLY public.schema_migrations
 --
 
 INSERT INTO public.schema_migrations (version) VALUES
-    ('20250520165816');
+    ('20250520165816'),
+    ('20250604101500'),
+    ('20250611143000'),
+    ('20250618120000'),
+    ('20250625093000'),
+    ('20250702100000'),
+    ('20250709100000'),
+    ('20250716100000'),
+    ('20250723100000'),
+    ('20250730100000'),
+    ('20250806100000'),
+    ('20250813100000'),
+    ('20250820100000');
@@ -0,0 +1,170 @@
+package domain
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+type (
+	// APIKeyRepository stores the API keys callers authenticate with and their usage
+	APIKeyRepository interface {
+		// GetAPIKeyByHash returns the active key with the given hash, nil when there is none
+		GetAPIKeyByHash(ctx context.Context, hash string) (*APIKey, error)
+		// GetAPIKey returns the key with the given ID, revoked or not, nil when there is none
+		GetAPIKey(ctx context.Context, id int64) (*APIKey, error)
+		ListAPIKeys(ctx context.Context) ([]APIKey, error)
+		CreateAPIKey(ctx context.Context, key APIKey) (*APIKey, error)
+		// UpdateAPIKey saves the name, limits, allowed chains and admin flag of an active key
+		UpdateAPIKey(ctx context.Context, key APIKey) (*APIKey, error)
+		// RotateAPIKey replaces the prefix and hash of an active key
+		RotateAPIKey(ctx context.Context, id int64, prefix, hash string) (*APIKey, error)
+		RevokeAPIKey(ctx context.Context, id int64) (*APIKey, error)
+		// SaveUsage upserts usage counts, which are totals rather than increments
+		SaveUsage(ctx context.Context, usage []APIKeyUsage) error
+		GetUsage(ctx context.Context, query UsageQuery) ([]APIKeyUsage, error)
+		// SaveStreamToken stores the hash of a stream token of the key with the given ID for ttl
+		SaveStreamToken(ctx context.Context, hash string, keyID int64, ttl time.Duration) error
+		// TakeStreamToken removes a stream token and returns the ID of its key, 0 when it expired or was taken already
+		TakeStreamToken(ctx context.Context, hash string) (int64, error)
+	}
+
+	// UsageMeter reads the usage of every API key metered by the rate limiter
+	UsageMeter interface {
+		// Usage returns the requests of every key per route and UTC day still held by the meter
+		Usage(ctx context.Context) ([]APIKeyUsage, error)
+	}
+
+	// AdminService manages API keys
+	AdminService interface {
+		CreateKey(ctx context.Context, params APIKeyParams) (*CreatedAPIKey, error)
+		ListKeys(ctx context.Context) ([]APIKey, error)
+		GetKey(ctx context.Context, id int64) (*APIKey, error)
+		UpdateKey(ctx context.Context, id int64, params APIKeyParams) (*APIKey, error)
+		// RotateKey replaces the secret of a key, keeping its ID, limits and usage
+		RotateKey(ctx context.Context, id int64) (*CreatedAPIKey, error)
+		RevokeKey(ctx context.Context, id int64) (*APIKey, error)
+		GetUsage(ctx context.Context, query UsageQuery) ([]APIKeyUsage, error)
+		// AggregateUsage copies the usage counters of the meter into the repository
+		AggregateUsage(ctx context.Context) error
+	}
+
+	// RateLimiter enforces the rate limit and daily quota of API keys across replicas
+	RateLimiter interface {
+		// Allow takes a token from the bucket of key and counts the request against its daily quota.
+		// Allowed requests are also metered per route.
+		Allow(ctx context.Context, key APIKey, route string) (*RateLimit, error)
+	}
+
+	// StreamLimiter caps the concurrent streams of API keys and meters what they push, across replicas
+	StreamLimiter interface {
+		// Open takes one of the stream slots of key for the stream with the given ID, it fails with
+		// KindRateLimited when every slot is held. A slot is freed by Close, or once it is no longer renewed.
+		Open(ctx context.Context, key APIKey, streamID string) error
+		Renew(ctx context.Context, key APIKey, streamID string) error
+		Close(ctx context.Context, key APIKey, streamID string) error
+		// Charge counts n requests against the daily quota of key and meters them on route.
+		// It reports false, charging nothing, once the quota is exhausted.
+		Charge(ctx context.Context, key APIKey, route string, n int) (bool, error)
+	}
+
+	// AuthService authenticates callers by API key and enforces their limits
+	AuthService interface {
+		Authenticate(ctx context.Context, rawKey string) (*APIKey, error)
+		Allow(ctx context.Context, key APIKey, route string) (*RateLimit, error)
+		// IssueStreamToken creates a short-lived single-use token standing for key on a stream request,
+		// for browsers, which cannot set headers on EventSource and WebSocket requests
+		IssueStreamToken(ctx context.Context, key APIKey) (*StreamToken, error)
+		// AuthenticateStreamToken looks up the active key of a stream token, which can only be used once
+		AuthenticateStreamToken(ctx context.Context, token string) (*APIKey, error)
+		// OpenStream takes a stream slot of key, it fails with KindRateLimited when every slot is held
+		OpenStream(ctx context.Context, key APIKey, streamID string) error
+		// RenewStream keeps the slot of a stream held, CloseStream frees it
+		RenewStream(ctx context.Context, key APIKey, streamID string)
+		CloseStream(ctx context.Context, key APIKey, streamID string)
+		// ChargeStream counts n requests pushed by a stream against the daily quota of key.
+		// It reports false once the quota is exhausted.
+		ChargeStream(ctx context.Context, key APIKey, route string, n int) bool
+	}
+
+	// APIKey is a caller's key, only its hash is stored
+	APIKey struct {
+		ID   int64  `db:"id" json:"id"`
+		Name string `db:"name" json:"name"`
+		// Prefix is the start of the key, shown to tell keys apart
+		Prefix  string `db:"prefix" json:"prefix"`
+		KeyHash string `db:"key_hash" json:"-"`
+		// RatePerSec is how fast the token bucket refills, Burst is its size
+		RatePerSec int `db:"rate_per_sec" json:"ratePerSec"`
+		Burst      int `db:"burst" json:"burst"`
+		// DailyQuota is the number of requests per UTC day, 0 is unlimited
+		DailyQuota int `db:"daily_quota" json:"dailyQuota"`
+		// AllowedChains are the names of the chains the key may query, empty is every chain
+		AllowedChains []string   `db:"-" json:"allowedChains"`
+		IsAdmin       bool       `db:"is_admin" json:"isAdmin"`
+		CreatedAt     time.Time  `db:"created_at" json:"createdAt"`
+		RevokedAt     *time.Time `db:"revoked_at" json:"revokedAt,omitempty"`
+	}
+
+	// APIKeyParams are the settings of a key to create or update, nil fields are left unchanged
+	APIKeyParams struct {
+		Name          *string   `json:"name"`
+		RatePerSec    *int      `json:"ratePerSec"`
+		Burst         *int      `json:"burst"`
+		DailyQuota    *int      `json:"dailyQuota"`
+		AllowedChains *[]string `json:"allowedChains"`
+		IsAdmin       *bool     `json:"isAdmin"`
+	}
+
+	// CreatedAPIKey is a key along with its secret, which is only ever shown once
+	CreatedAPIKey struct {
+		APIKey
+		Key string `json:"key"`
+	}
+
+	// APIKeyUsage is the number of requests of a key to a route on a UTC day
+	APIKeyUsage struct {
+		APIKeyID int64     `db:"api_key_id" json:"apiKeyId"`
+		Day      time.Time `db:"day" json:"day"`
+		Route    string    `db:"route" json:"route"`
+		Requests int64     `db:"requests" json:"requests"`
+	}
+
+	// UsageQuery selects the usage of a key between two UTC days, both included
+	UsageQuery struct {
+		APIKeyID int64
+		From     time.Time
+		To       time.Time
+	}
+
+	// StreamToken stands for an API key on a single stream request, until it expires
+	StreamToken struct {
+		Token     string    `json:"token"`
+		ExpiresAt time.Time `json:"expiresAt"`
+	}
+
+	// RateLimit is the outcome of a rate limit check. Limit, Remaining and Reset describe
+	// whichever of the rate limit and the daily quota is closest to exhaustion.
+	RateLimit struct {
+		Allowed bool
+		// QuotaExceeded is set when the daily quota, rather than the rate, denied the request
+		QuotaExceeded bool
+		Limit         int
+		Remaining     int
+		// Reset is how long until Remaining goes up again
+		Reset time.Duration
+	}
+)
+
+// AllowsChain reports whether the key may query the chain with the given name.
+func (k APIKey) AllowsChain(name string) bool {
+	if len(k.AllowedChains) == 0 {
+		return true
+	}
+	for _, allowed := range k.AllowedChains {
+		if strings.EqualFold(allowed, name) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,104 @@
+package domain
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Named blocks accepted wherever a block can be selected
+const (
+	BlockLatest    = "latest"
+	BlockSafe      = "safe"
+	BlockFinalized = "finalized"
+	BlockPending   = "pending"
+)
+
+type (
+	// BlockHeader holds the header fields of a block
+	BlockHeader struct {
+		Number     uint64    `json:"number"`
+		Hash       string    `json:"hash"`
+		ParentHash string    `json:"parentHash"`
+		Timestamp  time.Time `json:"timestamp"`
+		Miner      string    `json:"miner"`
+		GasUsed    uint64    `json:"gasUsed"`
+		GasLimit   uint64    `json:"gasLimit"`
+		// BaseFeePerGas is set from London on, the blob gas fields from Cancun on
+		BaseFeePerGas *FeeAmount `json:"baseFeePerGas,omitempty"`
+		BlobGasUsed   *uint64    `json:"blobGasUsed,omitempty"`
+		ExcessBlobGas *uint64    `json:"excessBlobGas,omitempty"`
+	}
+
+	// Block is a block along with the summaries of its transactions
+	Block struct {
+		BlockHeader
+		Transactions []Transaction `json:"transactions"`
+	}
+
+	// BlockResponse is a block with either the hashes or the summaries of its transactions
+	BlockResponse struct {
+		BlockHeader
+		TransactionCount int `json:"transactionCount"`
+		// TransactionHashes is set unless the full transactions are requested, Transactions otherwise
+		TransactionHashes []string      `json:"transactionHashes,omitempty"`
+		Transactions      []Transaction `json:"transactions,omitempty"`
+		Confirmations     uint64        `json:"confirmations"`
+		// Finalized is set once the block is deep enough that it is not expected to be reorged
+		Finalized  bool   `json:"finalized"`
+		ServerTime string `json:"serverTime"`
+	}
+)
+
+// BlockSelector picks the block a query is pinned to.
+// Either Tag holds one of the named blocks or Number holds an explicit height.
+type BlockSelector struct {
+	Tag    string
+	Number uint64
+}
+
+// LatestBlock selects the most recent block known to the node.
+func LatestBlock() BlockSelector {
+	return BlockSelector{Tag: BlockLatest}
+}
+
+// NumberedBlock selects the block at the given height.
+func NumberedBlock(number uint64) BlockSelector {
+	return BlockSelector{Number: number}
+}
+
+// ParseBlockSelector parses a block given as a decimal number, a 0x-prefixed
+// hex number or one of the named blocks. An empty value selects the latest block.
+func ParseBlockSelector(val string) (BlockSelector, error) {
+	v := strings.ToLower(strings.TrimSpace(val))
+	switch v {
+	case "":
+		return LatestBlock(), nil
+	case BlockLatest, BlockSafe, BlockFinalized, BlockPending:
+		return BlockSelector{Tag: v}, nil
+	}
+
+	var (
+		number uint64
+		err    error
+	)
+	if strings.HasPrefix(v, "0x") {
+		number, err = strconv.ParseUint(v[2:], 16, 64)
+	} else {
+		number, err = strconv.ParseUint(v, 10, 64)
+	}
+	if err != nil {
+		return BlockSelector{}, InvalidInputError("block must be a number, a hex number or one of %s, %s, %s, %s",
+			BlockLatest, BlockSafe, BlockFinalized, BlockPending)
+	}
+
+	return NumberedBlock(number), nil
+}
+
+// String returns the JSON-RPC representation of the block.
+func (b BlockSelector) String() string {
+	if b.Tag != "" {
+		return b.Tag
+	}
+	return "0x" + strconv.FormatUint(b.Number, 16)
+}
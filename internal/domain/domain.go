@@ -2,12 +2,48 @@ package domain
 
 import (
 	"context"
+	"math/big"
 	"time"
 )
 
+const (
+	// HealthOK is the status of a healthy dependency or report
+	HealthOK = "ok"
+	// HealthFail is the status of an unhealthy dependency or report
+	HealthFail = "fail"
+)
+
+const (
+	// OrderAsc sorts history entries from the oldest to the newest
+	OrderAsc = "asc"
+	// OrderDesc sorts history entries from the newest to the oldest
+	OrderDesc = "desc"
+)
+
 type (
 	Service interface {
-		Get(ctx context.Context, address string) (*Response, error)
+		Get(ctx context.Context, address string, block BlockSelector) (*Response, error)
+		GetHistory(ctx context.Context, filter HistoryFilter) (*HistoryResponse, error)
+		GetBalances(ctx context.Context, addresses []string, block BlockSelector) (*BatchBalanceResponse, error)
+		GetFees(ctx context.Context) (*Fees, error)
+		GetTokenBalances(ctx context.Context, address string, contracts []string) (*TokenBalancesResponse, error)
+		GetTransaction(ctx context.Context, hash string) (*TransactionResponse, error)
+		// GetBlock retrieves a block by number, hash or "latest", with the full transactions or their hashes only
+		GetBlock(ctx context.Context, id string, fullTransactions bool) (*BlockResponse, error)
+		ListWatched(ctx context.Context) ([]WatchedAddress, error)
+		GetWatched(ctx context.Context, address string) (*WatchedAddress, error)
+		WatchAddress(ctx context.Context, address, label string) (*WatchedAddress, error)
+		UpdateWatched(ctx context.Context, address, label string) (*WatchedAddress, error)
+		UnwatchAddress(ctx context.Context, address string) error
+		// GetTransfers retrieves a page of the indexed transfers of a watched address, newest first
+		GetTransfers(ctx context.Context, filter TransferFilter) (*TransfersResponse, error)
+		// SubscribeHeads streams the new heads of the chain, see HeadFeed
+		SubscribeHeads() (heads <-chan HeadEvent, unsubscribe func(), err error)
+		// ResolveAddress validates an address or resolves an ENS name, it returns the checksummed
+		// address along with the name it was resolved from
+		ResolveAddress(ctx context.Context, address string) (string, string, error)
+		// GetBalanceAt reads the balance of a resolved address at a block without saving it
+		GetBalanceAt(ctx context.Context, address string, block uint64) (*BlockBalance, error)
 	}
 
 	Repository interface {
@@ -15,31 +51,300 @@ type (
 		SetBlockNumber(ctx context.Context, blockNumber uint64) error
 		GetGasPrice(ctx context.Context) (string, error)
 		GetBlockNumber(ctx context.Context) (uint64, error)
+		SetFees(ctx context.Context, fees Fees) error
+		GetFees(ctx context.Context) (*Fees, error)
 		SaveBalance(ctx context.Context, address, balance string) (*AddressBalance, error)
+		SaveBalances(ctx context.Context, balances []AddressBalance) error
+		GetBalanceHistory(ctx context.Context, query BalanceHistoryQuery) ([]AddressBalance, error)
+		GetToken(ctx context.Context, contract string) (*Token, error)
+		SaveToken(ctx context.Context, token Token) error
+		// GetLastGasPrice, GetLastBlockNumber and GetLastBalance return the last known good
+		// values, which outlive the cache. They return nil when there is none.
+		GetLastGasPrice(ctx context.Context) (*LastKnown[string], error)
+		GetLastBlockNumber(ctx context.Context) (*LastKnown[uint64], error)
+		GetLastBalance(ctx context.Context, address string) (*AddressBalance, error)
+		// GetTransaction returns nil when the transaction is not saved. Only finalized transactions are.
+		GetTransaction(ctx context.Context, hash string) (*TransactionDetails, error)
+		SaveTransaction(ctx context.Context, tx TransactionDetails) error
+		// GetBlock looks a block up by hash when set, by number otherwise, in Redis then in the database.
+		// It returns nil when the block is in neither.
+		GetBlock(ctx context.Context, ref BlockRef) (*Block, error)
+		// SaveBlock saves a finalized block to the database for good
+		SaveBlock(ctx context.Context, block Block) error
+		// CacheBlock caches a recent block in Redis, by number and by hash
+		CacheBlock(ctx context.Context, block Block) error
 	}
 
 	AlchemyAPIService interface {
 		GetGasPrice(ctx context.Context) (string, error)
 		GetLatestBlockNumber(ctx context.Context) (uint64, error)
-		GetBalance(ctx context.Context, address string) (string, error)
+		GetBalance(ctx context.Context, address string, block BlockSelector) (*BlockBalance, error)
+		GetBalances(ctx context.Context, addresses []string, block BlockSelector) (*BlockBalances, error)
+		GetGasTipCap(ctx context.Context) (*big.Int, error)
+		// GetGasPriceWei fetches the suggested gas price in wei, GetGasPrice formats it in ETH
+		GetGasPriceWei(ctx context.Context) (*big.Int, error)
+		GetFeeHistory(ctx context.Context, blockCount uint64, percentiles []float64) (*FeeHistory, error)
+		GetTokenMetadata(ctx context.Context, contract string) (*Token, error)
+		GetTokenBalance(ctx context.Context, contract, holder string) (*big.Int, error)
+		// SubscribeNewHeads pushes the number of every new head to heads.
+		// It fails when no endpoint supports subscriptions (e.g. HTTP only).
+		SubscribeNewHeads(ctx context.Context, heads chan<- uint64) (Subscription, error)
+		ENSResolver
+		GetChainID(ctx context.Context) (uint64, error)
+		// GetHead fetches the header of a block
+		GetHead(ctx context.Context, block BlockSelector) (*Head, error)
+		// GetTransaction fetches a transaction along with its receipt, if it is included
+		GetTransaction(ctx context.Context, hash string) (*TransactionDetails, error)
+		// GetBlock fetches a block with its full transactions, by hash when set, by number otherwise
+		GetBlock(ctx context.Context, ref BlockRef) (*Block, error)
+	}
+
+	// ENSResolver resolves ENS names, which live on mainnet whatever the chain being served
+	ENSResolver interface {
+		// ResolveENS resolves a normalized ENS name to a checksummed address
+		ResolveENS(ctx context.Context, name string) (string, error)
+	}
+
+	// HealthService checks the dependencies a replica needs to serve traffic
+	HealthService interface {
+		Ready(ctx context.Context) HealthReport
+	}
+
+	// Subscription is a stream of events that ends with an error on Err or on Unsubscribe
+	Subscription interface {
+		Unsubscribe()
+		Err() <-chan error
+	}
+
+	// Coalescer deduplicates concurrent loads of the same key: while a load is in flight,
+	// other callers of the same key wait for its result instead of loading it again
+	Coalescer interface {
+		Do(ctx context.Context, key string, load func(ctx context.Context) ([]byte, error)) ([]byte, error)
+	}
+
+	// Worker is a background process running until ctx is done
+	Worker interface {
+		Run(ctx context.Context) error
 	}
 
 	Response struct {
-		GasPrice    string  `json:"ethGasPrice"`
-		BlockNumber uint64  `json:"latestBlockNumber"`
-		Balance     Balance `json:"balance"`
-		ServerTime  string  `json:"serverTime"`
+		// GasPrice and BlockNumber are always present, null when they are unknown
+		GasPrice    *string  `json:"ethGasPrice"`
+		BlockNumber *uint64  `json:"latestBlockNumber"`
+		Balance     *Balance `json:"balance,omitempty"`
+		// Block is the block the balance was read at, it is unknown for a stale balance
+		Block *BlockRef `json:"block,omitempty"`
+		// Status reports whether each field is fresh, stale or missing
+		Status     ResponseStatus `json:"status"`
+		ServerTime string         `json:"serverTime"`
+	}
+
+	// ResponseStatus is the status of every field of a Response
+	ResponseStatus struct {
+		GasPrice    FieldStatus `json:"ethGasPrice"`
+		BlockNumber FieldStatus `json:"latestBlockNumber"`
+		Balance     FieldStatus `json:"balance"`
 	}
 
+	// FieldStatus tells a fresh value from a stale one served after an upstream failure.
+	// Error is set instead when there is no value at all.
+	FieldStatus struct {
+		Stale bool       `json:"stale"`
+		AsOf  *time.Time `json:"asOf,omitempty"`
+		Error string     `json:"error,omitempty"`
+	}
+
+	// LastKnown is the last known good value and the time it was fetched at
+	LastKnown[T any] struct {
+		Value T         `json:"value"`
+		AsOf  time.Time `json:"asOf"`
+	}
+
+	// BlockRef identifies a resolved block. Hash is empty for the pending block.
+	BlockRef struct {
+		Number uint64 `json:"number"`
+		Hash   string `json:"hash,omitempty"`
+	}
+
+	// BlockBalance is a balance in wei along with the block it was read at
+	BlockBalance struct {
+		Wei   *big.Int
+		Block BlockRef
+	}
+
+	// Balance holds an exact wei amount along with its exact gwei and ETH representations
 	Balance struct {
 		Address string `json:"address"`
+		// ENSName is set when the address was resolved from an ENS name
+		ENSName string `json:"ensName,omitempty"`
+		Wei     string `json:"weiBalance"`
+		Gwei    string `json:"gweiBalance"`
 		Eth     string `json:"ethBalance"`
+		// Value is the balance expressed in Unit
+		Unit  string `json:"unit"`
+		Value string `json:"value"`
+	}
+
+	// BlockBalances holds balances read in a single batch at the same block
+	BlockBalances struct {
+		Block BlockRef
+		// Balances are in the same order as the requested addresses
+		Balances []AddressWei
+	}
+
+	// AddressWei is the outcome of a single address in a batch.
+	// Either Wei or Error is set.
+	AddressWei struct {
+		Address string
+		Wei     *big.Int
+		Error   string
+	}
+
+	// BalanceResult is the outcome of a single address in a batch response.
+	// Either Balance or Error is set.
+	BalanceResult struct {
+		Address string   `json:"address"`
+		Balance *Balance `json:"balance,omitempty"`
+		Error   string   `json:"error,omitempty"`
+	}
+
+	BatchBalanceResponse struct {
+		Block      BlockRef        `json:"block"`
+		Balances   []BalanceResult `json:"balances"`
+		ServerTime string          `json:"serverTime"`
+	}
+
+	// Head is the latest block of a chain
+	Head struct {
+		Number    uint64
+		Hash      string
+		Timestamp time.Time
+		// BaseFeePerGas is nil before London
+		BaseFeePerGas *big.Int
+	}
+
+	// HealthReport is the status of every dependency, it is ok when all of them are
+	HealthReport struct {
+		Status string                 `json:"status"`
+		Checks map[string]CheckResult `json:"checks"`
+	}
+
+	// CheckResult is the outcome of a single dependency check
+	CheckResult struct {
+		Status    string `json:"status"`
+		LatencyMs int64  `json:"latencyMs"`
+		Error     string `json:"error,omitempty"`
+	}
+
+	// FeeHistory is the eth_feeHistory of the most recent blocks.
+	// BaseFees has one more entry than GasUsedRatio: the base fee of the pending block.
+	FeeHistory struct {
+		OldestBlock  uint64
+		BaseFees     []*big.Int
+		GasUsedRatio []float64
+		// Rewards holds the priority fees paid at each requested percentile per block
+		Rewards [][]*big.Int
+	}
+
+	// Fees describes the post-London fee market
+	Fees struct {
+		// BlockNumber is the newest block the estimates were computed from
+		BlockNumber uint64 `json:"blockNumber"`
+		// BaseFee is the base fee of the pending block
+		BaseFee FeeAmount `json:"baseFeePerGas"`
+		// PriorityFee is the tip suggested by the node
+		PriorityFee FeeAmount   `json:"maxPriorityFeePerGas"`
+		Slow        FeeEstimate `json:"slow"`
+		Standard    FeeEstimate `json:"standard"`
+		Fast        FeeEstimate `json:"fast"`
+		ServerTime  string      `json:"serverTime"`
+	}
+
+	FeeEstimate struct {
+		MaxPriorityFeePerGas FeeAmount `json:"maxPriorityFeePerGas"`
+		MaxFeePerGas         FeeAmount `json:"maxFeePerGas"`
+	}
+
+	// FeeAmount is a per gas fee in wei along with its exact gwei representation
+	FeeAmount struct {
+		Wei  string `json:"wei"`
+		Gwei string `json:"gwei"`
+	}
+
+	// Token is the metadata of an ERC-20 token contract
+	Token struct {
+		ChainID   uint64    `db:"chain_id" json:"-"`
+		Contract  string    `db:"contract_address" json:"contract"`
+		Symbol    string    `db:"symbol" json:"symbol"`
+		Decimals  uint8     `db:"decimals" json:"decimals"`
+		CreatedAt time.Time `db:"created_at" json:"-"`
+	}
+
+	// TokenBalance is the balance of a single token. Either Balance or Error is set.
+	TokenBalance struct {
+		Contract string `json:"contract"`
+		Symbol   string `json:"symbol,omitempty"`
+		Decimals uint8  `json:"decimals"`
+		// RawBalance is the balance in the token's smallest unit
+		RawBalance string `json:"rawBalance,omitempty"`
+		// Balance is the balance formatted with the token's decimals
+		Balance string `json:"balance,omitempty"`
+		Error   string `json:"error,omitempty"`
+	}
+
+	TokenBalancesResponse struct {
+		Address    string         `json:"address"`
+		ENSName    string         `json:"ensName,omitempty"`
+		Tokens     []TokenBalance `json:"tokens"`
+		ServerTime string         `json:"serverTime"`
 	}
 
 	AddressBalance struct {
-		ID        int       `db:"id"`
-		Address   string    `db:"address"`
+		ID      int    `db:"id"`
+		ChainID uint64 `db:"chain_id"`
+		Address string `db:"address"`
+		// Balance is the exact wei amount as a decimal string
 		Balance   string    `db:"balance"`
 		CreatedAt time.Time `db:"created_at"`
 	}
+
+	// HistoryFilter holds the caller supplied filters of a balance history request
+	HistoryFilter struct {
+		Address string
+		From    *time.Time
+		To      *time.Time
+		// Cursor is the opaque value returned as NextCursor by the previous page
+		Cursor string
+		Limit  int
+		// Order is either OrderAsc or OrderDesc
+		Order string
+	}
+
+	// BalanceHistoryQuery is the resolved HistoryFilter passed to the repository
+	BalanceHistoryQuery struct {
+		Address string
+		From    *time.Time
+		To      *time.Time
+		// AfterID skips every row up to and including this id in the requested order
+		AfterID    int
+		Limit      int
+		Descending bool
+	}
+
+	HistoryResponse struct {
+		Address    string         `json:"address"`
+		ENSName    string         `json:"ensName,omitempty"`
+		Balances   []HistoryEntry `json:"balances"`
+		NextCursor string         `json:"nextCursor,omitempty"`
+	}
+
+	HistoryEntry struct {
+		Balance
+		CreatedAt time.Time `json:"createdAt"`
+	}
 )
+
+// Stale reports whether any field of the response is stale.
+func (r *Response) Stale() bool {
+	return r.Status.GasPrice.Stale || r.Status.BlockNumber.Stale || r.Status.Balance.Stale
+}
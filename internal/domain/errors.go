@@ -0,0 +1,139 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrorKind classifies an error by who is responsible for it
+type ErrorKind int
+
+const (
+	// KindInternal is a failure on our side
+	KindInternal ErrorKind = iota
+	// KindInvalidInput is a caller supplied value that cannot be used
+	KindInvalidInput
+	// KindNotFound is a caller supplied value that refers to nothing
+	KindNotFound
+	// KindUpstreamUnavailable is a dependency (provider, database, cache) that failed
+	KindUpstreamUnavailable
+	// KindUpstreamRateLimited is a dependency that throttled us
+	KindUpstreamRateLimited
+	// KindTimeout is a dependency that did not answer in time
+	KindTimeout
+	// KindUnauthenticated is a request without a valid API key
+	KindUnauthenticated
+	// KindRateLimited is a caller that exceeded the rate limit or quota of its API key
+	KindRateLimited
+	// KindForbidden is a caller whose API key does not grant access to what it asked for
+	KindForbidden
+)
+
+var (
+	// ErrInvalidInput matches every error of kind KindInvalidInput
+	ErrInvalidInput = &Error{Kind: KindInvalidInput, Message: "invalid input"}
+	// ErrNotFound matches every error of kind KindNotFound
+	ErrNotFound = &Error{Kind: KindNotFound, Message: "not found"}
+	// ErrUpstreamUnavailable matches every error of kind KindUpstreamUnavailable
+	ErrUpstreamUnavailable = &Error{Kind: KindUpstreamUnavailable, Message: "upstream unavailable"}
+	// ErrUpstreamRateLimited matches every error of kind KindUpstreamRateLimited
+	ErrUpstreamRateLimited = &Error{Kind: KindUpstreamRateLimited, Message: "upstream rate limited"}
+	// ErrTimeout matches every error of kind KindTimeout
+	ErrTimeout = &Error{Kind: KindTimeout, Message: "timeout"}
+	// ErrUnauthenticated matches every error of kind KindUnauthenticated
+	ErrUnauthenticated = &Error{Kind: KindUnauthenticated, Message: "unauthenticated"}
+	// ErrRateLimited matches every error of kind KindRateLimited
+	ErrRateLimited = &Error{Kind: KindRateLimited, Message: "rate limited"}
+	// ErrForbidden matches every error of kind KindForbidden
+	ErrForbidden = &Error{Kind: KindForbidden, Message: "forbidden"}
+)
+
+// Error is an error of a known kind.
+// Message is safe to show to callers, the wrapped Err may not be.
+type Error struct {
+	Kind    ErrorKind
+	Message string
+	Err     error
+}
+
+// NewError creates an error of the given kind wrapping err, which may be nil.
+func NewError(kind ErrorKind, err error, format string, args ...interface{}) error {
+	return &Error{
+		Kind:    kind,
+		Message: fmt.Sprintf(format, args...),
+		Err:     err,
+	}
+}
+
+// InvalidInputError creates an error of kind KindInvalidInput.
+func InvalidInputError(format string, args ...interface{}) error {
+	return NewError(KindInvalidInput, nil, format, args...)
+}
+
+// ForbiddenError creates an error of kind KindForbidden.
+func ForbiddenError(format string, args ...interface{}) error {
+	return NewError(KindForbidden, nil, format, args...)
+}
+
+// NotFoundError creates an error of kind KindNotFound.
+func NotFoundError(format string, args ...interface{}) error {
+	return NewError(KindNotFound, nil, format, args...)
+}
+
+func (k ErrorKind) String() string {
+	switch k {
+	case KindInvalidInput:
+		return "invalid_input"
+	case KindNotFound:
+		return "not_found"
+	case KindUpstreamUnavailable:
+		return "upstream_unavailable"
+	case KindUpstreamRateLimited:
+		return "upstream_rate_limited"
+	case KindTimeout:
+		return "timeout"
+	case KindUnauthenticated:
+		return "unauthenticated"
+	case KindRateLimited:
+		return "rate_limited"
+	case KindForbidden:
+		return "forbidden"
+	default:
+		return "internal"
+	}
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is makes errors.Is match any error of the same kind as the target, e.g. errors.Is(err, ErrNotFound).
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Kind == e.Kind
+}
+
+// KindOf returns the kind of the outermost Error in err's chain, KindInternal when there is none.
+func KindOf(err error) ErrorKind {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Kind
+	}
+	return KindInternal
+}
+
+// MessageOf returns the caller safe message of the outermost Error in err's chain.
+func MessageOf(err error) string {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Message
+	}
+	return "internal error"
+}
@@ -0,0 +1,104 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// Directions of a transfer, relative to the watched address
+const (
+	DirectionIn   = "in"
+	DirectionOut  = "out"
+	DirectionSelf = "self"
+)
+
+type (
+	// IndexRepository stores the watch-list of a chain and the transfers indexed for it
+	IndexRepository interface {
+		ListWatched(ctx context.Context) ([]WatchedAddress, error)
+		// GetWatched, UpdateWatched return nil when the address is not watched
+		GetWatched(ctx context.Context, address string) (*WatchedAddress, error)
+		// AddWatched returns nil when the address is already watched
+		AddWatched(ctx context.Context, watched WatchedAddress) (*WatchedAddress, error)
+		UpdateWatched(ctx context.Context, watched WatchedAddress) (*WatchedAddress, error)
+		// RemoveWatched reports whether the address was watched
+		RemoveWatched(ctx context.Context, address string) (bool, error)
+		// GetLastIndexedBlock returns nil before the first block is indexed
+		GetLastIndexedBlock(ctx context.Context) (*IndexedBlock, error)
+		// SaveIndexedBlock saves a block along with its transfers, atomically
+		SaveIndexedBlock(ctx context.Context, block IndexedBlock, transfers []Transfer) error
+		// RollbackFrom deletes the indexed blocks from the given number on, along with their transfers
+		RollbackFrom(ctx context.Context, number uint64) error
+		GetTransfers(ctx context.Context, query TransferQuery) ([]Transfer, error)
+	}
+
+	// WatchedAddress is an address whose transactions are indexed
+	WatchedAddress struct {
+		ID        int64     `db:"id" json:"-"`
+		ChainID   uint64    `db:"chain_id" json:"-"`
+		Address   string    `db:"address" json:"address"`
+		Label     string    `db:"label" json:"label"`
+		CreatedAt time.Time `db:"created_at" json:"createdAt"`
+	}
+
+	// IndexedBlock is a block the indexer went through, its hashes detect reorgs
+	IndexedBlock struct {
+		Number     uint64 `db:"number"`
+		Hash       string `db:"hash"`
+		ParentHash string `db:"parent_hash"`
+	}
+
+	// Transfer is a transaction to or from a watched address
+	Transfer struct {
+		ID             int64     `db:"id"`
+		ChainID        uint64    `db:"chain_id"`
+		BlockNumber    uint64    `db:"block_number"`
+		BlockHash      string    `db:"block_hash"`
+		BlockTimestamp time.Time `db:"block_timestamp"`
+		TxHash         string    `db:"tx_hash"`
+		// Address is the watched address, Counterparty the other end, empty for contract creations
+		Address      string `db:"address"`
+		Counterparty string `db:"counterparty"`
+		// Direction is one of DirectionIn, DirectionOut or DirectionSelf
+		Direction string `db:"direction"`
+		// Value is the exact wei amount as a decimal string
+		Value string `db:"value"`
+		// Status is one of TxSuccess, TxFailed or TxUnknown, the value only moved on success
+		Status    string    `db:"status"`
+		CreatedAt time.Time `db:"created_at"`
+	}
+
+	// TransferFilter holds the caller supplied filters of a transfers request
+	TransferFilter struct {
+		Address string
+		// Cursor is the opaque value returned as NextCursor by the previous page
+		Cursor string
+		Limit  int
+	}
+
+	// TransferQuery is the resolved TransferFilter passed to the repository, newest transfers first
+	TransferQuery struct {
+		Address string
+		// BeforeID skips every row from this id on
+		BeforeID int64
+		Limit    int
+	}
+
+	TransfersResponse struct {
+		Address    string          `json:"address"`
+		ENSName    string          `json:"ensName,omitempty"`
+		Transfers  []TransferEntry `json:"transfers"`
+		NextCursor string          `json:"nextCursor,omitempty"`
+	}
+
+	TransferEntry struct {
+		BlockNumber    uint64      `json:"blockNumber"`
+		BlockHash      string      `json:"blockHash"`
+		BlockTimestamp time.Time   `json:"blockTimestamp"`
+		TxHash         string      `json:"txHash"`
+		Counterparty   string      `json:"counterparty,omitempty"`
+		Direction      string      `json:"direction"`
+		Value          EtherAmount `json:"value"`
+		Status         string      `json:"status"`
+	}
+)
@@ -0,0 +1,60 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// Types of the messages pushed to stream clients
+const (
+	StreamHead    = "head"
+	StreamBalance = "balance"
+	// StreamSubscribed acknowledges a change of the address subscriptions of a WebSocket client
+	StreamSubscribed = "subscribed"
+	StreamError      = "error"
+)
+
+type (
+	// HeadFeed fans the new heads of a chain out to the stream clients of every replica.
+	// It runs as a worker relaying the published heads to the subscribers of its replica.
+	HeadFeed interface {
+		Worker
+		// Publish sends a head to the subscribers of every replica
+		Publish(ctx context.Context, head HeadEvent) error
+		// Subscribe registers a subscriber of this replica until unsubscribe is called.
+		// Heads are dropped for a subscriber that falls behind, the channel is closed when the feed stops.
+		Subscribe() (heads <-chan HeadEvent, unsubscribe func())
+	}
+
+	// Elector elects a single replica to run a task
+	Elector interface {
+		// Lead runs task whenever this replica holds the lead, until ctx is done.
+		// The context of task is cancelled when the lead is lost.
+		Lead(ctx context.Context, task func(ctx context.Context) error) error
+	}
+
+	// HeadEvent is a new head along with the fees at the time
+	HeadEvent struct {
+		ChainID       uint64     `json:"chainId"`
+		Number        uint64     `json:"number"`
+		Hash          string     `json:"hash"`
+		Timestamp     time.Time  `json:"timestamp"`
+		BaseFeePerGas *FeeAmount `json:"baseFeePerGas,omitempty"`
+		// GasPrice is the gas price suggested by the provider
+		GasPrice *FeeAmount `json:"gasPrice,omitempty"`
+	}
+
+	// StreamMessage is a message pushed to a stream client, the field set depends on Type
+	StreamMessage struct {
+		Type string     `json:"type"`
+		Head *HeadEvent `json:"head,omitempty"`
+		// Block and Balance are set on StreamBalance
+		Block   *BlockRef `json:"block,omitempty"`
+		Balance *Balance  `json:"balance,omitempty"`
+		// Addresses are the subscribed addresses, set on StreamSubscribed
+		Addresses []string `json:"addresses,omitempty"`
+		// Address is set on StreamError when the error is about a single subscribed address
+		Address string `json:"address,omitempty"`
+		Error   string `json:"error,omitempty"`
+	}
+)
@@ -0,0 +1,95 @@
+package domain
+
+import "math/big"
+
+// Statuses of a transaction
+const (
+	// TxPending is a transaction known to the node but not included in a block yet
+	TxPending = "pending"
+	// TxSuccess is an included transaction that executed successfully
+	TxSuccess = "success"
+	// TxFailed is an included transaction that reverted
+	TxFailed = "failed"
+	// TxUnknown is an included transaction whose receipt predates Byzantium (EIP-658),
+	// which carries a state root instead of a status
+	TxUnknown = "unknown"
+)
+
+type (
+	// TransactionDetails is a transaction along with its receipt, which is nil while it is pending
+	TransactionDetails struct {
+		Transaction Transaction `json:"transaction"`
+		Receipt     *Receipt    `json:"receipt,omitempty"`
+	}
+
+	// Transaction is a decoded transaction. The block fields are nil while it is pending.
+	Transaction struct {
+		Hash string `json:"hash"`
+		From string `json:"from"`
+		// To is empty for contract creations
+		To    string      `json:"to,omitempty"`
+		Value EtherAmount `json:"value"`
+		Nonce uint64      `json:"nonce"`
+		// Type is the EIP-2718 type: 0 legacy, 1 access list, 2 dynamic fee, 3 blob, 4 set code
+		Type uint64 `json:"type"`
+		Gas  uint64 `json:"gas"`
+		// GasPrice is set on legacy and access list transactions,
+		// the fee caps on dynamic fee transactions and later types
+		GasPrice             *FeeAmount `json:"gasPrice,omitempty"`
+		MaxFeePerGas         *FeeAmount `json:"maxFeePerGas,omitempty"`
+		MaxPriorityFeePerGas *FeeAmount `json:"maxPriorityFeePerGas,omitempty"`
+		Input                string     `json:"input"`
+		BlockNumber          *uint64    `json:"blockNumber,omitempty"`
+		BlockHash            string     `json:"blockHash,omitempty"`
+		TransactionIndex     *uint64    `json:"transactionIndex,omitempty"`
+	}
+
+	// Receipt is the outcome of an included transaction
+	Receipt struct {
+		// Status is one of TxSuccess, TxFailed or TxUnknown
+		Status            string    `json:"status"`
+		BlockNumber       uint64    `json:"blockNumber"`
+		BlockHash         string    `json:"blockHash"`
+		GasUsed           uint64    `json:"gasUsed"`
+		CumulativeGasUsed uint64    `json:"cumulativeGasUsed"`
+		EffectiveGasPrice FeeAmount `json:"effectiveGasPrice"`
+		// Fee is the gas used times the effective gas price
+		Fee EtherAmount `json:"fee"`
+		// ContractAddress is set on contract creations
+		ContractAddress string `json:"contractAddress,omitempty"`
+		Logs            []Log  `json:"logs"`
+	}
+
+	// Log is an event emitted by a transaction
+	Log struct {
+		Address  string   `json:"address"`
+		Topics   []string `json:"topics"`
+		Data     string   `json:"data"`
+		LogIndex uint64   `json:"logIndex"`
+	}
+
+	// EtherAmount is a wei amount along with its exact ETH representation
+	EtherAmount struct {
+		Wei string `json:"wei"`
+		Eth string `json:"eth"`
+	}
+
+	TransactionResponse struct {
+		TransactionDetails
+		// Status is one of TxPending, TxSuccess, TxFailed or TxUnknown
+		Status string `json:"status"`
+		// Confirmations counts the including block and every block on top of it, 0 while pending
+		Confirmations uint64 `json:"confirmations"`
+		// Finalized is set once the transaction is deep enough that it is not expected to be reorged
+		Finalized  bool   `json:"finalized"`
+		ServerTime string `json:"serverTime"`
+	}
+)
+
+// NewEtherAmount builds an EtherAmount from a wei amount.
+func NewEtherAmount(wei *big.Int) EtherAmount {
+	return EtherAmount{
+		Wei: wei.String(),
+		Eth: FormatWei(wei, UnitEther),
+	}
+}
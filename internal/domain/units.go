@@ -0,0 +1,108 @@
+package domain
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// Units a wei amount can be expressed in
+const (
+	UnitWei   = "wei"
+	UnitGwei  = "gwei"
+	UnitEther = "ether"
+)
+
+const (
+	gweiDecimals  = 9
+	etherDecimals = 18
+)
+
+// ParseUnit validates a unit given by the caller. An empty value selects ether.
+func ParseUnit(val string) (string, error) {
+	switch unit := strings.ToLower(strings.TrimSpace(val)); unit {
+	case "":
+		return UnitEther, nil
+	case UnitWei, UnitGwei, UnitEther:
+		return unit, nil
+	}
+
+	return "", InvalidInputError("unit must be one of %s, %s, %s", UnitWei, UnitGwei, UnitEther)
+}
+
+// ParseWei parses an exact wei amount given as a decimal string.
+func ParseWei(val string) (*big.Int, error) {
+	wei, ok := new(big.Int).SetString(val, 10)
+	if !ok {
+		return nil, fmt.Errorf("malformed wei amount %q", val)
+	}
+	return wei, nil
+}
+
+// FormatUnits formats an integer amount of the smallest unit as an exact
+// decimal string with the given number of decimals, using integer arithmetic only.
+func FormatUnits(val *big.Int, decimals int) string {
+	if decimals <= 0 {
+		return val.String()
+	}
+
+	abs := new(big.Int).Abs(val)
+	base := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals)), nil)
+	whole, frac := new(big.Int).QuoRem(abs, base, new(big.Int))
+
+	fracStr := frac.String()
+	s := whole.String() + "." + strings.Repeat("0", decimals-len(fracStr)) + fracStr
+	if val.Sign() < 0 {
+		s = "-" + s
+	}
+
+	return s
+}
+
+// FormatWei formats a wei amount in the given unit.
+func FormatWei(wei *big.Int, unit string) string {
+	switch unit {
+	case UnitWei:
+		return wei.String()
+	case UnitGwei:
+		return FormatUnits(wei, gweiDecimals)
+	default:
+		return FormatUnits(wei, etherDecimals)
+	}
+}
+
+// NewBalance builds the Balance of an address from its exact wei amount.
+// Value is expressed in ether until SetUnit is called.
+func NewBalance(address string, wei *big.Int) Balance {
+	b := Balance{
+		Address: address,
+		Wei:     wei.String(),
+		Gwei:    FormatWei(wei, UnitGwei),
+		Eth:     FormatWei(wei, UnitEther),
+	}
+	b.SetUnit(UnitEther)
+
+	return b
+}
+
+// SetUnit expresses Value in the given unit.
+func (b *Balance) SetUnit(unit string) {
+	switch unit {
+	case UnitWei:
+		b.Value = b.Wei
+	case UnitGwei:
+		b.Value = b.Gwei
+	default:
+		unit = UnitEther
+		b.Value = b.Eth
+	}
+	b.Unit = unit
+}
+
+// NewFeeAmount builds a FeeAmount from a per gas fee in wei.
+func NewFeeAmount(wei *big.Int) FeeAmount {
+	return FeeAmount{
+		Wei:  wei.String(),
+		Gwei: FormatWei(wei, UnitGwei),
+	}
+}
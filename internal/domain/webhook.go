@@ -0,0 +1,126 @@
+package domain
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// Events a webhook is notified of
+const (
+	// EventBalanceChanged is sent on every balance change to webhooks without a threshold
+	EventBalanceChanged = "balance.changed"
+	// EventThresholdCrossed is sent when the balance goes from one side of the threshold to the other
+	EventThresholdCrossed = "balance.threshold_crossed"
+)
+
+// Statuses of a webhook delivery
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	// DeliveryFailed is a delivery that ran out of attempts, it is only retried when replayed
+	DeliveryFailed = "failed"
+)
+
+type (
+	// WebhookRepository stores webhooks and their deliveries
+	WebhookRepository interface {
+		CreateWebhook(ctx context.Context, webhook Webhook) (*Webhook, error)
+		// GetWebhook returns nil when the webhook does not exist
+		GetWebhook(ctx context.Context, id int64) (*Webhook, error)
+		// ListWebhooks lists the webhooks of an API key, the ones created without authentication when nil
+		ListWebhooks(ctx context.Context, apiKeyID *int64) ([]Webhook, error)
+		ListChainWebhooks(ctx context.Context, chainID uint64) ([]Webhook, error)
+		// DeleteWebhook deletes a webhook of an API key along with its deliveries, it reports whether the webhook existed
+		DeleteWebhook(ctx context.Context, apiKeyID *int64, id int64) (bool, error)
+		CreateDeliveries(ctx context.Context, deliveries []WebhookDelivery) error
+		// ListDeliveries lists the most recent deliveries of a webhook, newest first
+		ListDeliveries(ctx context.Context, webhookID int64, limit int) ([]WebhookDelivery, error)
+		// ClaimDueDeliveries leases the pending deliveries due by now, so no other replica
+		// attempts them until the lease expires or they are updated
+		ClaimDueDeliveries(ctx context.Context, limit int, lease time.Duration) ([]WebhookDelivery, error)
+		// UpdateDelivery saves the outcome of an attempt
+		UpdateDelivery(ctx context.Context, delivery WebhookDelivery) error
+		// ReplayDelivery makes a delivery pending and due again with fresh attempts.
+		// It returns nil when the webhook has no such delivery.
+		ReplayDelivery(ctx context.Context, webhookID, id int64) (*WebhookDelivery, error)
+		// GetMonitoredBalances returns the balances the monitor last compared addresses of a chain at,
+		// keyed by lowercase address. Addresses never compared are missing.
+		GetMonitoredBalances(ctx context.Context, chainID uint64, addresses []string) (map[string]string, error)
+		// SaveMonitoredBalances records the balances of addresses of a chain at a block, as the next baseline
+		SaveMonitoredBalances(ctx context.Context, chainID, block uint64, balances []AddressBalance) error
+	}
+
+	// WebhookService manages webhooks and replays their deliveries.
+	// Webhooks belong to the API key that created them, apiKeyID is nil when authentication is disabled.
+	WebhookService interface {
+		CreateWebhook(ctx context.Context, apiKeyID *int64, params WebhookParams) (*CreatedWebhook, error)
+		ListWebhooks(ctx context.Context, apiKeyID *int64) ([]Webhook, error)
+		GetWebhook(ctx context.Context, apiKeyID *int64, id int64) (*Webhook, error)
+		DeleteWebhook(ctx context.Context, apiKeyID *int64, id int64) error
+		ListDeliveries(ctx context.Context, apiKeyID *int64, webhookID int64) ([]WebhookDelivery, error)
+		ReplayDelivery(ctx context.Context, apiKeyID *int64, webhookID, id int64) (*WebhookDelivery, error)
+	}
+
+	// Webhook is a URL notified of the balance changes of an address
+	Webhook struct {
+		ID int64 `db:"id" json:"id"`
+		// APIKeyID is the API key the webhook belongs to, nil when it was created without authentication
+		APIKeyID *int64 `db:"api_key_id" json:"-"`
+		ChainID  uint64 `db:"chain_id" json:"chainId"`
+		Address  string `db:"address" json:"address"`
+		URL      string `db:"url" json:"url"`
+		// Secret signs the payloads, it is only shown once
+		Secret string `db:"secret" json:"-"`
+		// ThresholdWei restricts notifications to the balance crossing it, every change is notified when nil
+		ThresholdWei *string   `db:"threshold_wei" json:"thresholdWei,omitempty"`
+		CreatedAt    time.Time `db:"created_at" json:"createdAt"`
+	}
+
+	// WebhookParams are the settings of a webhook to create
+	WebhookParams struct {
+		// Chain is the name or ID of the chain, the default chain when empty
+		Chain        string  `json:"chain"`
+		Address      string  `json:"address"`
+		URL          string  `json:"url"`
+		ThresholdWei *string `json:"thresholdWei"`
+	}
+
+	// CreatedWebhook is a webhook along with its signing secret, which is only ever shown once
+	CreatedWebhook struct {
+		Webhook
+		Secret string `json:"secret"`
+	}
+
+	// WebhookDelivery is a payload to deliver to a webhook and the outcome of its attempts
+	WebhookDelivery struct {
+		ID        int64           `db:"id" json:"id"`
+		WebhookID int64           `db:"webhook_id" json:"webhookId"`
+		Event     string          `db:"event" json:"event"`
+		Payload   json.RawMessage `db:"payload" json:"payload"`
+		// Status is one of DeliveryPending, DeliverySucceeded or DeliveryFailed
+		Status        string    `db:"status" json:"status"`
+		Attempts      int       `db:"attempts" json:"attempts"`
+		NextAttemptAt time.Time `db:"next_attempt_at" json:"nextAttemptAt"`
+		// LastError and ResponseStatus describe the last attempt
+		LastError      string    `db:"last_error" json:"lastError,omitempty"`
+		ResponseStatus *int      `db:"response_status" json:"responseStatus,omitempty"`
+		CreatedAt      time.Time `db:"created_at" json:"createdAt"`
+		UpdatedAt      time.Time `db:"updated_at" json:"updatedAt"`
+	}
+
+	// BalancePayload is the body POSTed to a webhook on a balance event
+	BalancePayload struct {
+		Event   string   `json:"event"`
+		ChainID uint64   `json:"chainId"`
+		Address string   `json:"address"`
+		Block   BlockRef `json:"block"`
+		// PreviousBalance is the balance the monitor last saw before the block, the last saved one at first
+		PreviousBalance EtherAmount `json:"previousBalance"`
+		Balance         EtherAmount `json:"balance"`
+		ThresholdWei    string      `json:"thresholdWei,omitempty"`
+		// Crossed is "above" or "below" on EventThresholdCrossed, the side of the threshold the balance went to
+		Crossed   string    `json:"crossed,omitempty"`
+		CreatedAt time.Time `json:"createdAt"`
+	}
+)
@@ -0,0 +1,192 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/aisalamdag23/etherstats/internal/domain"
+	"github.com/aisalamdag23/etherstats/internal/handler"
+	"github.com/gorilla/mux"
+)
+
+const (
+	// maxBodyBytes caps the size of a key request body
+	maxBodyBytes = 1 << 16
+	// dayLayout is the format of the usage range bounds
+	dayLayout = "2006-01-02"
+)
+
+type server struct {
+	service domain.AdminService
+}
+
+// NewServer creates the handler managing API keys and their usage.
+func NewServer(service domain.AdminService) handler.Handler {
+	return &server{
+		service: service,
+	}
+}
+
+func (s *server) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/keys", handler.RestrictMethods(map[string]func(w http.ResponseWriter, r *http.Request){
+		http.MethodGet:  s.ListKeys,
+		http.MethodPost: s.CreateKey,
+	}))
+	router.HandleFunc("/keys/{id}", handler.RestrictMethods(map[string]func(w http.ResponseWriter, r *http.Request){
+		http.MethodGet:    s.GetKey,
+		http.MethodPatch:  s.UpdateKey,
+		http.MethodDelete: s.RevokeKey,
+	}))
+	router.HandleFunc("/keys/{id}/rotate", handler.Restrict(http.MethodPost, s.RotateKey))
+	router.HandleFunc("/keys/{id}/usage", handler.Restrict(http.MethodGet, s.GetUsage))
+}
+
+func (s *server) CreateKey(w http.ResponseWriter, r *http.Request) {
+	params, ok := decodeParams(w, r)
+	if !ok {
+		return
+	}
+
+	key, err := s.service.CreateKey(r.Context(), params)
+	if err != nil {
+		handler.WriteError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, key)
+}
+
+func (s *server) ListKeys(w http.ResponseWriter, r *http.Request) {
+	keys, err := s.service.ListKeys(r.Context())
+	if err != nil {
+		handler.WriteError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, keys)
+}
+
+func (s *server) GetKey(w http.ResponseWriter, r *http.Request) {
+	id, ok := keyID(w, r)
+	if !ok {
+		return
+	}
+
+	key, err := s.service.GetKey(r.Context(), id)
+	if err != nil {
+		handler.WriteError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, key)
+}
+
+func (s *server) UpdateKey(w http.ResponseWriter, r *http.Request) {
+	id, ok := keyID(w, r)
+	if !ok {
+		return
+	}
+	params, ok := decodeParams(w, r)
+	if !ok {
+		return
+	}
+
+	key, err := s.service.UpdateKey(r.Context(), id, params)
+	if err != nil {
+		handler.WriteError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, key)
+}
+
+func (s *server) RotateKey(w http.ResponseWriter, r *http.Request) {
+	id, ok := keyID(w, r)
+	if !ok {
+		return
+	}
+
+	key, err := s.service.RotateKey(r.Context(), id)
+	if err != nil {
+		handler.WriteError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, key)
+}
+
+func (s *server) RevokeKey(w http.ResponseWriter, r *http.Request) {
+	id, ok := keyID(w, r)
+	if !ok {
+		return
+	}
+
+	key, err := s.service.RevokeKey(r.Context(), id)
+	if err != nil {
+		handler.WriteError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, key)
+}
+
+func (s *server) GetUsage(w http.ResponseWriter, r *http.Request) {
+	id, ok := keyID(w, r)
+	if !ok {
+		return
+	}
+
+	query := domain.UsageQuery{APIKeyID: id}
+	for name, bound := range map[string]*time.Time{"from": &query.From, "to": &query.To} {
+		value := r.URL.Query().Get(name)
+		if value == "" {
+			continue
+		}
+		day, err := time.Parse(dayLayout, value)
+		if err != nil {
+			handler.WriteError(w, r, domain.InvalidInputError("%s must be a date formatted as YYYY-MM-DD", name))
+			return
+		}
+		*bound = day
+	}
+
+	usage, err := s.service.GetUsage(r.Context(), query)
+	if err != nil {
+		handler.WriteError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, usage)
+}
+
+// keyID parses the key ID of the route, writing a problem when it is not a positive integer.
+func keyID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil || id <= 0 {
+		handler.WriteError(w, r, domain.InvalidInputError("invalid api key id %q", mux.Vars(r)["id"]))
+		return 0, false
+	}
+	return id, true
+}
+
+// decodeParams decodes the key settings of the request body, writing a problem when it is malformed.
+func decodeParams(w http.ResponseWriter, r *http.Request) (domain.APIKeyParams, bool) {
+	var params domain.APIKeyParams
+
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&params); err != nil {
+		handler.WriteError(w, r, domain.InvalidInputError("malformed request body"))
+		return params, false
+	}
+	return params, true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
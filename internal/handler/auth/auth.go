@@ -0,0 +1,49 @@
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/aisalamdag23/etherstats/internal/domain"
+	"github.com/aisalamdag23/etherstats/internal/handler"
+	"github.com/aisalamdag23/etherstats/internal/infrastructure/protocol/rest/middleware"
+	"github.com/gorilla/mux"
+)
+
+type server struct {
+	service domain.AuthService
+}
+
+// NewServer creates the handler issuing stream tokens to authenticated callers.
+// Its routes go behind the Auth middleware.
+func NewServer(service domain.AuthService) handler.Handler {
+	return &server{
+		service: service,
+	}
+}
+
+func (s *server) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/stream-tokens", handler.Restrict(http.MethodPost, s.PostStreamToken))
+}
+
+// PostStreamToken issues a single-use token opening one stream on behalf of the caller's key,
+// passed in ?token= by browsers, which cannot set headers on EventSource and WebSocket requests.
+// The token expires within a minute, so it is fetched right before every connection.
+func (s *server) PostStreamToken(w http.ResponseWriter, r *http.Request) {
+	key := middleware.APIKeyFromContext(r.Context())
+	if key == nil {
+		handler.WriteError(w, r, domain.NewError(domain.KindUnauthenticated, nil, "missing api key"))
+		return
+	}
+
+	token, err := s.service.IssueStreamToken(r.Context(), *key)
+	if err != nil {
+		handler.WriteError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(token)
+}
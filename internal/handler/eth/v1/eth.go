@@ -3,6 +3,9 @@ package v1
 import (
 	"encoding/json"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/aisalamdag23/etherstats/internal/domain"
 	"github.com/aisalamdag23/etherstats/internal/handler"
@@ -10,23 +13,76 @@ import (
 )
 
 type server struct {
-	service domain.Service
+	// services are keyed by lowercase chain name and chain ID
+	services     map[string]domain.Service
+	defaultChain string
+	// authService caps and meters the streams of API keys, nil when auth is disabled
+	authService domain.AuthService
 }
 
-type apiProblem struct {
-	Title  string `json:"title"`
-	Status int    `json:"status"`
-	Detail string `json:"detail"`
+const (
+	// maxBatchBodyBytes caps the size of a batch balance request body
+	maxBatchBodyBytes = 1 << 20
+	// staleWarning is the Warning header of responses with stale values (RFC 7234)
+	staleWarning = `110 - "Response is Stale"`
+)
+
+type batchBalanceRequest struct {
+	Addresses []string `json:"addresses"`
+	Block     string   `json:"block"`
 }
 
-func NewServer(service domain.Service) handler.Handler {
+// NewServer creates the eth handler of many chains.
+// services are keyed by lowercase chain name and chain ID, routes without a chain are served by defaultChain.
+// authService caps and meters streams per API key, it is nil when auth is disabled.
+func NewServer(services map[string]domain.Service, defaultChain string, authService domain.AuthService) handler.Handler {
 	return &server{
-		service: service,
+		services:     services,
+		defaultChain: strings.ToLower(defaultChain),
+		authService:  authService,
 	}
 }
 
 func (s *server) RegisterRoutes(router *mux.Router) {
-	router.HandleFunc("/eth/{id}", handler.Restrict(http.MethodGet, s.GetEth))
+	// every route is served for the default chain and for any chain by name or ID
+	for _, prefix := range []string{"", "/{chain}"} {
+		// static routes go first so they are not captured by {id}
+		router.HandleFunc(prefix+"/eth/balances", handler.Restrict(http.MethodPost, s.PostBalances))
+		router.HandleFunc(prefix+"/eth/fees", handler.Restrict(http.MethodGet, s.GetFees))
+		router.HandleFunc(prefix+"/eth/stream", handler.Restrict(http.MethodGet, s.GetStream))
+		router.HandleFunc(prefix+"/eth/tx/{hash}", handler.Restrict(http.MethodGet, s.GetTransaction))
+		router.HandleFunc(prefix+"/eth/blocks/{block}", handler.Restrict(http.MethodGet, s.GetBlock))
+		router.HandleFunc(prefix+"/eth/watchlist", handler.RestrictMethods(map[string]func(w http.ResponseWriter, r *http.Request){
+			http.MethodGet:  s.ListWatched,
+			http.MethodPost: s.PostWatched,
+		}))
+		router.HandleFunc(prefix+"/eth/watchlist/{address}", handler.RestrictMethods(map[string]func(w http.ResponseWriter, r *http.Request){
+			http.MethodGet:    s.GetWatched,
+			http.MethodPatch:  s.PatchWatched,
+			http.MethodDelete: s.DeleteWatched,
+		}))
+		router.HandleFunc(prefix+"/eth/{id}", handler.Restrict(http.MethodGet, s.GetEth))
+		router.HandleFunc(prefix+"/eth/{id}/history", handler.Restrict(http.MethodGet, s.GetHistory))
+		router.HandleFunc(prefix+"/eth/{id}/tokens", handler.Restrict(http.MethodGet, s.GetTokens))
+		router.HandleFunc(prefix+"/eth/{id}/transfers", handler.Restrict(http.MethodGet, s.GetTransfers))
+	}
+}
+
+// chainService resolves the service of the chain in the route, writing a problem when the chain is unknown.
+// Routes without a chain are served by the default chain.
+func (s *server) chainService(w http.ResponseWriter, r *http.Request) (domain.Service, bool) {
+	chain, ok := mux.Vars(r)["chain"]
+	if !ok {
+		chain = s.defaultChain
+	}
+
+	service, ok := s.services[strings.ToLower(chain)]
+	if !ok {
+		handler.WriteError(w, r, domain.NotFoundError("unknown chain %q", chain))
+		return nil, false
+	}
+
+	return service, true
 }
 
 func (s *server) GetEth(w http.ResponseWriter, r *http.Request) {
@@ -35,16 +91,248 @@ func (s *server) GetEth(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("Content-Type", "application/json")
 
-	resp, err := s.service.Get(r.Context(), id)
+	service, ok := s.chainService(w, r)
+	if !ok {
+		return
+	}
+
+	block, err := domain.ParseBlockSelector(r.URL.Query().Get("block"))
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		_ = json.NewEncoder(w).Encode(apiProblem{
-			Title:  http.StatusText(http.StatusInternalServerError),
-			Status: http.StatusInternalServerError,
-			Detail: err.Error(),
-		})
+		handler.WriteError(w, r, err)
+		return
+	}
+	unit, err := domain.ParseUnit(r.URL.Query().Get("unit"))
+	if err != nil {
+		handler.WriteError(w, r, err)
+		return
+	}
+
+	resp, err := service.Get(r.Context(), id, block)
+	if err != nil {
+		handler.WriteError(w, r, err)
+		return
+	}
+
+	if resp.Balance != nil {
+		resp.Balance.SetUnit(unit)
+	}
+	if resp.Stale() {
+		w.Header().Set("Warning", staleWarning)
 	}
 
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(resp)
 }
+
+func (s *server) GetFees(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	service, ok := s.chainService(w, r)
+	if !ok {
+		return
+	}
+
+	resp, err := service.GetFees(r.Context())
+	if err != nil {
+		handler.WriteError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+func (s *server) PostBalances(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	service, ok := s.chainService(w, r)
+	if !ok {
+		return
+	}
+
+	var req batchBalanceRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBatchBodyBytes)).Decode(&req); err != nil {
+		handler.WriteError(w, r, domain.InvalidInputError("malformed request body"))
+		return
+	}
+
+	block, err := domain.ParseBlockSelector(req.Block)
+	if err != nil {
+		handler.WriteError(w, r, err)
+		return
+	}
+	unit, err := domain.ParseUnit(r.URL.Query().Get("unit"))
+	if err != nil {
+		handler.WriteError(w, r, err)
+		return
+	}
+
+	resp, err := service.GetBalances(r.Context(), req.Addresses, block)
+	if err != nil {
+		handler.WriteError(w, r, err)
+		return
+	}
+	for _, result := range resp.Balances {
+		if result.Balance != nil {
+			result.Balance.SetUnit(unit)
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+func (s *server) GetHistory(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	w.Header().Set("Content-Type", "application/json")
+
+	service, ok := s.chainService(w, r)
+	if !ok {
+		return
+	}
+
+	filter, err := parseHistoryFilter(r)
+	if err != nil {
+		handler.WriteError(w, r, err)
+		return
+	}
+	filter.Address = vars["id"]
+	unit, err := domain.ParseUnit(r.URL.Query().Get("unit"))
+	if err != nil {
+		handler.WriteError(w, r, err)
+		return
+	}
+
+	resp, err := service.GetHistory(r.Context(), filter)
+	if err != nil {
+		handler.WriteError(w, r, err)
+		return
+	}
+	for i := range resp.Balances {
+		resp.Balances[i].SetUnit(unit)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+func (s *server) GetTokens(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	w.Header().Set("Content-Type", "application/json")
+
+	service, ok := s.chainService(w, r)
+	if !ok {
+		return
+	}
+
+	// contracts may be repeated and/or comma separated
+	var contracts []string
+	for _, val := range r.URL.Query()["contract"] {
+		for _, contract := range strings.Split(val, ",") {
+			if contract = strings.TrimSpace(contract); contract != "" {
+				contracts = append(contracts, contract)
+			}
+		}
+	}
+
+	resp, err := service.GetTokenBalances(r.Context(), id, contracts)
+	if err != nil {
+		handler.WriteError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+func (s *server) GetTransaction(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	hash := vars["hash"]
+
+	w.Header().Set("Content-Type", "application/json")
+
+	service, ok := s.chainService(w, r)
+	if !ok {
+		return
+	}
+
+	resp, err := service.GetTransaction(r.Context(), hash)
+	if err != nil {
+		handler.WriteError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// GetBlock serves a block with the hashes of its transactions, or their summaries with ?transactions=full.
+func (s *server) GetBlock(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	block := vars["block"]
+
+	w.Header().Set("Content-Type", "application/json")
+
+	service, ok := s.chainService(w, r)
+	if !ok {
+		return
+	}
+
+	var full bool
+	switch r.URL.Query().Get("transactions") {
+	case "", "hashes":
+	case "full":
+		full = true
+	default:
+		handler.WriteError(w, r, domain.InvalidInputError("transactions must be %q or %q", "hashes", "full"))
+		return
+	}
+
+	resp, err := service.GetBlock(r.Context(), block, full)
+	if err != nil {
+		handler.WriteError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// parseHistoryFilter reads the from, to, limit, cursor and order query parameters.
+// Time bounds are expected in RFC 3339 format.
+func parseHistoryFilter(r *http.Request) (domain.HistoryFilter, error) {
+	query := r.URL.Query()
+	filter := domain.HistoryFilter{
+		Cursor: query.Get("cursor"),
+		Order:  query.Get("order"),
+	}
+
+	bounds := []struct {
+		name string
+		dst  **time.Time
+	}{{"from", &filter.From}, {"to", &filter.To}}
+	for _, b := range bounds {
+		val := query.Get(b.name)
+		if val == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, val)
+		if err != nil {
+			return filter, domain.InvalidInputError("%s must be an RFC 3339 timestamp", b.name)
+		}
+		*b.dst = &t
+	}
+
+	if val := query.Get("limit"); val != "" {
+		limit, err := strconv.Atoi(val)
+		if err != nil {
+			return filter, domain.InvalidInputError("limit must be a number")
+		}
+		filter.Limit = limit
+	}
+
+	return filter, nil
+}
@@ -0,0 +1,441 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aisalamdag23/etherstats/internal/domain"
+	"github.com/aisalamdag23/etherstats/internal/handler"
+	"github.com/aisalamdag23/etherstats/internal/infrastructure/logger"
+	"github.com/aisalamdag23/etherstats/internal/infrastructure/protocol/rest/middleware"
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	// maxStreamAddresses caps the addresses a single stream client may subscribe to
+	maxStreamAddresses = 20
+	// sseKeepalive is how often an idle event stream gets a comment, so proxies keep it open
+	sseKeepalive = 15 * time.Second
+	// wsPingInterval is how often WebSocket clients are pinged, they are dropped after wsPongWait without a pong
+	wsPingInterval = 30 * time.Second
+	wsPongWait     = 60 * time.Second
+	// wsWriteWait bounds every WebSocket write
+	wsWriteWait = 10 * time.Second
+	// maxWSMessageBytes caps the size of a message sent by a WebSocket client
+	maxWSMessageBytes = 1 << 12
+	// quotaExceeded ends the streams of keys out of quota
+	quotaExceeded = "daily quota exceeded"
+)
+
+// Commands a WebSocket client may send
+const (
+	streamSubscribe   = "subscribe"
+	streamUnsubscribe = "unsubscribe"
+)
+
+// upgrader accepts every origin: API keys travel in headers and stream tokens in the URL
+// rather than cookies, so another origin cannot ride on the credentials of a browser
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	CheckOrigin:     func(*http.Request) bool { return true },
+}
+
+type (
+	// streamCommand is a message sent by a WebSocket client
+	streamCommand struct {
+		Type      string   `json:"type"`
+		Addresses []string `json:"addresses"`
+	}
+
+	// streamSubscriptions are the addresses a stream client is subscribed to
+	streamSubscriptions struct {
+		service   domain.Service
+		addresses map[string]*streamAddress
+	}
+
+	streamAddress struct {
+		// name is the ENS name the address was resolved from
+		name string
+		// last is the last balance pushed in wei, empty until the first one
+		last string
+	}
+
+	// streamMeter holds the stream slot of an API key and charges the key for every head pushed.
+	// A nil meter, when auth is disabled, meters nothing.
+	streamMeter struct {
+		authService domain.AuthService
+		key         domain.APIKey
+		id          string
+		route       string
+	}
+)
+
+// GetStream pushes a message on every new head of the chain, along with the balance of the
+// subscribed addresses whenever it changes. Clients upgrading to WebSocket may change their
+// subscriptions with subscribe and unsubscribe messages, others get Server-Sent Events.
+// Both may subscribe to a comma separated list of addresses or ENS names with ?addresses=.
+// Browsers, which cannot set the key header on either, pass a stream token in ?token= instead.
+// API keys hold a limited number of concurrent streams, each head counts against their daily
+// quota as one request plus one per subscribed address, and the stream ends once it is exhausted.
+func (s *server) GetStream(w http.ResponseWriter, r *http.Request) {
+	service, ok := s.chainService(w, r)
+	if !ok {
+		return
+	}
+
+	subs := &streamSubscriptions{service: service, addresses: make(map[string]*streamAddress)}
+	if val := r.URL.Query().Get("addresses"); val != "" {
+		if err := subs.add(r.Context(), strings.Split(val, ",")); err != nil {
+			handler.WriteError(w, r, err)
+			return
+		}
+	}
+
+	meter, err := s.openMeter(r)
+	if err != nil {
+		handler.WriteError(w, r, err)
+		return
+	}
+	// the request context is canceled by the time the stream ends
+	defer meter.close(context.WithoutCancel(r.Context()))
+
+	heads, unsubscribe, err := service.SubscribeHeads()
+	if err != nil {
+		handler.WriteError(w, r, err)
+		return
+	}
+	defer unsubscribe()
+
+	if websocket.IsWebSocketUpgrade(r) {
+		s.serveWebSocket(w, r, heads, subs, meter)
+		return
+	}
+	s.serveEvents(w, r, heads, subs, meter)
+}
+
+// serveEvents streams the messages as Server-Sent Events named after their type.
+func (s *server) serveEvents(w http.ResponseWriter, r *http.Request, heads <-chan domain.HeadEvent, subs *streamSubscriptions, meter *streamMeter) {
+	ctx := r.Context()
+	rc := http.NewResponseController(w)
+	// the stream outlives the write timeout of the server
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		handler.WriteError(w, r, fmt.Errorf("failed to clear write deadline: %w", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-store")
+	// nginx buffers responses by default
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	write := func(messages ...domain.StreamMessage) error {
+		for _, msg := range messages {
+			data, err := json.Marshal(msg)
+			if err != nil {
+				return err
+			}
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", msg.Type, data); err != nil {
+				return err
+			}
+		}
+		return rc.Flush()
+	}
+
+	var err error
+	if len(subs.addresses) > 0 {
+		err = write(subs.subscribed())
+	} else {
+		err = rc.Flush()
+	}
+
+	keepalive := time.NewTicker(sseKeepalive)
+	defer keepalive.Stop()
+
+	for err == nil {
+		select {
+		case <-ctx.Done():
+			return
+		case head, ok := <-heads:
+			if !ok {
+				return
+			}
+			if !meter.charge(ctx, len(subs.addresses)) {
+				_ = write(domain.StreamMessage{Type: domain.StreamError, Error: quotaExceeded})
+				return
+			}
+			err = write(subs.messages(ctx, head)...)
+		case <-keepalive.C:
+			meter.renew(ctx)
+			if _, err = fmt.Fprint(w, ": keepalive\n\n"); err == nil {
+				err = rc.Flush()
+			}
+		}
+	}
+	logger.Extract(ctx).Debug("event stream closed", zap.Error(err))
+}
+
+// serveWebSocket streams the messages as WebSocket text messages and applies the subscription
+// changes sent by the client.
+func (s *server) serveWebSocket(w http.ResponseWriter, r *http.Request, heads <-chan domain.HeadEvent, subs *streamSubscriptions, meter *streamMeter) {
+	ctx := r.Context()
+	// Upgrade answers the client itself on failure
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		logger.Extract(ctx).Debug("failed to upgrade to websocket", zap.Error(err))
+		return
+	}
+	defer func() { _ = conn.Close() }()
+
+	done := make(chan struct{})
+	defer close(done)
+	commands := readCommands(conn, done)
+
+	write := func(messages ...domain.StreamMessage) error {
+		for _, msg := range messages {
+			_ = conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if err := conn.WriteJSON(msg); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if len(subs.addresses) > 0 {
+		err = write(subs.subscribed())
+	}
+
+	ping := time.NewTicker(wsPingInterval)
+	defer ping.Stop()
+
+	for err == nil {
+		select {
+		case cmd, ok := <-commands:
+			if !ok {
+				// the client went away
+				return
+			}
+			err = write(subs.apply(ctx, cmd))
+		case head, ok := <-heads:
+			if !ok {
+				_ = conn.WriteControl(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseGoingAway, "shutting down"), time.Now().Add(wsWriteWait))
+				return
+			}
+			if !meter.charge(ctx, len(subs.addresses)) {
+				_ = write(domain.StreamMessage{Type: domain.StreamError, Error: quotaExceeded})
+				_ = conn.WriteControl(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.ClosePolicyViolation, quotaExceeded), time.Now().Add(wsWriteWait))
+				return
+			}
+			err = write(subs.messages(ctx, head)...)
+		case <-ping.C:
+			meter.renew(ctx)
+			err = conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteWait))
+		}
+	}
+	logger.Extract(ctx).Debug("websocket stream closed", zap.Error(err))
+}
+
+// readCommands reads the messages of a WebSocket client until the connection fails or done is closed.
+// The returned channel is closed once the connection fails.
+func readCommands(conn *websocket.Conn, done <-chan struct{}) <-chan streamCommand {
+	commands := make(chan streamCommand)
+
+	conn.SetReadLimit(maxWSMessageBytes)
+	_ = conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+
+	go func() {
+		defer close(commands)
+		for {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			var cmd streamCommand
+			if err := json.Unmarshal(data, &cmd); err != nil {
+				// a malformed message is reported as an unknown command
+				cmd = streamCommand{}
+			}
+			select {
+			case commands <- cmd:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return commands
+}
+
+// apply applies a subscription change, it returns the subscribed addresses or an error message.
+func (s *streamSubscriptions) apply(ctx context.Context, cmd streamCommand) domain.StreamMessage {
+	var err error
+	switch cmd.Type {
+	case streamSubscribe:
+		err = s.add(ctx, cmd.Addresses)
+	case streamUnsubscribe:
+		err = s.remove(ctx, cmd.Addresses)
+	default:
+		err = domain.InvalidInputError("message type must be %s or %s", streamSubscribe, streamUnsubscribe)
+	}
+	if err != nil {
+		return domain.StreamMessage{Type: domain.StreamError, Error: domain.MessageOf(err)}
+	}
+
+	return s.subscribed()
+}
+
+// add subscribes to addresses or ENS names, all of them or none.
+func (s *streamSubscriptions) add(ctx context.Context, vals []string) error {
+	added := make(map[string]*streamAddress)
+	for _, val := range vals {
+		val = strings.TrimSpace(val)
+		if val == "" {
+			continue
+		}
+		address, name, err := s.service.ResolveAddress(ctx, val)
+		if err != nil {
+			return err
+		}
+		if _, ok := s.addresses[address]; !ok {
+			added[address] = &streamAddress{name: name}
+		}
+	}
+	if len(s.addresses)+len(added) > maxStreamAddresses {
+		return domain.InvalidInputError("at most %d addresses may be subscribed to", maxStreamAddresses)
+	}
+
+	for address, sub := range added {
+		s.addresses[address] = sub
+	}
+	return nil
+}
+
+// remove unsubscribes from addresses or ENS names, those not subscribed to are ignored.
+func (s *streamSubscriptions) remove(ctx context.Context, vals []string) error {
+	for _, val := range vals {
+		val = strings.TrimSpace(val)
+		if val == "" {
+			continue
+		}
+		address, _, err := s.service.ResolveAddress(ctx, val)
+		if err != nil {
+			return err
+		}
+		delete(s.addresses, address)
+	}
+	return nil
+}
+
+// subscribed lists the subscribed addresses.
+func (s *streamSubscriptions) subscribed() domain.StreamMessage {
+	addresses := make([]string, 0, len(s.addresses))
+	for address := range s.addresses {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+
+	return domain.StreamMessage{Type: domain.StreamSubscribed, Addresses: addresses}
+}
+
+// messages returns the messages of a head: the head itself followed by the balances
+// of the subscribed addresses that changed since they were last pushed.
+func (s *streamSubscriptions) messages(ctx context.Context, head domain.HeadEvent) []domain.StreamMessage {
+	messages := []domain.StreamMessage{{Type: domain.StreamHead, Head: &head}}
+	if len(s.addresses) == 0 {
+		return messages
+	}
+
+	addresses := make([]string, 0, len(s.addresses))
+	for address := range s.addresses {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+
+	// the balances are read concurrently, each failure is reported on its own
+	balances := make([]*domain.BlockBalance, len(addresses))
+	errs := make([]error, len(addresses))
+	var g errgroup.Group
+	for i, address := range addresses {
+		g.Go(func() error {
+			balances[i], errs[i] = s.service.GetBalanceAt(ctx, address, head.Number)
+			return nil
+		})
+	}
+	_ = g.Wait()
+
+	for i, address := range addresses {
+		if errs[i] != nil {
+			messages = append(messages, domain.StreamMessage{Type: domain.StreamError, Address: address, Error: domain.MessageOf(errs[i])})
+			continue
+		}
+		sub := s.addresses[address]
+		wei := balances[i].Wei.String()
+		if wei == sub.last {
+			continue
+		}
+		sub.last = wei
+
+		balance := domain.NewBalance(address, balances[i].Wei)
+		balance.ENSName = sub.name
+		messages = append(messages, domain.StreamMessage{Type: domain.StreamBalance, Block: &balances[i].Block, Balance: &balance})
+	}
+
+	return messages
+}
+
+// openMeter takes a stream slot of the API key of the request, it fails when every slot is held.
+func (s *server) openMeter(r *http.Request) (*streamMeter, error) {
+	key := middleware.APIKeyFromContext(r.Context())
+	if s.authService == nil || key == nil {
+		return nil, nil
+	}
+
+	route := "unmatched"
+	if current := mux.CurrentRoute(r); current != nil {
+		if tpl, err := current.GetPathTemplate(); err == nil {
+			route = tpl
+		}
+	}
+	meter := &streamMeter{authService: s.authService, key: *key, id: handler.NewRequestID(), route: route}
+	if err := s.authService.OpenStream(r.Context(), meter.key, meter.id); err != nil {
+		return nil, err
+	}
+
+	return meter, nil
+}
+
+// renew keeps the stream slot held.
+func (m *streamMeter) renew(ctx context.Context) {
+	if m != nil {
+		m.authService.RenewStream(ctx, m.key, m.id)
+	}
+}
+
+// close frees the stream slot.
+func (m *streamMeter) close(ctx context.Context) {
+	if m != nil {
+		m.authService.CloseStream(ctx, m.key, m.id)
+	}
+}
+
+// charge counts a head pushed along with the balances of addresses, it reports false once the quota is exhausted.
+func (m *streamMeter) charge(ctx context.Context, addresses int) bool {
+	if m == nil {
+		return true
+	}
+	return m.authService.ChargeStream(ctx, m.key, m.route, 1+addresses)
+}
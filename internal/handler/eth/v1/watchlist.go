@@ -0,0 +1,154 @@
+package v1
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/aisalamdag23/etherstats/internal/domain"
+	"github.com/aisalamdag23/etherstats/internal/handler"
+	"github.com/gorilla/mux"
+)
+
+const (
+	// maxWatchBodyBytes caps the size of a watch-list request body
+	maxWatchBodyBytes = 1 << 12
+)
+
+type watchRequest struct {
+	Address string `json:"address"`
+	Label   string `json:"label"`
+}
+
+func (s *server) ListWatched(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	service, ok := s.chainService(w, r)
+	if !ok {
+		return
+	}
+
+	resp, err := service.ListWatched(r.Context())
+	if err != nil {
+		handler.WriteError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+func (s *server) PostWatched(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	service, ok := s.chainService(w, r)
+	if !ok {
+		return
+	}
+
+	var req watchRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxWatchBodyBytes)).Decode(&req); err != nil {
+		handler.WriteError(w, r, domain.InvalidInputError("malformed request body"))
+		return
+	}
+
+	resp, err := service.WatchAddress(r.Context(), req.Address, req.Label)
+	if err != nil {
+		handler.WriteError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+func (s *server) GetWatched(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	service, ok := s.chainService(w, r)
+	if !ok {
+		return
+	}
+
+	resp, err := service.GetWatched(r.Context(), mux.Vars(r)["address"])
+	if err != nil {
+		handler.WriteError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// PatchWatched changes the label of a watched address, the only setting there is.
+func (s *server) PatchWatched(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	service, ok := s.chainService(w, r)
+	if !ok {
+		return
+	}
+
+	var req watchRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxWatchBodyBytes)).Decode(&req); err != nil {
+		handler.WriteError(w, r, domain.InvalidInputError("malformed request body"))
+		return
+	}
+
+	resp, err := service.UpdateWatched(r.Context(), mux.Vars(r)["address"], req.Label)
+	if err != nil {
+		handler.WriteError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+func (s *server) DeleteWatched(w http.ResponseWriter, r *http.Request) {
+	service, ok := s.chainService(w, r)
+	if !ok {
+		return
+	}
+
+	if err := service.UnwatchAddress(r.Context(), mux.Vars(r)["address"]); err != nil {
+		handler.WriteError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *server) GetTransfers(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	w.Header().Set("Content-Type", "application/json")
+
+	service, ok := s.chainService(w, r)
+	if !ok {
+		return
+	}
+
+	filter := domain.TransferFilter{
+		Address: id,
+		Cursor:  r.URL.Query().Get("cursor"),
+	}
+	if val := r.URL.Query().Get("limit"); val != "" {
+		limit, err := strconv.Atoi(val)
+		if err != nil {
+			handler.WriteError(w, r, domain.InvalidInputError("limit must be an integer"))
+			return
+		}
+		filter.Limit = limit
+	}
+
+	resp, err := service.GetTransfers(r.Context(), filter)
+	if err != nil {
+		handler.WriteError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,48 @@
+package health
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/aisalamdag23/etherstats/internal/domain"
+	"github.com/aisalamdag23/etherstats/internal/handler"
+	"github.com/gorilla/mux"
+)
+
+type server struct {
+	service domain.HealthService
+}
+
+// NewServer creates the liveness and readiness handler.
+func NewServer(service domain.HealthService) handler.Handler {
+	return &server{
+		service: service,
+	}
+}
+
+func (s *server) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/healthz", handler.Restrict(http.MethodGet, s.GetLiveness))
+	router.HandleFunc("/readyz", handler.Restrict(http.MethodGet, s.GetReadiness))
+}
+
+// GetLiveness reports that the process is up, without checking any dependency.
+func (s *server) GetLiveness(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(map[string]string{"status": domain.HealthOK})
+}
+
+// GetReadiness reports the status of every dependency, with 503 when any of them fails.
+func (s *server) GetReadiness(w http.ResponseWriter, r *http.Request) {
+	report := s.service.Ready(r.Context())
+
+	status := http.StatusOK
+	if report.Status != domain.HealthOK {
+		status = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(report)
+}
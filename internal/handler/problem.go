@@ -0,0 +1,103 @@
+package handler
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+
+	"github.com/aisalamdag23/etherstats/internal/domain"
+)
+
+// RequestIDHeader is the header carrying the ID of a request
+const RequestIDHeader = "X-Request-ID"
+
+// Problem is an RFC 7807 problem details response.
+type Problem struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail,omitempty"`
+	Instance  string `json:"instance,omitempty"`
+	RequestID string `json:"requestId,omitempty"`
+}
+
+// problemTypes are the status code and type URI of every kind of domain error, and whether
+// the caller caused it
+var problemTypes = map[domain.ErrorKind]struct {
+	status int
+	uri    string
+	caller bool
+}{
+	domain.KindInvalidInput:        {http.StatusBadRequest, "/problems/invalid-input", true},
+	domain.KindNotFound:            {http.StatusNotFound, "/problems/not-found", true},
+	domain.KindUnauthenticated:     {http.StatusUnauthorized, "/problems/unauthenticated", true},
+	domain.KindForbidden:           {http.StatusForbidden, "/problems/forbidden", true},
+	domain.KindRateLimited:         {http.StatusTooManyRequests, "/problems/rate-limited", true},
+	domain.KindUpstreamRateLimited: {http.StatusTooManyRequests, "/problems/upstream-rate-limited", false},
+	domain.KindUpstreamUnavailable: {http.StatusBadGateway, "/problems/upstream-unavailable", false},
+	domain.KindTimeout:             {http.StatusGatewayTimeout, "/problems/timeout", false},
+	domain.KindInternal:            {http.StatusInternalServerError, "/problems/internal", false},
+}
+
+// WriteError writes the problem matching the kind of err.
+// Errors the caller caused carry the error message, the others only the caller safe message
+// so internal details, such as endpoint names and upstream errors, do not leak.
+func WriteError(w http.ResponseWriter, r *http.Request, err error) {
+	pt := problemTypes[domain.KindOf(err)]
+
+	detail := domain.MessageOf(err)
+	if pt.caller {
+		detail = err.Error()
+	}
+
+	WriteProblem(w, r, Problem{
+		Type:   pt.uri,
+		Status: pt.status,
+		Detail: detail,
+	})
+}
+
+// WriteProblem writes p as an application/problem+json response.
+// The title, instance and request ID are filled in when empty.
+func WriteProblem(w http.ResponseWriter, r *http.Request, p Problem) {
+	if p.Type == "" {
+		p.Type = "about:blank"
+	}
+	if p.Title == "" {
+		p.Title = http.StatusText(p.Status)
+	}
+	if p.Instance == "" {
+		p.Instance = r.URL.Path
+	}
+	if p.RequestID == "" {
+		p.RequestID = RequestID(w, r)
+	}
+
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(p.Status)
+	_ = json.NewEncoder(w).Encode(p)
+}
+
+// RequestID returns the ID of the request, taken from the response or request header.
+// A new ID is generated and set on the response when there is none.
+func RequestID(w http.ResponseWriter, r *http.Request) string {
+	if id := w.Header().Get(RequestIDHeader); id != "" {
+		return id
+	}
+
+	id := r.Header.Get(RequestIDHeader)
+	if id == "" {
+		id = NewRequestID()
+	}
+	w.Header().Set(RequestIDHeader, id)
+
+	return id
+}
+
+// NewRequestID generates a random 128-bit request ID.
+func NewRequestID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
@@ -0,0 +1,41 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aisalamdag23/etherstats/internal/domain"
+)
+
+// TestWriteErrorUpstreamRateLimited checks that a throttled failover reports the generic message only,
+// not the endpoints and upstream errors it wraps.
+func TestWriteErrorUpstreamRateLimited(t *testing.T) {
+	upstream := errors.Join(
+		errors.New("primary: 429 Too Many Requests: https://eth-mainnet.example.com/v2/secret-key"),
+		errors.New(`fallback: {"jsonrpc":"2.0","error":{"code":-32005,"message":"limit exceeded"}}`),
+	)
+	err := domain.NewError(domain.KindUpstreamRateLimited, upstream, "all endpoints failed")
+
+	rec := httptest.NewRecorder()
+	WriteError(rec, httptest.NewRequest(http.MethodGet, "/api/v1/eth/fees", nil), err)
+
+	if rec.Code != http.StatusTooManyRequests {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusTooManyRequests)
+	}
+	var p Problem
+	if err := json.NewDecoder(rec.Body).Decode(&p); err != nil {
+		t.Fatal(err)
+	}
+	if p.Detail != "all endpoints failed" {
+		t.Errorf("detail = %q, want %q", p.Detail, "all endpoints failed")
+	}
+	for _, leak := range []string{"primary", "secret-key", "jsonrpc"} {
+		if strings.Contains(p.Detail, leak) {
+			t.Errorf("detail %q leaks %q", p.Detail, leak)
+		}
+	}
+}
@@ -1,11 +1,37 @@
 package handler
 
-import "net/http"
+import (
+	"net/http"
+	"sort"
+	"strings"
+)
 
 func Restrict(method string, handlerFunc func(w http.ResponseWriter, r *http.Request)) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != method {
-			w.WriteHeader(http.StatusMethodNotAllowed)
+			w.Header().Set("Allow", method)
+			WriteProblem(w, r, Problem{Status: http.StatusMethodNotAllowed})
+			return
+		}
+		handlerFunc(w, r)
+	}
+}
+
+// RestrictMethods dispatches a route to the handler of the request method,
+// answering 405 with the allowed methods otherwise.
+func RestrictMethods(handlers map[string]func(w http.ResponseWriter, r *http.Request)) func(w http.ResponseWriter, r *http.Request) {
+	methods := make([]string, 0, len(handlers))
+	for method := range handlers {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+	allow := strings.Join(methods, ", ")
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		handlerFunc, ok := handlers[r.Method]
+		if !ok {
+			w.Header().Set("Allow", allow)
+			WriteProblem(w, r, Problem{Status: http.StatusMethodNotAllowed})
 			return
 		}
 		handlerFunc(w, r)
@@ -0,0 +1,159 @@
+package webhook
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/aisalamdag23/etherstats/internal/domain"
+	"github.com/aisalamdag23/etherstats/internal/handler"
+	"github.com/aisalamdag23/etherstats/internal/infrastructure/protocol/rest/middleware"
+	"github.com/gorilla/mux"
+)
+
+const (
+	// maxBodyBytes caps the size of a webhook request body
+	maxBodyBytes = 1 << 12
+)
+
+type server struct {
+	service domain.WebhookService
+}
+
+// NewServer creates the handler managing webhooks and their deliveries.
+func NewServer(service domain.WebhookService) handler.Handler {
+	return &server{
+		service: service,
+	}
+}
+
+func (s *server) RegisterRoutes(router *mux.Router) {
+	router.HandleFunc("/webhooks", handler.RestrictMethods(map[string]func(w http.ResponseWriter, r *http.Request){
+		http.MethodGet:  s.ListWebhooks,
+		http.MethodPost: s.CreateWebhook,
+	}))
+	router.HandleFunc("/webhooks/{id}", handler.RestrictMethods(map[string]func(w http.ResponseWriter, r *http.Request){
+		http.MethodGet:    s.GetWebhook,
+		http.MethodDelete: s.DeleteWebhook,
+	}))
+	router.HandleFunc("/webhooks/{id}/deliveries", handler.Restrict(http.MethodGet, s.ListDeliveries))
+	router.HandleFunc("/webhooks/{id}/deliveries/{deliveryId}/replay", handler.Restrict(http.MethodPost, s.ReplayDelivery))
+}
+
+func (s *server) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	var params domain.WebhookParams
+
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&params); err != nil {
+		handler.WriteError(w, r, domain.InvalidInputError("malformed request body"))
+		return
+	}
+
+	webhook, err := s.service.CreateWebhook(r.Context(), apiKeyID(r), params)
+	if err != nil {
+		handler.WriteError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, webhook)
+}
+
+func (s *server) ListWebhooks(w http.ResponseWriter, r *http.Request) {
+	webhooks, err := s.service.ListWebhooks(r.Context(), apiKeyID(r))
+	if err != nil {
+		handler.WriteError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, webhooks)
+}
+
+func (s *server) GetWebhook(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	webhook, err := s.service.GetWebhook(r.Context(), apiKeyID(r), id)
+	if err != nil {
+		handler.WriteError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, webhook)
+}
+
+func (s *server) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	if err := s.service.DeleteWebhook(r.Context(), apiKeyID(r), id); err != nil {
+		handler.WriteError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *server) ListDeliveries(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	deliveries, err := s.service.ListDeliveries(r.Context(), apiKeyID(r), id)
+	if err != nil {
+		handler.WriteError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, deliveries)
+}
+
+// ReplayDelivery queues a delivery again, it is attempted on the next dispatch.
+func (s *server) ReplayDelivery(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	deliveryID, ok := pathID(w, r, "deliveryId")
+	if !ok {
+		return
+	}
+
+	delivery, err := s.service.ReplayDelivery(r.Context(), apiKeyID(r), id, deliveryID)
+	if err != nil {
+		handler.WriteError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusAccepted, delivery)
+}
+
+// apiKeyID returns the ID of the API key authenticating the request, nil when authentication is disabled.
+func apiKeyID(r *http.Request) *int64 {
+	if key := middleware.APIKeyFromContext(r.Context()); key != nil {
+		return &key.ID
+	}
+	return nil
+}
+
+// pathID parses an ID of the route, writing a problem when it is not a positive integer.
+func pathID(w http.ResponseWriter, r *http.Request, name string) (int64, bool) {
+	id, err := strconv.ParseInt(mux.Vars(r)[name], 10, 64)
+	if err != nil || id <= 0 {
+		handler.WriteError(w, r, domain.InvalidInputError("invalid %s %q", name, mux.Vars(r)[name]))
+		return 0, false
+	}
+	return id, true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
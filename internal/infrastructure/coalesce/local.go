@@ -0,0 +1,36 @@
+package coalesce
+
+import (
+	"context"
+
+	"github.com/aisalamdag23/etherstats/internal/domain"
+	"golang.org/x/sync/singleflight"
+)
+
+type local struct {
+	group singleflight.Group
+}
+
+// NewLocal creates a coalescer that deduplicates loads within this process.
+func NewLocal() domain.Coalescer {
+	return &local{}
+}
+
+// Do runs load once for all concurrent callers of the same key.
+// The load is detached from the cancellation of the caller that started it,
+// so a caller giving up does not fail the others; each caller still stops waiting when its ctx is done.
+func (l *local) Do(ctx context.Context, key string, load func(ctx context.Context) ([]byte, error)) ([]byte, error) {
+	ch := l.group.DoChan(key, func() (interface{}, error) {
+		return load(context.WithoutCancel(ctx))
+	})
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case res := <-ch:
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		return res.Val.([]byte), nil
+	}
+}
@@ -0,0 +1,137 @@
+package coalesce
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	"github.com/aisalamdag23/etherstats/internal/domain"
+	"github.com/redis/go-redis/v9"
+)
+
+// releaseScript deletes the lock only if it is still held by the given token
+var releaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+
+type (
+	// RedisOptions tunes the Redis lock backing the coalescer
+	RedisOptions struct {
+		// LockTTL bounds how long a load may hold the lock, in case its replica dies
+		LockTTL time.Duration
+		// PollInterval is how often waiting callers check for the result
+		PollInterval time.Duration
+	}
+
+	redisCoalescer struct {
+		local     domain.Coalescer
+		client    *redis.Client
+		namespace string
+		opts      RedisOptions
+	}
+)
+
+// NewRedis creates a coalescer that deduplicates loads across every replica sharing the Redis instance.
+// Loads are first deduplicated within the process, then the one remaining load per replica takes
+// a Redis lock. Replicas that find the lock taken wait for the result published by its holder.
+// Keys are prefixed with namespace.
+func NewRedis(client *redis.Client, namespace string, opts RedisOptions) domain.Coalescer {
+	return &redisCoalescer{
+		local:     NewLocal(),
+		client:    client,
+		namespace: namespace,
+		opts:      opts,
+	}
+}
+
+// Do runs load once for all concurrent callers of the same key across replicas.
+func (c *redisCoalescer) Do(ctx context.Context, key string, load func(ctx context.Context) ([]byte, error)) ([]byte, error) {
+	return c.local.Do(ctx, key, func(ctx context.Context) ([]byte, error) {
+		return c.do(ctx, key, load)
+	})
+}
+
+func (c *redisCoalescer) do(ctx context.Context, key string, load func(ctx context.Context) ([]byte, error)) ([]byte, error) {
+	lockKey := c.namespace + ":coalesce:lock:" + key
+	token, err := newToken()
+	if err != nil {
+		return nil, err
+	}
+
+	acquired, err := c.client.SetNX(ctx, lockKey, token, c.opts.LockTTL).Result()
+	if err != nil {
+		// Redis is unavailable, load without coordinating rather than failing
+		return load(ctx)
+	}
+	if acquired {
+		defer releaseScript.Run(context.WithoutCancel(ctx), c.client, []string{lockKey}, token)
+
+		val, err := load(ctx)
+		if err != nil {
+			return nil, err
+		}
+		// waiting replicas only need the result until they notice it, the lock TTL is plenty
+		_ = c.client.Set(ctx, c.resultKey(key, token), val, c.opts.LockTTL).Err()
+
+		return val, nil
+	}
+
+	return c.wait(ctx, key, lockKey, load)
+}
+
+// wait polls for the result of the load holding the lock.
+// When the lock is released or expires without a result, e.g. because that load failed,
+// it loads the value itself.
+func (c *redisCoalescer) wait(ctx context.Context, key, lockKey string, load func(ctx context.Context) ([]byte, error)) ([]byte, error) {
+	holder, err := c.client.Get(ctx, lockKey).Result()
+	if err != nil {
+		// the lock was released in the meantime or Redis is unavailable
+		return load(ctx)
+	}
+	resultKey := c.resultKey(key, holder)
+
+	ticker := time.NewTicker(c.opts.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		val, err := c.client.Get(ctx, resultKey).Bytes()
+		if err == nil {
+			return val, nil
+		}
+		if err != redis.Nil {
+			return load(ctx)
+		}
+
+		// the result is published before the lock is released, so a missing
+		// result after the lock is gone means the load failed
+		current, err := c.client.Get(ctx, lockKey).Result()
+		if err != nil || current != holder {
+			if val, err := c.client.Get(ctx, resultKey).Bytes(); err == nil {
+				return val, nil
+			}
+			return load(ctx)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// resultKey is where the holder of the lock identified by token publishes its result.
+func (c *redisCoalescer) resultKey(key, token string) string {
+	return c.namespace + ":coalesce:result:" + key + ":" + token
+}
+
+func newToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
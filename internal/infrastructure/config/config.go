@@ -4,6 +4,8 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/spf13/viper"
@@ -22,6 +24,22 @@ type (
 		PostgresDB Database         `mapstructure:"postgresdb" validate:"required"`
 		RedisDB    Database         `mapstructure:"redisdb" validate:"required"`
 		Alchemy    APIProviderCreds `mapstructure:"alchemy" validate:"required"`
+		// Chains are the networks served by the API. The first one is the default
+		// chain served by routes without a chain. When empty, Ethereum mainnet is
+		// served through Alchemy.MainNetURL.
+		Chains []Chain `mapstructure:"chains" validate:"dive"`
+		RPC    RPC     `mapstructure:"rpc"`
+		Poller Poller  `mapstructure:"poller"`
+		// Coalescing deduplicates concurrent upstream calls on cache misses
+		Coalescing Coalescing `mapstructure:"coalescing"`
+		Staleness  Staleness  `mapstructure:"staleness"`
+		Health     Health     `mapstructure:"health"`
+		Tracing    Tracing    `mapstructure:"tracing"`
+		Auth       Auth       `mapstructure:"auth"`
+		CORS       CORS       `mapstructure:"cors"`
+		Indexer    Indexer    `mapstructure:"indexer"`
+		Webhooks   Webhooks   `mapstructure:"webhooks"`
+		Stream     Stream     `mapstructure:"stream"`
 	}
 
 	// General config.
@@ -55,12 +73,150 @@ type (
 	}
 
 	APIProviderCreds struct {
-		APIKey      string `mapstructure:"api_key" validate:"required"`
-		MainNetURL  string `mapstructure:"mainnet_url" validate:"required"`
+		APIKey      string `mapstructure:"api_key"`
+		MainNetURL  string `mapstructure:"mainnet_url"`
+		CacheTTLSec int    `mapstructure:"cache_ttl_sec"`
+	}
+
+	// Chain is a network served by the API
+	Chain struct {
+		// Name is used in routes, e.g. /api/v1/{name}/eth/{id}
+		Name    string `mapstructure:"name" validate:"required"`
+		ChainID uint64 `mapstructure:"chain_id" validate:"required"`
+		// RPCURL is the Alchemy base URL of the network, the API key is appended to it.
+		// It is only used when no Endpoints are set.
+		RPCURL      string `mapstructure:"rpc_url" validate:"required_without=Endpoints"`
 		CacheTTLSec int    `mapstructure:"cache_ttl_sec" validate:"required"`
+		// Endpoints are JSON-RPC endpoints of any provider, in order of preference
+		Endpoints []Endpoint `mapstructure:"endpoints" validate:"dive"`
+		// FinalityDepth is how many confirmations make a block final on this chain, 64 when unset
+		FinalityDepth uint64 `mapstructure:"finality_depth"`
+	}
+
+	// Endpoint is a JSON-RPC endpoint, the URL includes any credentials
+	Endpoint struct {
+		Name string `mapstructure:"name" validate:"required"`
+		URL  string `mapstructure:"url" validate:"required"`
+	}
+
+	// Poller keeps the gas price, block number and fee cache of every chain warm in the background
+	Poller struct {
+		Enabled bool `mapstructure:"enabled"`
+		// IntervalSec is how often the cache is refreshed between new heads.
+		// It must be shorter than the cache TTL of every chain.
+		IntervalSec int `mapstructure:"interval_sec" validate:"required_if=Enabled true"`
+	}
+
+	// Indexer scans new blocks of every chain for the transactions of watched addresses.
+	// Writes are idempotent, but it only needs to be enabled on a single replica.
+	Indexer struct {
+		Enabled     bool `mapstructure:"enabled"`
+		IntervalSec int  `mapstructure:"interval_sec" validate:"required_if=Enabled true"`
+		// MaxBlocksPerRun caps the blocks indexed on each interval
+		MaxBlocksPerRun int `mapstructure:"max_blocks_per_run" validate:"required_if=Enabled true"`
+	}
+
+	// Webhooks notifies registered URLs of the balance changes of their address.
+	// Deliveries are shared by every replica, but the monitor only needs to be enabled on a single one.
+	Webhooks struct {
+		Enabled            bool `mapstructure:"enabled"`
+		MonitorEnabled     bool `mapstructure:"monitor_enabled"`
+		MonitorIntervalSec int  `mapstructure:"monitor_interval_sec" validate:"required_if=MonitorEnabled true"`
+		// MaxBlocksPerRun caps the blocks checked on each monitor interval
+		MaxBlocksPerRun     int `mapstructure:"max_blocks_per_run" validate:"required_if=MonitorEnabled true"`
+		DispatchIntervalSec int `mapstructure:"dispatch_interval_sec" validate:"required_if=Enabled true"`
+		// TimeoutMs bounds every delivery request
+		TimeoutMs   int `mapstructure:"timeout_ms" validate:"required_if=Enabled true"`
+		MaxAttempts int `mapstructure:"max_attempts" validate:"required_if=Enabled true"`
+		// RetryBaseSec is the delay before the first retry, doubled on each of the next ones up to RetryMaxSec
+		RetryBaseSec int `mapstructure:"retry_base_sec" validate:"required_if=Enabled true"`
+		RetryMaxSec  int `mapstructure:"retry_max_sec" validate:"required_if=Enabled true"`
+	}
+
+	// Stream pushes the new heads of every chain to SSE and WebSocket clients.
+	// Every replica serves streams, the heads are published by a single elected one.
+	Stream struct {
+		Enabled bool `mapstructure:"enabled"`
+		// PollIntervalSec is how often the latest block is polled when no endpoint supports subscriptions
+		PollIntervalSec int `mapstructure:"poll_interval_sec" validate:"required_if=Enabled true"`
+		// LeaderTTLSec is the lease of the publishing replica, a dead one is replaced within it
+		LeaderTTLSec int `mapstructure:"leader_ttl_sec" validate:"required_if=Enabled true"`
+		// MaxPerKey caps the concurrent streams of an API key when auth is enabled
+		MaxPerKey int `mapstructure:"max_per_key" validate:"required_if=Enabled true"`
+	}
+
+	Coalescing struct {
+		// Mode is either "local" (within a replica, the default) or "redis" (across replicas)
+		Mode string `mapstructure:"mode" validate:"omitempty,oneof=local redis"`
+		// LockTTLMs bounds how long a replica may hold the Redis lock of a key
+		LockTTLMs int `mapstructure:"lock_ttl_ms" validate:"required_if=Mode redis"`
+		// PollIntervalMs is how often replicas waiting on the Redis lock check for the result
+		PollIntervalMs int `mapstructure:"poll_interval_ms" validate:"required_if=Mode redis"`
+	}
+
+	// Staleness bounds the last known good values served when upstream calls fail
+	Staleness struct {
+		// MaxSec is the maximum age of a last known good value, 0 disables them
+		MaxSec int `mapstructure:"max_sec"`
+	}
+
+	// Health tunes the readiness checks of Postgres, Redis and the RPC provider of every chain
+	Health struct {
+		// TimeoutMs bounds every check, 0 disables it
+		TimeoutMs int `mapstructure:"timeout_ms"`
+		// MaxHeadAgeSec is how old the head block of a chain may be, 0 disables the check
+		MaxHeadAgeSec int `mapstructure:"max_head_age_sec"`
+	}
+
+	// Tracing exports OpenTelemetry spans to an OTLP/HTTP collector
+	Tracing struct {
+		Enabled bool `mapstructure:"enabled"`
+		// Endpoint is the host:port of the collector
+		Endpoint string `mapstructure:"endpoint" validate:"required_if=Enabled true"`
+		// Insecure sends spans over plain HTTP
+		Insecure bool `mapstructure:"insecure"`
+		// SampleRatio is the share of new traces that are sampled, 0 samples none of them
+		SampleRatio float64 `mapstructure:"sample_ratio" validate:"gte=0,lte=1"`
+	}
+
+	// Auth requires an API key on every API route, each key with its own rate limit and daily quota
+	Auth struct {
+		Enabled bool `mapstructure:"enabled"`
+		// KeyCacheTTLSec is how long keys are cached in Redis, which bounds how long a revoked key keeps working
+		KeyCacheTTLSec int `mapstructure:"key_cache_ttl_sec" validate:"required_if=Enabled true"`
+		// DefaultRatePerSec, DefaultBurst and DefaultDailyQuota apply to keys created without them
+		DefaultRatePerSec int `mapstructure:"default_rate_per_sec" validate:"gte=0"`
+		DefaultBurst      int `mapstructure:"default_burst" validate:"gte=0"`
+		DefaultDailyQuota int `mapstructure:"default_daily_quota" validate:"gte=0"`
+		// UsageSyncIntervalSec is how often usage counters are aggregated from Redis into Postgres, 0 disables it
+		UsageSyncIntervalSec int `mapstructure:"usage_sync_interval_sec" validate:"gte=0"`
+	}
+
+	CORS struct {
+		// AllowedOrigins are the origins browsers may call the API from, any origin when empty
+		AllowedOrigins []string `mapstructure:"allowed_origins"`
+	}
+
+	// RPC tunes failover across the endpoints of a chain
+	RPC struct {
+		// RequestTimeoutMs bounds a single attempt on a single endpoint
+		RequestTimeoutMs int `mapstructure:"request_timeout_ms"`
+		// CallTimeoutMs bounds each value fetched by a request, across every attempt, 0 disables it
+		CallTimeoutMs int `mapstructure:"call_timeout_ms"`
+		// HealthCheckIntervalSec is how often endpoints are health checked, 0 disables it
+		HealthCheckIntervalSec int `mapstructure:"health_check_interval_sec"`
+		// MaxBlockLag is how many blocks an endpoint may trail the others before it is deprioritized
+		MaxBlockLag uint64 `mapstructure:"max_block_lag"`
 	}
 )
 
+const (
+	mainnetName    = "mainnet"
+	mainnetChainID = 1
+	// defaultFinalityDepth is two epochs of Ethereum proof of stake, after which blocks are finalized
+	defaultFinalityDepth = 64
+)
+
 // Load loads all configurations in to a new Config struct.
 // CommitHash is a git commit hash of this app build.
 // Tag is a git Tag of this app build.
@@ -92,11 +248,50 @@ func Load(commitHash, tag string) (*Config, error) {
 	c.CommitHash = commitHash
 	c.Tag = tag
 
+	if len(c.Chains) == 0 {
+		if c.Alchemy.MainNetURL == "" || c.Alchemy.CacheTTLSec == 0 {
+			return nil, errors.New("either chains or alchemy.mainnet_url and alchemy.cache_ttl_sec must be set")
+		}
+		c.Chains = []Chain{{
+			Name:        mainnetName,
+			ChainID:     mainnetChainID,
+			RPCURL:      c.Alchemy.MainNetURL,
+			CacheTTLSec: c.Alchemy.CacheTTLSec,
+		}}
+	}
+
+	for i := range c.Chains {
+		if c.Chains[i].FinalityDepth == 0 {
+			c.Chains[i].FinalityDepth = defaultFinalityDepth
+		}
+	}
+
 	validator := validator.New()
 	err = validator.Struct(c)
 	if err != nil {
 		return nil, err
 	}
 
+	if err := validateChains(c.Chains); err != nil {
+		return nil, err
+	}
+
 	return &c, nil
 }
+
+// validateChains makes sure chain names and IDs are unique, since both are used as route keys.
+// Routes match names whatever their case, so names differing only in case are duplicates.
+func validateChains(chains []Chain) error {
+	seen := make(map[string]bool, len(chains)*2)
+	for _, chain := range chains {
+		name := strings.ToLower(chain.Name)
+		id := strconv.FormatUint(chain.ChainID, 10)
+		if seen[name] || seen[id] {
+			return fmt.Errorf("chain %q (%d) is configured more than once", chain.Name, chain.ChainID)
+		}
+		seen[name] = true
+		seen[id] = true
+	}
+
+	return nil
+}
@@ -0,0 +1,114 @@
+package election
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	"github.com/aisalamdag23/etherstats/internal/domain"
+	"github.com/aisalamdag23/etherstats/internal/infrastructure/logger"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+var (
+	// renewScript extends the lease only if it is still held by the given token
+	renewScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`)
+	// releaseScript deletes the lease only if it is still held by the given token
+	releaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+)
+
+type redisElector struct {
+	client *redis.Client
+	key    string
+	// ttl is the lease of the leader, a replica that dies is replaced within ttl
+	ttl time.Duration
+}
+
+// NewRedis creates an elector backed by a Redis lease at key. The leader renews the lease
+// every third of ttl, the other replicas try to take it over at the same pace.
+func NewRedis(client *redis.Client, key string, ttl time.Duration) domain.Elector {
+	return &redisElector{
+		client: client,
+		key:    key,
+		ttl:    ttl,
+	}
+}
+
+// Lead runs task whenever this replica holds the lease, until ctx is done.
+func (e *redisElector) Lead(ctx context.Context, task func(ctx context.Context) error) error {
+	ticker := time.NewTicker(e.ttl / 3)
+	defer ticker.Stop()
+
+	for {
+		token, err := newToken()
+		if err != nil {
+			return err
+		}
+		acquired, err := e.client.SetNX(ctx, e.key, token, e.ttl).Result()
+		if err != nil && ctx.Err() == nil {
+			logger.Extract(ctx).Error("failed to acquire lease", zap.Error(err), zap.String("key", e.key))
+		}
+		if acquired {
+			e.lead(ctx, token, task)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// lead runs task while renewing the lease, it returns once task returns or the lease is lost.
+func (e *redisElector) lead(ctx context.Context, token string, task func(ctx context.Context) error) {
+	leadCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	// the lease is left to the next leader even when ctx is done
+	defer releaseScript.Run(context.WithoutCancel(ctx), e.client, []string{e.key}, token)
+
+	done := make(chan error, 1)
+	go func() { done <- task(leadCtx) }()
+
+	ticker := time.NewTicker(e.ttl / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case err := <-done:
+			if err != nil && ctx.Err() == nil {
+				logger.Extract(ctx).Error("leader task failed", zap.Error(err), zap.String("key", e.key))
+			}
+			return
+		case <-ticker.C:
+			renewed, err := renewScript.Run(leadCtx, e.client, []string{e.key}, token, e.ttl.Milliseconds()).Int()
+			if err != nil || renewed == 0 {
+				if ctx.Err() == nil {
+					logger.Extract(ctx).Warn("lost lease", zap.Error(err), zap.String("key", e.key))
+				}
+				cancel()
+				<-done
+				return
+			}
+		}
+	}
+}
+
+// newToken returns a random token identifying the lease holder.
+func newToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -3,6 +3,7 @@ package logger
 import (
 	"context"
 
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 )
 
@@ -23,8 +24,16 @@ func newCtxLogger(entry *zap.Logger) *ctxLogger {
 }
 
 // ToContext adds the logger to the context for extraction later.
+// When the context carries a recording span, its trace and span IDs are added to the logger
+// so log lines can be joined with traces.
 // Returning the new context that has been created.
 func ToContext(ctx context.Context, entry *zap.Logger) context.Context {
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		entry = entry.With(
+			zap.String("trace_id", sc.TraceID().String()),
+			zap.String("span_id", sc.SpanID().String()),
+		)
+	}
 	return context.WithValue(ctx, ctxLoggerKey, newCtxLogger(entry))
 }
 
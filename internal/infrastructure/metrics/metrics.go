@@ -0,0 +1,92 @@
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "etherstats"
+
+// Cache lookup results
+const (
+	CacheHit   = "hit"
+	CacheMiss  = "miss"
+	CacheError = "error"
+)
+
+var (
+	// HTTPRequests counts served requests per route template, method and status code
+	HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "Number of HTTP requests served.",
+	}, []string{"route", "method", "status"})
+
+	// HTTPRequestDuration observes the latency of served requests per route template, method and status code, streams aside
+	HTTPRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "Latency of HTTP requests.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+
+	// HTTPStreamDuration observes how long Server-Sent Events and WebSocket streams stay open per route template
+	HTTPStreamDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "stream_duration_seconds",
+		Help:      "Duration of HTTP streams.",
+		// from a second to about 18 hours
+		Buckets: prometheus.ExponentialBuckets(1, 4, 9),
+	}, []string{"route"})
+
+	// CacheRequests counts Redis cache lookups per chain, key and result (hit, miss, error)
+	CacheRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "cache",
+		Name:      "requests_total",
+		Help:      "Number of cache lookups by result.",
+	}, []string{"chain", "key", "result"})
+
+	// RPCRequestDuration observes the latency of JSON-RPC calls per endpoint and method
+	RPCRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "rpc",
+		Name:      "request_duration_seconds",
+		Help:      "Latency of upstream JSON-RPC calls.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"endpoint", "method"})
+
+	// RPCErrors counts failed JSON-RPC calls per endpoint, method and error kind
+	RPCErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "rpc",
+		Name:      "errors_total",
+		Help:      "Number of failed upstream JSON-RPC calls by error kind.",
+	}, []string{"endpoint", "method", "kind"})
+
+	// DBQueryDuration observes the latency of database queries per query name
+	DBQueryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "db",
+		Name:      "query_duration_seconds",
+		Help:      "Latency of database queries.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"query"})
+)
+
+// Handler serves every registered metric in the Prometheus exposition format.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// Since returns the seconds elapsed since start, as observed by histograms.
+func Since(start time.Time) float64 {
+	return time.Since(start).Seconds()
+}
@@ -0,0 +1,120 @@
+package middleware
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/aisalamdag23/etherstats/internal/domain"
+	"github.com/aisalamdag23/etherstats/internal/handler"
+	"github.com/aisalamdag23/etherstats/internal/infrastructure/logger"
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+const (
+	// APIKeyHeader is the header carrying the API key, as an alternative to a bearer token
+	APIKeyHeader = "X-API-Key"
+	// StreamTokenParam is the query parameter carrying a stream token, for clients that cannot set headers
+	StreamTokenParam = "token"
+	// streamRouteSuffix ends the template of the stream routes, the only ones taking stream tokens
+	streamRouteSuffix = "/eth/stream"
+)
+
+type apiKeyCtxKey struct{}
+
+// Auth is a middleware that authenticates requests by API key and enforces the key's
+// rate limit and daily quota. Every response carries the RateLimit-* headers of the key.
+// chains maps the lowercase chain names and IDs accepted by the routes, and "" for the
+// default chain, to the chain name, so keys restricted to some chains are refused the others.
+// Browsers cannot set headers on EventSource and WebSocket requests, so stream routes also take
+// a single-use stream token in ?token=, issued beforehand to a request carrying the key.
+func Auth(authService domain.AuthService, chains map[string]string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+
+			key, err := authenticate(ctx, authService, r)
+			if err != nil {
+				handler.WriteError(w, r, err)
+				return
+			}
+			ctx = logger.ToContext(ctx, logger.Extract(ctx).With(zap.Int64("api_key_id", key.ID)))
+			ctx = context.WithValue(ctx, apiKeyCtxKey{}, key)
+
+			// unknown chains are left to the handler, which answers 404
+			chain, ok := chains[strings.ToLower(mux.Vars(r)["chain"])]
+			if ok && !key.AllowsChain(chain) {
+				handler.WriteError(w, r.WithContext(ctx), domain.ForbiddenError("api key is not allowed on chain %q", chain))
+				return
+			}
+
+			limit, err := authService.Allow(ctx, *key, routeTemplate(r))
+			if err != nil {
+				handler.WriteError(w, r.WithContext(ctx), err)
+				return
+			}
+
+			reset := strconv.Itoa(int(math.Ceil(limit.Reset.Seconds())))
+			w.Header().Set("RateLimit-Limit", strconv.Itoa(limit.Limit))
+			w.Header().Set("RateLimit-Remaining", strconv.Itoa(limit.Remaining))
+			w.Header().Set("RateLimit-Reset", reset)
+
+			if !limit.Allowed {
+				w.Header().Set("Retry-After", reset)
+				detail := "rate limit exceeded"
+				if limit.QuotaExceeded {
+					detail = "daily quota exceeded"
+				}
+				handler.WriteError(w, r, domain.NewError(domain.KindRateLimited, nil, "%s", detail))
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// RequireAdmin is a middleware that only lets admin keys through. It goes after Auth.
+func RequireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if key := APIKeyFromContext(r.Context()); key == nil || !key.IsAdmin {
+			handler.WriteError(w, r, domain.ForbiddenError("admin api key required"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// APIKeyFromContext returns the key authenticated by Auth, nil when there is none.
+func APIKeyFromContext(ctx context.Context) *domain.APIKey {
+	key, _ := ctx.Value(apiKeyCtxKey{}).(*domain.APIKey)
+	return key
+}
+
+// authenticate looks up the key of the request, or that of its stream token on stream routes
+// when the request carries no key.
+func authenticate(ctx context.Context, authService domain.AuthService, r *http.Request) (*domain.APIKey, error) {
+	rawKey := apiKey(r)
+	if token := r.URL.Query().Get(StreamTokenParam); rawKey == "" && token != "" &&
+		strings.HasSuffix(routeTemplate(r), streamRouteSuffix) {
+		return authService.AuthenticateStreamToken(ctx, token)
+	}
+
+	return authService.Authenticate(ctx, rawKey)
+}
+
+// apiKey reads the API key from the X-API-Key header or a bearer Authorization header.
+func apiKey(r *http.Request) string {
+	if key := r.Header.Get(APIKeyHeader); key != "" {
+		return key
+	}
+
+	auth := r.Header.Get("Authorization")
+	if len(auth) > len("Bearer ") && strings.EqualFold(auth[:len("Bearer ")], "Bearer ") {
+		return strings.TrimSpace(auth[len("Bearer "):])
+	}
+	return ""
+}
@@ -2,17 +2,51 @@ package middleware
 
 import (
 	"net/http"
+	"regexp"
+	"time"
 
+	"github.com/aisalamdag23/etherstats/internal/handler"
 	"github.com/aisalamdag23/etherstats/internal/infrastructure/logger"
+	"github.com/gorilla/mux"
 	"go.uber.org/zap"
 )
 
-// CtxWithLogger is a middleware that puts a logger instance to context
+// requestIDPattern is what a propagated X-Request-ID may look like, anything else is replaced
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// CtxWithLogger is a middleware that puts a request scoped logger instance to context.
+// It propagates the X-Request-ID of the request, or creates one, and echoes it in the response.
+// The logger carries the request ID, route and method, and writes one access log line per request.
 func CtxWithLogger(loggerEntry *zap.Logger) func(next http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			ctx := logger.ToContext(r.Context(), loggerEntry)
-			next.ServeHTTP(w, r.WithContext(ctx))
+			start := time.Now()
+
+			requestID := r.Header.Get(handler.RequestIDHeader)
+			if !requestIDPattern.MatchString(requestID) {
+				requestID = handler.NewRequestID()
+			}
+			w.Header().Set(handler.RequestIDHeader, requestID)
+
+			fields := []zap.Field{
+				zap.String("request_id", requestID),
+				zap.String("route", routeTemplate(r)),
+				zap.String("method", r.Method),
+			}
+			if chain, ok := mux.Vars(r)["chain"]; ok {
+				fields = append(fields, zap.String("chain", chain))
+			}
+
+			ctx := logger.ToContext(r.Context(), loggerEntry.With(fields...))
+			rec := newResponseRecorder(w)
+			next.ServeHTTP(rec, r.WithContext(ctx))
+
+			logger.Extract(ctx).Info("request served",
+				zap.String("path", r.URL.Path),
+				zap.Int("status", rec.status),
+				zap.Int("bytes", rec.bytes),
+				zap.Duration("duration", time.Since(start)),
+			)
 		})
 	}
 }
@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/aisalamdag23/etherstats/internal/infrastructure/metrics"
+	"github.com/gorilla/mux"
+)
+
+// Metrics is a middleware that counts requests and observes their latency per route and status.
+// Routes are labelled by their template so path variables do not blow up the label cardinality.
+// Streams are observed apart, their duration is how long clients stay connected rather than a latency.
+func Metrics() func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			rec := newResponseRecorder(w)
+			next.ServeHTTP(rec, r)
+
+			route := routeTemplate(r)
+			status := strconv.Itoa(rec.status)
+			metrics.HTTPRequests.WithLabelValues(route, r.Method, status).Inc()
+			if rec.streaming {
+				metrics.HTTPStreamDuration.WithLabelValues(route).Observe(metrics.Since(start))
+				return
+			}
+			metrics.HTTPRequestDuration.WithLabelValues(route, r.Method, status).Observe(metrics.Since(start))
+		})
+	}
+}
+
+// routeTemplate returns the path template of the matched route, e.g. /api/v1/eth/{id}.
+func routeTemplate(r *http.Request) string {
+	if current := mux.CurrentRoute(r); current != nil {
+		if tpl, err := current.GetPathTemplate(); err == nil {
+			return tpl
+		}
+	}
+	return "unmatched"
+}
@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// responseRecorder remembers the status code and body size written by the next handler
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+	// streaming is set for Server-Sent Events and hijacked connections, e.g. WebSockets
+	streaming bool
+}
+
+func newResponseRecorder(w http.ResponseWriter) *responseRecorder {
+	return &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+}
+
+func (w *responseRecorder) WriteHeader(status int) {
+	w.status = status
+	w.streaming = w.streaming || strings.HasPrefix(w.Header().Get("Content-Type"), "text/event-stream")
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *responseRecorder) Write(b []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += n
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer, e.g. to flush or hijack.
+func (w *responseRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Hijack hands the connection over to the handler, for WebSocket libraries that assert
+// http.Hijacker rather than going through http.ResponseController.
+// The handshake is answered on the raw connection, so it is recorded as switching protocols.
+func (w *responseRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(w.ResponseWriter).Hijack()
+	if err == nil {
+		w.status, w.streaming = http.StatusSwitchingProtocols, true
+	}
+	return conn, rw, err
+}
@@ -8,11 +8,16 @@ import (
 	"os/signal"
 	"time"
 
+	"github.com/aisalamdag23/etherstats/internal/handler"
 	"github.com/aisalamdag23/etherstats/internal/infrastructure/config"
+	"github.com/aisalamdag23/etherstats/internal/infrastructure/metrics"
 	"github.com/aisalamdag23/etherstats/internal/infrastructure/protocol/rest/middleware"
 	"github.com/aisalamdag23/etherstats/internal/infrastructure/registry"
+	"github.com/aisalamdag23/etherstats/internal/infrastructure/tracing"
 	"github.com/gorilla/mux"
 	"github.com/rs/cors"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gorilla/mux/otelmux"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	"go.uber.org/zap"
 )
 
@@ -23,10 +28,29 @@ func RunServer(ctx context.Context, cfg *config.Config, logger *zap.Logger) erro
 		return err
 	}
 
+	// ctx bounds the background workers, it is cancelled on shutdown
+	ctx, stop := context.WithCancel(ctx)
+	defer stop()
+
+	// spans are only recorded when tracing is enabled, the global provider is a no-op otherwise
+	var tp *sdktrace.TracerProvider
+	if cfg.Tracing.Enabled {
+		exporter, err := tracing.NewExporter(ctx, cfg.Tracing)
+		if err != nil {
+			return fmt.Errorf("failed to create span exporter: %w", err)
+		}
+		tp = tracing.Init(cfg.General.AppName, cfg.Tag, cfg.Tracing, exporter)
+	}
+
 	reg := registry.Init(ctx, cfg, logger)
 
 	r := mux.NewRouter()
+	// the server span goes first so the context logger and metrics see it
+	r.Use(otelmux.Middleware(cfg.General.AppName))
 	r.Use(middleware.CtxWithLogger(logger))
+	r.Use(middleware.Metrics())
+
+	r.Handle("/metrics", metrics.Handler()).Methods(http.MethodGet)
 
 	v1 := r.PathPrefix("/api/v1").Subrouter()
 
@@ -37,25 +61,58 @@ func RunServer(ctx context.Context, cfg *config.Config, logger *zap.Logger) erro
 
 	ethServer.RegisterRoutes(v1)
 
+	if cfg.Webhooks.Enabled {
+		reg.CreateWebhookServer().RegisterRoutes(v1)
+	}
+
+	// probes are served outside of the versioned API, after the servers whose dependencies they check
+	reg.CreateHealthServer().RegisterRoutes(r)
+
 	// add auth and routes here - start
+	if cfg.Auth.Enabled {
+		// every API route requires a key, probes and metrics stay open
+		auth := middleware.Auth(reg.CreateAuthService(), reg.ChainAliases())
+		v1.Use(auth)
+		if cfg.Stream.Enabled {
+			// browsers open streams with a token rather than the key
+			reg.CreateAuthServer().RegisterRoutes(v1)
+		}
 
+		// keys are managed by admin keys only
+		admin := r.PathPrefix("/api/admin").Subrouter()
+		admin.Use(auth, middleware.RequireAdmin)
+		reg.CreateAdminServer().RegisterRoutes(admin)
+	}
 	// add auth and routes here - end
 
+	// keep the cache warm in the background so requests only read it
+	reg.StartWorkers()
+
+	allowedOrigins := cfg.CORS.AllowedOrigins
+	if len(allowedOrigins) == 0 {
+		allowedOrigins = []string{"*"}
+	}
+
 	// CORS control
+	// API keys travel in headers rather than cookies, so credentials are not allowed
 	cor := cors.New(cors.Options{
-		AllowedOrigins:   []string{"*"},
-		AllowCredentials: true,
-		AllowedMethods:   []string{http.MethodGet, http.MethodPost, http.MethodHead, http.MethodPut},
+		AllowedOrigins: allowedOrigins,
+		AllowedMethods: []string{http.MethodGet, http.MethodPost, http.MethodHead, http.MethodPut, http.MethodPatch, http.MethodDelete},
+		AllowedHeaders: []string{"Authorization", "Content-Type", middleware.APIKeyHeader, handler.RequestIDHeader},
+		ExposedHeaders: []string{
+			"RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "Retry-After",
+			"Warning", handler.RequestIDHeader,
+		},
 	})
 	// This inserts the middleware
-	handler := cor.Handler(r)
+	h := cor.Handler(r)
 
 	srv := &http.Server{
 		Addr:         cfg.General.HTTPAddr,
 		WriteTimeout: time.Second * time.Duration(cfg.General.WriteTimeoutSec),
 		ReadTimeout:  time.Second * time.Duration(cfg.General.ReadTimeoutSec),
 		IdleTimeout:  time.Second * time.Duration(cfg.General.IdleTimeoutSec),
-		Handler:      handler,
+		Handler:      h,
 	}
 
 	// Accept graceful shutdowns when quit via SIGINT (Ctrl+C)
@@ -73,12 +130,17 @@ func RunServer(ctx context.Context, cfg *config.Config, logger *zap.Logger) erro
 	// Block until the signal.
 	<-c
 	logger.Info("shutting down server...")
+	stop()
 	// Create a deadline to wait for.
-	ctx, cancel := context.WithTimeout(context.Background(), wait)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), wait)
 	defer cancel()
 	// Doesn't block if no connections, but will otherwise wait
 	// until the timeout deadline.
-	_ = srv.Shutdown(ctx)
+	_ = srv.Shutdown(shutdownCtx)
+	if tp != nil {
+		// flush the spans still batched
+		_ = tp.Shutdown(shutdownCtx)
+	}
 
 	logger.Info("shutdown complete")
 	os.Exit(0)
@@ -0,0 +1,124 @@
+package pubsub
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	"github.com/aisalamdag23/etherstats/internal/domain"
+	"github.com/aisalamdag23/etherstats/internal/infrastructure/logger"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+const (
+	// subscriberBuffer is how many heads a subscriber may lag behind before heads are dropped for it
+	subscriberBuffer = 16
+)
+
+type redisFeed struct {
+	client  *redis.Client
+	channel string
+	lgr     *zap.Logger
+
+	mu          sync.Mutex
+	subscribers map[chan domain.HeadEvent]struct{}
+	// stopped is set once Run returns, later subscribers get a closed channel
+	stopped bool
+}
+
+// NewRedis creates a head feed shared by every replica through a Redis pub/sub channel.
+// Each replica holds a single Redis subscription, relayed to its own subscribers.
+func NewRedis(client *redis.Client, channel string, lgr *zap.Logger) domain.HeadFeed {
+	return &redisFeed{
+		client:      client,
+		channel:     channel,
+		lgr:         lgr,
+		subscribers: make(map[chan domain.HeadEvent]struct{}),
+	}
+}
+
+// Publish sends a head to the subscribers of every replica.
+func (f *redisFeed) Publish(ctx context.Context, head domain.HeadEvent) error {
+	val, err := json.Marshal(head)
+	if err != nil {
+		return err
+	}
+
+	return f.client.Publish(ctx, f.channel, val).Err()
+}
+
+// Subscribe registers a subscriber of this replica.
+func (f *redisFeed) Subscribe() (<-chan domain.HeadEvent, func()) {
+	ch := make(chan domain.HeadEvent, subscriberBuffer)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.stopped {
+		close(ch)
+		return ch, func() {}
+	}
+	f.subscribers[ch] = struct{}{}
+
+	return ch, func() {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		if _, ok := f.subscribers[ch]; ok {
+			delete(f.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// Run relays the heads published to the channel to the subscribers of this replica until ctx is done,
+// then closes every subscriber. The Redis client reconnects the subscription on its own.
+func (f *redisFeed) Run(ctx context.Context) error {
+	ctx = logger.ToContext(ctx, f.lgr)
+	sub := f.client.Subscribe(ctx, f.channel)
+	defer func() { _ = sub.Close() }()
+	defer f.stop()
+
+	messages := sub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case msg, ok := <-messages:
+			if !ok {
+				return nil
+			}
+			var head domain.HeadEvent
+			if err := json.Unmarshal([]byte(msg.Payload), &head); err != nil {
+				logger.Extract(ctx).Error("failed to decode published head", zap.Error(err))
+				continue
+			}
+			f.broadcast(ctx, head)
+		}
+	}
+}
+
+// broadcast hands a head to every subscriber without waiting on any of them.
+func (f *redisFeed) broadcast(ctx context.Context, head domain.HeadEvent) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for ch := range f.subscribers {
+		select {
+		case ch <- head:
+		default:
+			logger.Extract(ctx).Warn("stream subscriber is behind, dropping head", zap.Uint64("number", head.Number))
+		}
+	}
+}
+
+// stop closes every subscriber, so the streams end along with the feed.
+func (f *redisFeed) stop() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.stopped = true
+	for ch := range f.subscribers {
+		delete(f.subscribers, ch)
+		close(ch)
+	}
+}
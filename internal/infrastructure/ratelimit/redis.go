@@ -0,0 +1,173 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aisalamdag23/etherstats/internal/domain"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// quotaKeyTTL keeps a day's quota counter a little past the end of the day
+	quotaKeyTTL = 48 * time.Hour
+	// usageKeyTTL keeps a day's usage long enough to be aggregated even after an outage
+	usageKeyTTL = 8 * 24 * time.Hour
+	// dayLayout formats the UTC day of quota and usage keys
+	dayLayout = "20060102"
+)
+
+// allowScript checks the daily quota, then takes a token from the bucket, refilled at the rate
+// since its last update. Allowed requests are counted against the quota and metered per route.
+// Time comes from Redis so replicas with skewed clocks share the same bucket.
+//
+// KEYS: bucket, quota counter, usage hash
+// ARGV: rate per second, burst, daily quota (0 is unlimited), quota TTL, usage TTL, route
+// Returns: allowed (0/1), quota exceeded (0/1), tokens left, ms until next token, requests today
+var allowScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local quota = tonumber(ARGV[3])
+
+local used = tonumber(redis.call("GET", KEYS[2]) or "0")
+if quota > 0 and used >= quota then
+	return {0, 1, 0, 0, used}
+end
+
+local t = redis.call("TIME")
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+local bucket = redis.call("HMGET", KEYS[1], "tokens", "ts")
+local tokens = tonumber(bucket[1]) or burst
+local ts = tonumber(bucket[2]) or now
+tokens = math.min(burst, tokens + math.max(0, now - ts) * rate / 1000)
+
+if tokens < 1 then
+	redis.call("HSET", KEYS[1], "tokens", tostring(tokens), "ts", now)
+	return {0, 0, 0, math.ceil((1 - tokens) * 1000 / rate), used}
+end
+
+tokens = tokens - 1
+redis.call("HSET", KEYS[1], "tokens", tostring(tokens), "ts", now)
+redis.call("PEXPIRE", KEYS[1], math.ceil(burst * 1000 / rate) + 1000)
+
+used = redis.call("INCR", KEYS[2])
+if used == 1 then
+	redis.call("EXPIRE", KEYS[2], ARGV[4])
+end
+redis.call("HINCRBY", KEYS[3], ARGV[6], 1)
+redis.call("EXPIRE", KEYS[3], ARGV[5])
+
+local wait = 0
+if tokens < 1 then
+	wait = math.ceil((1 - tokens) * 1000 / rate)
+end
+return {1, 0, math.floor(tokens), wait, used}`)
+
+type redisLimiter struct {
+	client *redis.Client
+}
+
+// Limiter is a rate limiter that also meters usage
+type Limiter interface {
+	domain.RateLimiter
+	domain.UsageMeter
+}
+
+// NewRedis creates a rate limiter keeping the token bucket, daily quota counter
+// and usage of every API key in Redis, so limits hold across replicas.
+func NewRedis(client *redis.Client) Limiter {
+	return &redisLimiter{
+		client: client,
+	}
+}
+
+// Allow takes a token from the bucket of key and counts the request against its daily quota.
+func (l *redisLimiter) Allow(ctx context.Context, key domain.APIKey, route string) (*domain.RateLimit, error) {
+	now := time.Now().UTC()
+	day := now.Format(dayLayout)
+	keys := []string{
+		fmt.Sprintf("apikey:%d:bucket", key.ID),
+		QuotaKey(key.ID, day),
+		UsageKey(key.ID, day),
+	}
+
+	res, err := allowScript.Run(ctx, l.client, keys,
+		key.RatePerSec, key.Burst, key.DailyQuota,
+		int(quotaKeyTTL.Seconds()), int(usageKeyTTL.Seconds()), route,
+	).Int64Slice()
+	if err != nil {
+		return nil, domain.NewError(domain.KindUpstreamUnavailable, err, "failed to check rate limit")
+	}
+	allowed, quotaExceeded, tokens, waitMs, used := res[0] == 1, res[1] == 1, int(res[2]), res[3], int(res[4])
+
+	limit := &domain.RateLimit{
+		Allowed:       allowed,
+		QuotaExceeded: quotaExceeded,
+		Limit:         key.Burst,
+		Remaining:     tokens,
+		Reset:         time.Duration(waitMs) * time.Millisecond,
+	}
+	// report the quota instead of the rate when it is closer to exhaustion
+	if key.DailyQuota > 0 && (quotaExceeded || key.DailyQuota-used < tokens) {
+		limit.Limit = key.DailyQuota
+		limit.Remaining = max(0, key.DailyQuota-used)
+		limit.Reset = now.Truncate(24 * time.Hour).Add(24 * time.Hour).Sub(now)
+	}
+
+	return limit, nil
+}
+
+// Usage returns the requests of every API key per route and UTC day held in Redis,
+// which keeps them for usageKeyTTL.
+func (l *redisLimiter) Usage(ctx context.Context) ([]domain.APIKeyUsage, error) {
+	var usage []domain.APIKeyUsage
+
+	iter := l.client.Scan(ctx, 0, "apikey:*:usage:*", 1000).Iterator()
+	for iter.Next(ctx) {
+		var (
+			keyID int64
+			day   string
+		)
+		if _, err := fmt.Sscanf(iter.Val(), "apikey:%d:usage:%s", &keyID, &day); err != nil {
+			continue
+		}
+		date, err := time.Parse(dayLayout, day)
+		if err != nil {
+			continue
+		}
+
+		routes, err := l.client.HGetAll(ctx, iter.Val()).Result()
+		if err != nil {
+			return nil, domain.NewError(domain.KindUpstreamUnavailable, err, "failed to read usage")
+		}
+		for route, val := range routes {
+			requests, err := strconv.ParseInt(val, 10, 64)
+			if err != nil {
+				continue
+			}
+			usage = append(usage, domain.APIKeyUsage{
+				APIKeyID: keyID,
+				Day:      date,
+				Route:    route,
+				Requests: requests,
+			})
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return nil, domain.NewError(domain.KindUpstreamUnavailable, err, "failed to scan usage")
+	}
+
+	return usage, nil
+}
+
+// QuotaKey is the Redis key counting the requests of an API key on a UTC day (yyyymmdd).
+func QuotaKey(keyID int64, day string) string {
+	return fmt.Sprintf("apikey:%d:quota:%s", keyID, day)
+}
+
+// UsageKey is the Redis hash counting the requests of an API key per route on a UTC day (yyyymmdd).
+func UsageKey(keyID int64, day string) string {
+	return fmt.Sprintf("apikey:%d:usage:%s", keyID, day)
+}
@@ -0,0 +1,129 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aisalamdag23/etherstats/internal/domain"
+	"github.com/redis/go-redis/v9"
+)
+
+// openScript drops the slots that were not renewed in time, then takes one for the stream
+// unless every slot is held. Time comes from Redis, like in allowScript.
+//
+// KEYS: slots sorted set, scored by expiry in ms
+// ARGV: stream ID, slots per key, slot TTL in ms
+// Returns: opened (0/1)
+var openScript = redis.NewScript(`
+local t = redis.call("TIME")
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+redis.call("ZREMRANGEBYSCORE", KEYS[1], "-inf", now)
+
+if not redis.call("ZSCORE", KEYS[1], ARGV[1]) and redis.call("ZCARD", KEYS[1]) >= tonumber(ARGV[2]) then
+	return 0
+end
+
+redis.call("ZADD", KEYS[1], now + tonumber(ARGV[3]), ARGV[1])
+redis.call("PEXPIRE", KEYS[1], ARGV[3])
+return 1`)
+
+// renewScript pushes back the expiry of a slot still held.
+//
+// KEYS: slots sorted set
+// ARGV: stream ID, slot TTL in ms
+var renewScript = redis.NewScript(`
+local t = redis.call("TIME")
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+redis.call("ZADD", KEYS[1], "XX", now + tonumber(ARGV[2]), ARGV[1])
+redis.call("PEXPIRE", KEYS[1], ARGV[2])
+return 1`)
+
+// chargeScript counts requests against the daily quota unless it is exhausted,
+// and meters them per route like allowScript.
+//
+// KEYS: quota counter, usage hash
+// ARGV: requests, daily quota (0 is unlimited), quota TTL, usage TTL, route
+// Returns: charged (0/1)
+var chargeScript = redis.NewScript(`
+local quota = tonumber(ARGV[2])
+local used = tonumber(redis.call("GET", KEYS[1]) or "0")
+if quota > 0 and used >= quota then
+	return 0
+end
+
+used = redis.call("INCRBY", KEYS[1], ARGV[1])
+if used == tonumber(ARGV[1]) then
+	redis.call("EXPIRE", KEYS[1], ARGV[3])
+end
+redis.call("HINCRBY", KEYS[2], ARGV[5], ARGV[1])
+redis.call("EXPIRE", KEYS[2], ARGV[4])
+return 1`)
+
+type redisStreams struct {
+	client    *redis.Client
+	maxPerKey int
+	ttl       time.Duration
+}
+
+// NewRedisStreams creates a stream limiter allowing maxPerKey concurrent streams per API key.
+// Slots are kept in Redis so the cap holds across replicas, a slot not renewed within ttl,
+// such as that of a replica gone down, is freed.
+func NewRedisStreams(client *redis.Client, maxPerKey int, ttl time.Duration) domain.StreamLimiter {
+	return &redisStreams{
+		client:    client,
+		maxPerKey: maxPerKey,
+		ttl:       ttl,
+	}
+}
+
+// Open takes a stream slot of key.
+func (l *redisStreams) Open(ctx context.Context, key domain.APIKey, streamID string) error {
+	opened, err := openScript.Run(ctx, l.client, []string{streamsKey(key.ID)},
+		streamID, l.maxPerKey, l.ttl.Milliseconds(),
+	).Int()
+	if err != nil {
+		return domain.NewError(domain.KindUpstreamUnavailable, err, "failed to open stream")
+	}
+	if opened == 0 {
+		return domain.NewError(domain.KindRateLimited, nil, "at most %d concurrent streams per api key", l.maxPerKey)
+	}
+
+	return nil
+}
+
+// Renew keeps the slot of a stream held for another ttl.
+func (l *redisStreams) Renew(ctx context.Context, key domain.APIKey, streamID string) error {
+	if err := renewScript.Run(ctx, l.client, []string{streamsKey(key.ID)}, streamID, l.ttl.Milliseconds()).Err(); err != nil {
+		return domain.NewError(domain.KindUpstreamUnavailable, err, "failed to renew stream")
+	}
+
+	return nil
+}
+
+// Close frees the slot of a stream.
+func (l *redisStreams) Close(ctx context.Context, key domain.APIKey, streamID string) error {
+	if err := l.client.ZRem(ctx, streamsKey(key.ID), streamID).Err(); err != nil {
+		return domain.NewError(domain.KindUpstreamUnavailable, err, "failed to close stream")
+	}
+
+	return nil
+}
+
+// Charge counts n requests against the daily quota of key and meters them on route.
+func (l *redisStreams) Charge(ctx context.Context, key domain.APIKey, route string, n int) (bool, error) {
+	day := time.Now().UTC().Format(dayLayout)
+	charged, err := chargeScript.Run(ctx, l.client, []string{QuotaKey(key.ID, day), UsageKey(key.ID, day)},
+		n, key.DailyQuota, int(quotaKeyTTL.Seconds()), int(usageKeyTTL.Seconds()), route,
+	).Int()
+	if err != nil {
+		return false, domain.NewError(domain.KindUpstreamUnavailable, err, "failed to charge stream")
+	}
+
+	return charged == 1, nil
+}
+
+// streamsKey is the Redis sorted set holding the stream slots of an API key.
+func streamsKey(keyID int64) string {
+	return fmt.Sprintf("apikey:%d:streams", keyID)
+}
@@ -3,33 +3,68 @@ package registry
 import (
 	"context"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/aisalamdag23/etherstats/internal/domain"
 	"github.com/aisalamdag23/etherstats/internal/handler"
+	adminhttp "github.com/aisalamdag23/etherstats/internal/handler/admin"
+	authhttp "github.com/aisalamdag23/etherstats/internal/handler/auth"
 	ethhttp "github.com/aisalamdag23/etherstats/internal/handler/eth/v1"
+	healthhttp "github.com/aisalamdag23/etherstats/internal/handler/health"
+	webhookhttp "github.com/aisalamdag23/etherstats/internal/handler/webhook"
+	"github.com/aisalamdag23/etherstats/internal/infrastructure/coalesce"
 	"github.com/aisalamdag23/etherstats/internal/infrastructure/config"
+	"github.com/aisalamdag23/etherstats/internal/infrastructure/election"
+	"github.com/aisalamdag23/etherstats/internal/infrastructure/pubsub"
+	"github.com/aisalamdag23/etherstats/internal/infrastructure/ratelimit"
 	"github.com/aisalamdag23/etherstats/internal/infrastructure/sql"
 	"github.com/aisalamdag23/etherstats/internal/infrastructure/sql/postgres"
+	apikeydb "github.com/aisalamdag23/etherstats/internal/storage/db/apikey"
 	ethdb "github.com/aisalamdag23/etherstats/internal/storage/db/eth"
+	webhookdb "github.com/aisalamdag23/etherstats/internal/storage/db/webhook"
+	adminsvc "github.com/aisalamdag23/etherstats/internal/usecase/admin"
 	alchemysvc "github.com/aisalamdag23/etherstats/internal/usecase/alchemy"
+	authsvc "github.com/aisalamdag23/etherstats/internal/usecase/auth"
 	ethsvc "github.com/aisalamdag23/etherstats/internal/usecase/eth"
+	healthsvc "github.com/aisalamdag23/etherstats/internal/usecase/health"
+	"github.com/aisalamdag23/etherstats/internal/usecase/provider"
+	webhooksvc "github.com/aisalamdag23/etherstats/internal/usecase/webhook"
 	"github.com/jmoiron/sqlx"
+	"github.com/redis/go-redis/extra/redisotel/v9"
 	"github.com/redis/go-redis/v9"
 	"go.uber.org/zap"
 )
 
+const (
+	// webhookBatchSize is how many due webhook deliveries a replica attempts per dispatch
+	webhookBatchSize = 50
+	// mainnetChainID is the ID of Ethereum mainnet, home of the ENS registry
+	mainnetChainID = 1
+	// streamSlotTTL frees the stream slots of a replica gone down, live streams renew theirs far more often
+	streamSlotTTL = 2 * time.Minute
+)
+
 // Registry is the factory that creates all the "feature servers"
 type Registry struct {
+	// ctx bounds the background work started by the servers
+	ctx     context.Context
 	cfg     *config.Config
 	db      *sqlx.DB
 	redisDB *redis.Client
 	logger  *zap.Logger
+	// workers are the background processes created along the servers
+	workers []domain.Worker
+	// checks are the readiness checks of the dependencies created along the servers
+	checks []healthsvc.Check
 }
 
 // Init instantiates the registry for API
 // - creates database connection pool
 func Init(ctx context.Context, cfg *config.Config, logger *zap.Logger) *Registry {
 	registry := &Registry{
+		ctx:    ctx,
 		cfg:    cfg,
 		logger: logger,
 	}
@@ -42,6 +77,11 @@ func Init(ctx context.Context, cfg *config.Config, logger *zap.Logger) *Registry
 
 	registry.db = database
 
+	// sqlx.Open is lazy, make sure the database is reachable before serving
+	if err := database.PingContext(ctx); err != nil {
+		logger.Fatal("failed to ping postgres", zap.Error(err))
+	}
+
 	// create a connection to redis
 	redisDB, err := registry.createRedisDB(ctx)
 	if err != nil {
@@ -52,15 +92,225 @@ func Init(ctx context.Context, cfg *config.Config, logger *zap.Logger) *Registry
 	return registry
 }
 
+// CreateETHServer creates the eth handler with one service per configured chain.
 func (r *Registry) CreateETHServer() (handler.Handler, error) {
-	repository := ethdb.NewRepository(r.db, r.redisDB, time.Second*time.Duration(r.cfg.Alchemy.CacheTTLSec))
-	alchemySvc, err := alchemysvc.NewService(r.cfg.Alchemy.MainNetURL, r.cfg.Alchemy.APIKey)
-	if err != nil {
-		return nil, err
+	services := make(map[string]domain.Service, len(r.cfg.Chains)*2)
+	opts := ethsvc.Options{
+		MaxStaleness: time.Second * time.Duration(r.cfg.Staleness.MaxSec),
+		CallTimeout:  time.Millisecond * time.Duration(r.cfg.RPC.CallTimeoutMs),
 	}
-	svc := ethsvc.NewService(repository, alchemySvc, r.logger)
+	providers := make([]domain.AlchemyAPIService, len(r.cfg.Chains))
+	// ENS names of every chain are resolved on mainnet, where the registry lives
+	var ensResolver domain.ENSResolver
+	for i, chain := range r.cfg.Chains {
+		providerSvc, err := r.createProvider(chain, r.logger.With(zap.String("chain", chain.Name)))
+		if err != nil {
+			return nil, fmt.Errorf("chain %s: %w", chain.Name, err)
+		}
+		providers[i] = providerSvc
+		if chain.ChainID == mainnetChainID {
+			ensResolver = providerSvc
+		}
+	}
+	for i, chain := range r.cfg.Chains {
+		repository := ethdb.NewRepository(r.db, r.redisDB, chain.ChainID, time.Second*time.Duration(chain.CacheTTLSec), opts.MaxStaleness)
+		lgr := r.logger.With(zap.String("chain", chain.Name))
+		providerSvc := providers[i]
+		indexRepository := ethdb.NewIndexRepository(r.db, chain.ChainID)
+		chainOpts := opts
+		chainOpts.FinalityDepth = chain.FinalityDepth
+		var headFeed domain.HeadFeed
+		if r.cfg.Stream.Enabled {
+			headFeed = pubsub.NewRedis(r.redisDB, fmt.Sprintf("chain:%d:heads", chain.ChainID), lgr)
+			elector := election.NewRedis(r.redisDB, fmt.Sprintf("chain:%d:heads:leader", chain.ChainID),
+				time.Second*time.Duration(r.cfg.Stream.LeaderTTLSec))
+			r.workers = append(r.workers, headFeed, ethsvc.NewHeadPublisher(chain.ChainID, providerSvc, headFeed, elector,
+				time.Second*time.Duration(r.cfg.Stream.PollIntervalSec), lgr))
+		}
+		svc := ethsvc.NewService(repository, indexRepository, providerSvc, ensResolver, r.createCoalescer(chain), headFeed, chainOpts)
+		r.checks = append(r.checks, healthsvc.Check{
+			Name:  "rpc:" + chain.Name,
+			Check: healthsvc.RPCCheck(providerSvc, chain.ChainID, time.Second*time.Duration(r.cfg.Health.MaxHeadAgeSec)),
+		})
+		if r.cfg.Poller.Enabled {
+			r.workers = append(r.workers, ethsvc.NewPoller(repository, providerSvc, time.Second*time.Duration(r.cfg.Poller.IntervalSec), lgr))
+		}
+		if r.cfg.Indexer.Enabled {
+			r.workers = append(r.workers, ethsvc.NewIndexer(indexRepository, providerSvc, ethsvc.IndexerOptions{
+				Interval:        time.Second * time.Duration(r.cfg.Indexer.IntervalSec),
+				MaxBlocksPerRun: r.cfg.Indexer.MaxBlocksPerRun,
+				MaxReorgDepth:   chain.FinalityDepth,
+			}, lgr))
+		}
+		if r.cfg.Webhooks.Enabled && r.cfg.Webhooks.MonitorEnabled {
+			r.workers = append(r.workers, webhooksvc.NewMonitor(chain.ChainID, r.createWebhookRepository(), repository, providerSvc, webhooksvc.MonitorOptions{
+				Interval:        time.Second * time.Duration(r.cfg.Webhooks.MonitorIntervalSec),
+				MaxBlocksPerRun: r.cfg.Webhooks.MaxBlocksPerRun,
+			}, lgr))
+		}
 
-	return ethhttp.NewServer(svc), nil
+		// routes accept either the chain name or the chain ID
+		services[strings.ToLower(chain.Name)] = svc
+		services[strconv.FormatUint(chain.ChainID, 10)] = svc
+	}
+
+	// streams are capped and metered per API key, which only exist with auth
+	var authService domain.AuthService
+	if r.cfg.Auth.Enabled {
+		authService = r.CreateAuthService()
+	}
+
+	return ethhttp.NewServer(services, r.cfg.Chains[0].Name, authService), nil
+}
+
+// CreateHealthServer creates the liveness and readiness handler.
+// It checks Postgres, Redis and the provider of every chain created so far.
+func (r *Registry) CreateHealthServer() handler.Handler {
+	checks := append([]healthsvc.Check{
+		{Name: "postgres", Check: healthsvc.PingCheck("postgres", r.db.PingContext)},
+		{Name: "redis", Check: healthsvc.PingCheck("redis", func(ctx context.Context) error {
+			return r.redisDB.Ping(ctx).Err()
+		})},
+	}, r.checks...)
+
+	svc := healthsvc.NewService(checks, time.Millisecond*time.Duration(r.cfg.Health.TimeoutMs))
+	return healthhttp.NewServer(svc)
+}
+
+// CreateAuthService creates the service authenticating API keys and enforcing their limits in Redis.
+func (r *Registry) CreateAuthService() domain.AuthService {
+	return authsvc.NewService(r.createAPIKeyRepository(), ratelimit.NewRedis(r.redisDB),
+		ratelimit.NewRedisStreams(r.redisDB, r.cfg.Stream.MaxPerKey, streamSlotTTL))
+}
+
+// CreateAuthServer creates the handler issuing stream tokens, it goes behind the Auth middleware.
+func (r *Registry) CreateAuthServer() handler.Handler {
+	return authhttp.NewServer(r.CreateAuthService())
+}
+
+// CreateAdminService creates the service managing API keys and aggregating their usage.
+func (r *Registry) CreateAdminService() domain.AdminService {
+	chains := make([]string, 0, len(r.cfg.Chains))
+	for _, chain := range r.cfg.Chains {
+		chains = append(chains, chain.Name)
+	}
+
+	return adminsvc.NewService(r.createAPIKeyRepository(), ratelimit.NewRedis(r.redisDB), adminsvc.Options{
+		Chains:            chains,
+		DefaultRatePerSec: r.cfg.Auth.DefaultRatePerSec,
+		DefaultBurst:      r.cfg.Auth.DefaultBurst,
+		DefaultDailyQuota: r.cfg.Auth.DefaultDailyQuota,
+	})
+}
+
+// CreateAdminServer creates the API key admin handler.
+// Usage counters are aggregated into Postgres in the background along with it.
+func (r *Registry) CreateAdminServer() handler.Handler {
+	svc := r.CreateAdminService()
+	if r.cfg.Auth.UsageSyncIntervalSec > 0 {
+		interval := time.Second * time.Duration(r.cfg.Auth.UsageSyncIntervalSec)
+		r.workers = append(r.workers, adminsvc.NewUsageAggregator(svc, interval, r.logger))
+	}
+
+	return adminhttp.NewServer(svc)
+}
+
+// CreateWebhookServer creates the webhook handler.
+// Due deliveries are dispatched in the background along with it.
+func (r *Registry) CreateWebhookServer() handler.Handler {
+	repository := r.createWebhookRepository()
+
+	chains := make(map[string]uint64, len(r.cfg.Chains)*2+1)
+	for _, chain := range r.cfg.Chains {
+		chains[strings.ToLower(chain.Name)] = chain.ChainID
+		chains[strconv.FormatUint(chain.ChainID, 10)] = chain.ChainID
+	}
+	chains[""] = r.cfg.Chains[0].ChainID
+
+	timeout := time.Millisecond * time.Duration(r.cfg.Webhooks.TimeoutMs)
+	r.workers = append(r.workers, webhooksvc.NewDispatcher(repository, webhooksvc.NewClient(timeout), webhooksvc.DispatcherOptions{
+		Interval:    time.Second * time.Duration(r.cfg.Webhooks.DispatchIntervalSec),
+		BatchSize:   webhookBatchSize,
+		MaxAttempts: r.cfg.Webhooks.MaxAttempts,
+		RetryBase:   time.Second * time.Duration(r.cfg.Webhooks.RetryBaseSec),
+		RetryMax:    time.Second * time.Duration(r.cfg.Webhooks.RetryMaxSec),
+		// every delivery of a batch may take the whole timeout
+		Lease: timeout*webhookBatchSize + time.Minute,
+	}, r.logger))
+
+	return webhookhttp.NewServer(webhooksvc.NewService(repository, chains))
+}
+
+// ChainAliases maps the lowercase names and IDs of the configured chains, and "" for the
+// default chain, to the chain name.
+func (r *Registry) ChainAliases() map[string]string {
+	aliases := make(map[string]string, len(r.cfg.Chains)*2+1)
+	for _, chain := range r.cfg.Chains {
+		aliases[strings.ToLower(chain.Name)] = chain.Name
+		aliases[strconv.FormatUint(chain.ChainID, 10)] = chain.Name
+	}
+	aliases[""] = r.cfg.Chains[0].Name
+
+	return aliases
+}
+
+// StartWorkers runs the background workers created along the servers until the registry context is done.
+func (r *Registry) StartWorkers() {
+	for _, w := range r.workers {
+		go func() {
+			if err := w.Run(r.ctx); err != nil {
+				r.logger.Error("worker stopped", zap.Error(err))
+			}
+		}()
+	}
+}
+
+// createAPIKeyRepository creates the API key repository, caching keys in Redis.
+func (r *Registry) createAPIKeyRepository() domain.APIKeyRepository {
+	return apikeydb.NewRepository(r.db, r.redisDB, time.Second*time.Duration(r.cfg.Auth.KeyCacheTTLSec))
+}
+
+// createWebhookRepository creates the repository of webhooks and their deliveries.
+func (r *Registry) createWebhookRepository() domain.WebhookRepository {
+	return webhookdb.NewRepository(r.db)
+}
+
+// createCoalescer creates the coalescer of a chain's cache misses.
+func (r *Registry) createCoalescer(chain config.Chain) domain.Coalescer {
+	if r.cfg.Coalescing.Mode != "redis" {
+		return coalesce.NewLocal()
+	}
+
+	return coalesce.NewRedis(r.redisDB, fmt.Sprintf("chain:%d", chain.ChainID), coalesce.RedisOptions{
+		LockTTL:      time.Millisecond * time.Duration(r.cfg.Coalescing.LockTTLMs),
+		PollInterval: time.Millisecond * time.Duration(r.cfg.Coalescing.PollIntervalMs),
+	})
+}
+
+// createProvider creates the JSON-RPC provider of a chain, failing over across its endpoints.
+// Chains without endpoints are served by Alchemy alone.
+func (r *Registry) createProvider(chain config.Chain, lgr *zap.Logger) (domain.AlchemyAPIService, error) {
+	endpoints := make([]provider.Endpoint, 0, len(chain.Endpoints))
+	if len(chain.Endpoints) == 0 {
+		svc, err := alchemysvc.NewService(chain.RPCURL, r.cfg.Alchemy.APIKey)
+		if err != nil {
+			return nil, err
+		}
+		endpoints = append(endpoints, provider.Endpoint{Name: "alchemy", Service: svc})
+	}
+	for _, ep := range chain.Endpoints {
+		svc, err := alchemysvc.NewServiceFromURL(ep.URL)
+		if err != nil {
+			return nil, fmt.Errorf("endpoint %s: %w", ep.Name, err)
+		}
+		endpoints = append(endpoints, provider.Endpoint{Name: ep.Name, Service: svc})
+	}
+
+	return provider.NewService(r.ctx, endpoints, provider.Options{
+		RequestTimeout:      time.Millisecond * time.Duration(r.cfg.RPC.RequestTimeoutMs),
+		HealthCheckInterval: time.Second * time.Duration(r.cfg.RPC.HealthCheckIntervalSec),
+		MaxBlockLag:         r.cfg.RPC.MaxBlockLag,
+	}, lgr)
 }
 
 func (r *Registry) createDB() (*sqlx.DB, error) {
@@ -96,6 +346,11 @@ func (r *Registry) createRedisDB(ctx context.Context) (*redis.Client, error) {
 		Protocol: 3,
 	})
 
+	// trace every Redis command
+	if err := redisotel.InstrumentTracing(client); err != nil {
+		return nil, err
+	}
+
 	// check if the connection is alive
 	if err := client.Ping(ctx).Err(); err != nil {
 		return nil, err
@@ -0,0 +1,43 @@
+package tracing
+
+import (
+	"context"
+
+	"github.com/aisalamdag23/etherstats/internal/infrastructure/config"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// NewExporter creates an exporter sending spans to the OTLP/HTTP collector of cfg.
+func NewExporter(ctx context.Context, cfg config.Tracing) (sdktrace.SpanExporter, error) {
+	opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(cfg.Endpoint)}
+	if cfg.Insecure {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	}
+
+	return otlptracehttp.New(ctx, opts...)
+}
+
+// Init creates a tracer provider batching spans to exporter and installs it, along with the
+// W3C trace context propagator, as the global one used by every instrumented package.
+// Any exporter works, e.g. an in-memory one in tests. The provider must be shut down to flush spans.
+// New traces are sampled at the configured ratio, 0 samples none, while traces sampled upstream are always kept.
+func Init(appName, version string, cfg config.Tracing, exporter sdktrace.SpanExporter) *sdktrace.TracerProvider {
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+		sdktrace.WithResource(resource.NewSchemaless(
+			attribute.String("service.name", appName),
+			attribute.String("service.version", version),
+		)),
+	)
+
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	return tp
+}
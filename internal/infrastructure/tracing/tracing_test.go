@@ -0,0 +1,174 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aisalamdag23/etherstats/internal/domain"
+	ethhttp "github.com/aisalamdag23/etherstats/internal/handler/eth/v1"
+	"github.com/aisalamdag23/etherstats/internal/infrastructure/coalesce"
+	"github.com/aisalamdag23/etherstats/internal/infrastructure/config"
+	"github.com/aisalamdag23/etherstats/internal/infrastructure/protocol/rest/middleware"
+	"github.com/aisalamdag23/etherstats/internal/usecase/alchemy"
+	ethsvc "github.com/aisalamdag23/etherstats/internal/usecase/eth"
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gorilla/mux/otelmux"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+const testAddress = "0xd8dA6BF26964aF9D7eEd9e03E53415D37aA96045"
+
+// emptyRepository is a cache that never hits. Calls outside of Get's path panic on the nil interface.
+type emptyRepository struct {
+	domain.Repository
+}
+
+func (emptyRepository) GetGasPrice(context.Context) (string, error)    { return "", nil }
+func (emptyRepository) SetGasPrice(context.Context, string) error      { return nil }
+func (emptyRepository) GetBlockNumber(context.Context) (uint64, error) { return 0, nil }
+func (emptyRepository) SetBlockNumber(context.Context, uint64) error   { return nil }
+func (emptyRepository) SaveBalance(_ context.Context, address, balance string) (*domain.AddressBalance, error) {
+	return &domain.AddressBalance{Address: address, Balance: balance}, nil
+}
+
+// newRPCServer is a stand-in JSON-RPC endpoint serving the calls of Get
+func newRPCServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	results := map[string]interface{}{
+		"eth_gasPrice":         "0x3b9aca00",
+		"eth_blockNumber":      "0x10",
+		"eth_getBlockByNumber": map[string]string{"number": "0x10", "hash": "0x" + strings.Repeat("a", 64)},
+		"eth_getBalance":       "0xde0b6b3a7640000",
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"jsonrpc": "2.0", "id": req.ID, "result": results[req.Method]})
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// TestGetSpans follows a balance request from the server span down to the JSON-RPC calls.
+func TestGetSpans(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := Init("etherstats", "test", config.Tracing{Enabled: true, SampleRatio: 1}, exporter)
+	t.Cleanup(func() { _ = tp.Shutdown(context.Background()) })
+
+	provider, err := alchemy.NewServiceFromURL(newRPCServer(t).URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc := ethsvc.NewService(emptyRepository{}, nil, provider, nil, coalesce.NewLocal(), nil, ethsvc.Options{})
+
+	r := mux.NewRouter()
+	r.Use(otelmux.Middleware("etherstats"))
+	r.Use(middleware.CtxWithLogger(zap.NewNop()))
+	ethhttp.NewServer(map[string]domain.Service{"mainnet": svc}, "mainnet", nil).RegisterRoutes(r.PathPrefix("/api/v1").Subrouter())
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/eth/"+testAddress, nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+	}
+	if err := tp.ForceFlush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	spans := make(map[string]tracetest.SpanStub)
+	for _, span := range exporter.GetSpans() {
+		spans[span.Name] = span
+	}
+
+	server, ok := spans["/api/v1/eth/{id}"]
+	if !ok {
+		t.Fatalf("no server span among %v", names(spans))
+	}
+	if server.SpanKind != trace.SpanKindServer {
+		t.Errorf("server span kind = %v", server.SpanKind)
+	}
+
+	get, ok := spans["eth.Get"]
+	if !ok {
+		t.Fatalf("no eth.Get span among %v", names(spans))
+	}
+	if get.Parent.SpanID() != server.SpanContext.SpanID() {
+		t.Error("eth.Get is not a child of the server span")
+	}
+	for _, step := range []string{"eth.resolveAddress", "eth.getGasPrice", "eth.getLatestBlockNumber", "eth.getBalance"} {
+		span, ok := spans[step]
+		if !ok {
+			t.Errorf("no %s span among %v", step, names(spans))
+			continue
+		}
+		if span.Parent.SpanID() != get.SpanContext.SpanID() {
+			t.Errorf("%s is not a child of eth.Get", step)
+		}
+	}
+
+	for name, method := range map[string]string{
+		"rpc.GetGasPrice":          "eth_gasPrice",
+		"rpc.GetLatestBlockNumber": "eth_blockNumber",
+		"rpc.GetBalance":           "eth_getBalance",
+	} {
+		span, ok := spans[name]
+		if !ok {
+			t.Errorf("no %s span among %v", name, names(spans))
+			continue
+		}
+		if got := attr(span, "rpc.method"); got != method {
+			t.Errorf("%s rpc.method = %q, want %q", name, got, method)
+		}
+		if span.SpanContext.TraceID() != server.SpanContext.TraceID() {
+			t.Errorf("%s is not in the request's trace", name)
+		}
+	}
+}
+
+// TestZeroSampleRatio checks that a sample ratio of 0 records no new trace.
+func TestZeroSampleRatio(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := Init("etherstats", "test", config.Tracing{Enabled: true}, exporter)
+	t.Cleanup(func() { _ = tp.Shutdown(context.Background()) })
+
+	_, span := tp.Tracer("test").Start(context.Background(), "root")
+	span.End()
+	if err := tp.ForceFlush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if spans := exporter.GetSpans(); len(spans) != 0 {
+		t.Errorf("%d spans sampled, want none", len(spans))
+	}
+}
+
+func attr(span tracetest.SpanStub, key attribute.Key) string {
+	for _, kv := range span.Attributes {
+		if kv.Key == key {
+			return kv.Value.Emit()
+		}
+	}
+	return ""
+}
+
+func names(spans map[string]tracetest.SpanStub) []string {
+	list := make([]string, 0, len(spans))
+	for name := range spans {
+		list = append(list, name)
+	}
+	return list
+}
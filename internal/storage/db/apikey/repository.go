@@ -0,0 +1,310 @@
+package apikey
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aisalamdag23/etherstats/internal/domain"
+	"github.com/aisalamdag23/etherstats/internal/infrastructure/logger"
+	"github.com/jmoiron/sqlx"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+type (
+	repository struct {
+		db      *sqlx.DB
+		redisDB *redis.Client
+		// cacheTTL is how long a key is cached in Redis, which bounds how long a revoked key keeps working
+		cacheTTL time.Duration
+	}
+
+	// apiKeyRow is an api_keys row, the allowed chains are a text array
+	apiKeyRow struct {
+		domain.APIKey
+		AllowedChains textArray `db:"allowed_chains"`
+	}
+
+	// textArray scans a Postgres text array of plain words, such as chain names
+	textArray []string
+)
+
+const (
+	// keyCachePrefix is the prefix of the keys used to cache API keys in Redis by hash
+	keyCachePrefix = "apikey:hash:"
+	// streamTokenPrefix is the prefix of the keys holding the key ID of stream tokens by hash
+	streamTokenPrefix = "apikey:streamtoken:"
+	// apiKeyColumns are the columns of an API key, in the order of apiKeyRow
+	apiKeyColumns = `id, name, prefix, key_hash, rate_per_sec, burst, daily_quota, allowed_chains, is_admin, created_at, revoked_at`
+)
+
+// NewRepository creates the repository of API keys.
+// Keys are read from the database and cached in Redis for cacheTTL.
+func NewRepository(db *sqlx.DB, redisDB *redis.Client, cacheTTL time.Duration) domain.APIKeyRepository {
+	return &repository{
+		db:       db,
+		redisDB:  redisDB,
+		cacheTTL: cacheTTL,
+	}
+}
+
+// GetAPIKeyByHash retrieves an active API key by the hash of the key.
+// It first checks Redis, then falls back to the database and refills Redis.
+// Redis errors are logged and fall back to the database as well.
+// If the key does not exist or is revoked, it returns nil.
+func (r *repository) GetAPIKeyByHash(ctx context.Context, hash string) (*domain.APIKey, error) {
+	val, err := r.redisDB.Get(ctx, keyCachePrefix+hash).Bytes()
+	if err == nil {
+		var key domain.APIKey
+		if err := json.Unmarshal(val, &key); err == nil {
+			return &key, nil
+		}
+	} else if err != redis.Nil {
+		// Postgres stays the source of truth, Redis being down must not lock every key out
+		logger.Extract(ctx).Warn("failed to read cached api key, falling back to postgres", zap.Error(err))
+	}
+
+	query := `SELECT ` + apiKeyColumns + `
+			  FROM api_keys
+			  WHERE key_hash = $1 AND revoked_at IS NULL;`
+
+	key, err := r.getAPIKey(ctx, query, hash)
+	if err != nil || key == nil {
+		return nil, err
+	}
+
+	// the key is cached as a convenience, a failure only costs another query
+	if val, err := json.Marshal(key); err == nil {
+		_ = r.redisDB.Set(ctx, keyCachePrefix+hash, val, r.cacheTTL).Err()
+	}
+
+	return key, nil
+}
+
+// GetAPIKey retrieves an API key by ID, revoked or not.
+// If the key does not exist, it returns nil.
+func (r *repository) GetAPIKey(ctx context.Context, id int64) (*domain.APIKey, error) {
+	query := `SELECT ` + apiKeyColumns + `
+			  FROM api_keys
+			  WHERE id = $1;`
+
+	return r.getAPIKey(ctx, query, id)
+}
+
+// ListAPIKeys retrieves every API key, revoked or not, ordered by ID.
+func (r *repository) ListAPIKeys(ctx context.Context) ([]domain.APIKey, error) {
+	query := `SELECT ` + apiKeyColumns + `
+			  FROM api_keys
+			  ORDER BY id;`
+
+	rows := []apiKeyRow{}
+	if err := r.db.SelectContext(ctx, &rows, query); err != nil {
+		return nil, wrapStoreError(err, "failed to list api keys")
+	}
+
+	keys := make([]domain.APIKey, 0, len(rows))
+	for _, row := range rows {
+		keys = append(keys, row.toDomain())
+	}
+
+	return keys, nil
+}
+
+// CreateAPIKey saves a new API key and returns it with its ID.
+func (r *repository) CreateAPIKey(ctx context.Context, key domain.APIKey) (*domain.APIKey, error) {
+	query := `INSERT INTO api_keys
+				(name, prefix, key_hash, rate_per_sec, burst, daily_quota, allowed_chains, is_admin)
+			  VALUES
+				($1, $2, $3, $4, $5, $6, $7, $8)
+			  RETURNING ` + apiKeyColumns + `;`
+
+	return r.getAPIKey(ctx, query, key.Name, key.Prefix, key.KeyHash,
+		key.RatePerSec, key.Burst, key.DailyQuota, nonNil(key.AllowedChains), key.IsAdmin)
+}
+
+// UpdateAPIKey saves the name, limits, allowed chains and admin flag of an active API key.
+// If the key does not exist or is revoked, it returns nil.
+func (r *repository) UpdateAPIKey(ctx context.Context, key domain.APIKey) (*domain.APIKey, error) {
+	query := `UPDATE api_keys
+			  SET name = $2, rate_per_sec = $3, burst = $4, daily_quota = $5, allowed_chains = $6, is_admin = $7
+			  WHERE id = $1 AND revoked_at IS NULL
+			  RETURNING ` + apiKeyColumns + `;`
+
+	updated, err := r.getAPIKey(ctx, query, key.ID, key.Name,
+		key.RatePerSec, key.Burst, key.DailyQuota, nonNil(key.AllowedChains), key.IsAdmin)
+	if err != nil || updated == nil {
+		return nil, err
+	}
+
+	return updated, r.uncache(ctx, updated.KeyHash)
+}
+
+// RotateAPIKey replaces the prefix and hash of an active API key, the old key stops working at once.
+// If the key does not exist or is revoked, it returns nil.
+func (r *repository) RotateAPIKey(ctx context.Context, id int64, prefix, hash string) (*domain.APIKey, error) {
+	old, err := r.GetAPIKey(ctx, id)
+	if err != nil || old == nil {
+		return nil, err
+	}
+
+	query := `UPDATE api_keys
+			  SET prefix = $2, key_hash = $3
+			  WHERE id = $1 AND revoked_at IS NULL
+			  RETURNING ` + apiKeyColumns + `;`
+
+	rotated, err := r.getAPIKey(ctx, query, id, prefix, hash)
+	if err != nil || rotated == nil {
+		return nil, err
+	}
+
+	return rotated, r.uncache(ctx, old.KeyHash)
+}
+
+// RevokeAPIKey revokes an API key, it stops working at once. Revoking a revoked key keeps
+// its original revocation time. If the key does not exist, it returns nil.
+func (r *repository) RevokeAPIKey(ctx context.Context, id int64) (*domain.APIKey, error) {
+	query := `UPDATE api_keys
+			  SET revoked_at = COALESCE(revoked_at, NOW())
+			  WHERE id = $1
+			  RETURNING ` + apiKeyColumns + `;`
+
+	revoked, err := r.getAPIKey(ctx, query, id)
+	if err != nil || revoked == nil {
+		return nil, err
+	}
+
+	return revoked, r.uncache(ctx, revoked.KeyHash)
+}
+
+// SaveUsage upserts the usage of API keys with a single multi-row insert.
+// Counts are totals of the day, so saving the same usage twice is harmless.
+func (r *repository) SaveUsage(ctx context.Context, usage []domain.APIKeyUsage) error {
+	if len(usage) == 0 {
+		return nil
+	}
+
+	query := `INSERT INTO api_key_usage
+				(api_key_id, day, route, requests)
+			  VALUES
+				(:api_key_id, :day, :route, :requests)
+			  ON CONFLICT (api_key_id, day, route) DO UPDATE
+			  SET requests = GREATEST(api_key_usage.requests, EXCLUDED.requests), updated_at = NOW();`
+
+	_, err := r.db.NamedExecContext(ctx, query, usage)
+	return wrapStoreError(err, "failed to save api key usage")
+}
+
+// GetUsage retrieves the usage of an API key between two UTC days, both included, ordered by day and route.
+func (r *repository) GetUsage(ctx context.Context, query domain.UsageQuery) ([]domain.APIKeyUsage, error) {
+	stmt := `SELECT api_key_id, day, route, requests
+			 FROM api_key_usage
+			 WHERE api_key_id = $1 AND day BETWEEN $2 AND $3
+			 ORDER BY day, route;`
+
+	usage := []domain.APIKeyUsage{}
+	err := r.db.SelectContext(ctx, &usage, stmt, query.APIKeyID, query.From, query.To)
+	if err != nil {
+		return nil, wrapStoreError(err, "failed to read api key usage")
+	}
+
+	return usage, nil
+}
+
+// SaveStreamToken stores the key ID of a stream token by the hash of the token, for ttl.
+// Tokens live in Redis only, they are too short-lived for Postgres.
+func (r *repository) SaveStreamToken(ctx context.Context, hash string, keyID int64, ttl time.Duration) error {
+	return wrapStoreError(r.redisDB.Set(ctx, streamTokenPrefix+hash, keyID, ttl).Err(), "failed to save stream token")
+}
+
+// TakeStreamToken removes a stream token and returns the ID of its key.
+// If the token expired or was already taken, it returns 0.
+func (r *repository) TakeStreamToken(ctx context.Context, hash string) (int64, error) {
+	keyID, err := r.redisDB.GetDel(ctx, streamTokenPrefix+hash).Int64()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, wrapStoreError(err, "failed to take stream token")
+	}
+
+	return keyID, nil
+}
+
+// getAPIKey runs a query returning at most one API key, nil when it returns none.
+func (r *repository) getAPIKey(ctx context.Context, query string, args ...interface{}) (*domain.APIKey, error) {
+	var row apiKeyRow
+	err := r.db.GetContext(ctx, &row, query, args...)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, wrapStoreError(err, "failed to query api key")
+	}
+
+	key := row.toDomain()
+	return &key, nil
+}
+
+// uncache drops a key from Redis so a change takes effect at once rather than after the cache TTL.
+func (r *repository) uncache(ctx context.Context, hash string) error {
+	return wrapStoreError(r.redisDB.Del(ctx, keyCachePrefix+hash).Err(), "failed to uncache api key")
+}
+
+func (row apiKeyRow) toDomain() domain.APIKey {
+	key := row.APIKey
+	key.AllowedChains = []string(row.AllowedChains)
+	return key
+}
+
+// Scan parses the text representation of a one-dimensional array, e.g. {mainnet,base}.
+func (a *textArray) Scan(src interface{}) error {
+	var val string
+	switch src := src.(type) {
+	case string:
+		val = src
+	case []byte:
+		val = string(src)
+	case nil:
+		*a = nil
+		return nil
+	default:
+		return fmt.Errorf("cannot scan %T into a text array", src)
+	}
+
+	val = strings.TrimSuffix(strings.TrimPrefix(val, "{"), "}")
+	if val == "" {
+		*a = textArray{}
+		return nil
+	}
+
+	parts := strings.Split(val, ",")
+	for i, part := range parts {
+		parts[i] = strings.Trim(part, `"`)
+	}
+	*a = parts
+	return nil
+}
+
+// nonNil turns a nil slice into an empty one, which the NOT NULL array columns expect.
+func nonNil(vals []string) []string {
+	if vals == nil {
+		return []string{}
+	}
+	return vals
+}
+
+// wrapStoreError classifies a Redis or Postgres failure, it returns nil when err is nil.
+func wrapStoreError(err error, format string, args ...interface{}) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return domain.NewError(domain.KindTimeout, err, format, args...)
+	}
+	return domain.NewError(domain.KindUpstreamUnavailable, err, format, args...)
+}
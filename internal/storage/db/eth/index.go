@@ -0,0 +1,230 @@
+package eth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aisalamdag23/etherstats/internal/domain"
+	"github.com/aisalamdag23/etherstats/internal/infrastructure/metrics"
+	"github.com/jmoiron/sqlx"
+)
+
+type indexRepository struct {
+	db      *sqlx.DB
+	chainID uint64
+}
+
+const (
+	watchedColumns  = `id, chain_id, address, label, created_at`
+	transferColumns = `id, chain_id, block_number, block_hash, block_timestamp, tx_hash, address, counterparty, direction, value, status, created_at`
+)
+
+// NewIndexRepository creates the repository of the watch-list and indexed transfers of a single chain.
+// Addresses are expected checksummed, they are compared as is.
+func NewIndexRepository(db *sqlx.DB, chainID uint64) domain.IndexRepository {
+	return &indexRepository{
+		db:      db,
+		chainID: chainID,
+	}
+}
+
+// ListWatched retrieves every watched address of the chain, oldest first.
+func (r *indexRepository) ListWatched(ctx context.Context) ([]domain.WatchedAddress, error) {
+	query := `SELECT ` + watchedColumns + `
+			  FROM watched_addresses
+			  WHERE chain_id = $1
+			  ORDER BY id;`
+
+	watched := []domain.WatchedAddress{}
+	err := r.db.SelectContext(ctx, &watched, query, r.chainID)
+	if err != nil {
+		return nil, wrapStoreError(err, "failed to read watched addresses")
+	}
+
+	return watched, nil
+}
+
+// GetWatched retrieves a watched address.
+// If the address is not watched, it returns nil.
+func (r *indexRepository) GetWatched(ctx context.Context, address string) (*domain.WatchedAddress, error) {
+	query := `SELECT ` + watchedColumns + `
+			  FROM watched_addresses
+			  WHERE chain_id = $1 AND address = $2;`
+
+	return r.getWatched(ctx, query, r.chainID, address)
+}
+
+// AddWatched adds an address to the watch-list.
+// If the address is already watched, it returns nil.
+func (r *indexRepository) AddWatched(ctx context.Context, watched domain.WatchedAddress) (*domain.WatchedAddress, error) {
+	query := `INSERT INTO watched_addresses
+				(chain_id, address, label)
+			  VALUES
+				($1, $2, $3)
+			  ON CONFLICT (chain_id, address) DO NOTHING
+			  RETURNING ` + watchedColumns + `;`
+
+	return r.getWatched(ctx, query, r.chainID, watched.Address, watched.Label)
+}
+
+// UpdateWatched updates the label of a watched address.
+// If the address is not watched, it returns nil.
+func (r *indexRepository) UpdateWatched(ctx context.Context, watched domain.WatchedAddress) (*domain.WatchedAddress, error) {
+	query := `UPDATE watched_addresses
+			  SET label = $3
+			  WHERE chain_id = $1 AND address = $2
+			  RETURNING ` + watchedColumns + `;`
+
+	return r.getWatched(ctx, query, r.chainID, watched.Address, watched.Label)
+}
+
+// RemoveWatched removes an address from the watch-list. Its indexed transfers are kept.
+// It reports whether the address was watched.
+func (r *indexRepository) RemoveWatched(ctx context.Context, address string) (bool, error) {
+	query := `DELETE FROM watched_addresses
+			  WHERE chain_id = $1 AND address = $2;`
+
+	res, err := r.db.ExecContext(ctx, query, r.chainID, address)
+	if err != nil {
+		return false, wrapStoreError(err, "failed to remove watched address")
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, wrapStoreError(err, "failed to remove watched address")
+	}
+
+	return n > 0, nil
+}
+
+func (r *indexRepository) getWatched(ctx context.Context, query string, args ...interface{}) (*domain.WatchedAddress, error) {
+	var watched domain.WatchedAddress
+	err := r.db.GetContext(ctx, &watched, query, args...)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, wrapStoreError(err, "failed to read watched address")
+	}
+
+	return &watched, nil
+}
+
+// GetLastIndexedBlock retrieves the highest indexed block of the chain.
+// If no block is indexed yet, it returns nil.
+func (r *indexRepository) GetLastIndexedBlock(ctx context.Context) (*domain.IndexedBlock, error) {
+	query := `SELECT number, hash, parent_hash
+			  FROM indexed_blocks
+			  WHERE chain_id = $1
+			  ORDER BY number DESC
+			  LIMIT 1;`
+
+	var block domain.IndexedBlock
+	err := r.db.GetContext(ctx, &block, query, r.chainID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, wrapStoreError(err, "failed to read last indexed block")
+	}
+
+	return &block, nil
+}
+
+// SaveIndexedBlock saves an indexed block along with its transfers in a single transaction.
+// A block indexed already, e.g. by another replica, is left as is along with its transfers.
+func (r *indexRepository) SaveIndexedBlock(ctx context.Context, block domain.IndexedBlock, transfers []domain.Transfer) error {
+	start := time.Now()
+	defer func() {
+		metrics.DBQueryDuration.WithLabelValues("save_indexed_block").Observe(metrics.Since(start))
+	}()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return wrapStoreError(err, "failed to save indexed block")
+	}
+	// a no-op once committed
+	defer func() { _ = tx.Rollback() }()
+
+	res, err := tx.ExecContext(ctx, `INSERT INTO indexed_blocks
+				(chain_id, number, hash, parent_hash)
+			  VALUES
+				($1, $2, $3, $4)
+			  ON CONFLICT (chain_id, number) DO NOTHING;`,
+		r.chainID, block.Number, block.Hash, block.ParentHash)
+	if err != nil {
+		return wrapStoreError(err, "failed to save indexed block")
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return wrapStoreError(err, "failed to save indexed block")
+	}
+	if n == 0 {
+		// indexed already
+		return nil
+	}
+
+	if len(transfers) > 0 {
+		for i := range transfers {
+			transfers[i].ChainID = r.chainID
+		}
+		_, err = tx.NamedExecContext(ctx, `INSERT INTO transfers
+				(chain_id, block_number, block_hash, block_timestamp, tx_hash, address, counterparty, direction, value, status)
+			  VALUES
+				(:chain_id, :block_number, :block_hash, :block_timestamp, :tx_hash, :address, :counterparty, :direction, :value, :status)
+			  ON CONFLICT (chain_id, tx_hash, address) DO NOTHING;`, transfers)
+		if err != nil {
+			return wrapStoreError(err, "failed to save transfers of block %d", block.Number)
+		}
+	}
+
+	return wrapStoreError(tx.Commit(), "failed to save indexed block")
+}
+
+// RollbackFrom deletes the indexed blocks from the given number on, along with their transfers,
+// in a single transaction.
+func (r *indexRepository) RollbackFrom(ctx context.Context, number uint64) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return wrapStoreError(err, "failed to roll back indexed blocks")
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	for _, query := range []string{
+		`DELETE FROM transfers WHERE chain_id = $1 AND block_number >= $2;`,
+		`DELETE FROM indexed_blocks WHERE chain_id = $1 AND number >= $2;`,
+	} {
+		if _, err := tx.ExecContext(ctx, query, r.chainID, number); err != nil {
+			return wrapStoreError(err, "failed to roll back indexed blocks")
+		}
+	}
+
+	return wrapStoreError(tx.Commit(), "failed to roll back indexed blocks")
+}
+
+// GetTransfers retrieves the indexed transfers of an address, newest first.
+func (r *indexRepository) GetTransfers(ctx context.Context, query domain.TransferQuery) ([]domain.Transfer, error) {
+	conditions := "chain_id = $1 AND address = $2"
+	args := []interface{}{r.chainID, query.Address}
+	if query.BeforeID > 0 {
+		args = append(args, query.BeforeID)
+		conditions += fmt.Sprintf(" AND id < $%d", len(args))
+	}
+
+	args = append(args, query.Limit)
+	stmt := fmt.Sprintf(`SELECT %s
+				  FROM transfers
+				  WHERE %s
+				  ORDER BY id DESC
+				  LIMIT $%d;`, transferColumns, conditions, len(args))
+
+	transfers := []domain.Transfer{}
+	err := r.db.SelectContext(ctx, &transfers, stmt, args...)
+	if err != nil {
+		return nil, wrapStoreError(err, "failed to read transfers")
+	}
+
+	return transfers, nil
+}
@@ -2,56 +2,106 @@ package eth
 
 import (
 	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/aisalamdag23/etherstats/internal/domain"
+	"github.com/aisalamdag23/etherstats/internal/infrastructure/metrics"
 	"github.com/jmoiron/sqlx"
 	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type (
 	repository struct {
 		db       *sqlx.DB
 		redisDB  *redis.Client
+		chainID  uint64
 		cacheTTL time.Duration
+		// lastKnownTTL is how long last known good values are kept, 0 disables them
+		lastKnownTTL time.Duration
 	}
 )
 
+var tracer = otel.Tracer("github.com/aisalamdag23/etherstats/internal/storage/db/eth")
+
 const (
 	// gasPriceKey is the key used to store the gas price in Redis
 	gasPriceKey = "gas_price"
 	// blockNumberKey is the key used to store the block number in Redis
 	blockNumberKey = "block_number"
+	// feesKey is the key used to store the fee estimates in Redis
+	feesKey = "fees"
+	// tokenKeyPrefix is the prefix of the keys used to store token metadata in Redis
+	tokenKeyPrefix = "token:"
+	// tokenCacheTTL is how long token metadata stays in Redis, it rarely ever changes
+	tokenCacheTTL = 24 * time.Hour
+	// blockKeyPrefix is the prefix of the keys used to store recent blocks in Redis, by number and by hash
+	blockKeyPrefix = "block:"
+	// lastKnownKeyPrefix is the prefix of the keys used to store last known good values in Redis
+	lastKnownKeyPrefix = "last:"
 )
 
-func NewRepository(db *sqlx.DB, redisDB *redis.Client, cacheTTL time.Duration) domain.Repository {
+// NewRepository creates the repository of a single chain.
+// Redis keys and database rows are scoped to the chain ID.
+// Cached values expire after cacheTTL, their last known good copies after lastKnownTTL.
+func NewRepository(db *sqlx.DB, redisDB *redis.Client, chainID uint64, cacheTTL, lastKnownTTL time.Duration) domain.Repository {
 	return &repository{
-		db:       db,
-		redisDB:  redisDB,
-		cacheTTL: cacheTTL,
+		db:           db,
+		redisDB:      redisDB,
+		chainID:      chainID,
+		cacheTTL:     cacheTTL,
+		lastKnownTTL: lastKnownTTL,
+	}
+}
+
+// key namespaces a Redis key with the chain ID.
+func (r *repository) key(name string) string {
+	return fmt.Sprintf("chain:%d:%s", r.chainID, name)
+}
+
+// observeCache counts the result of a cache lookup of the given key.
+func (r *repository) observeCache(name string, err error) {
+	result := metrics.CacheHit
+	switch {
+	case err == redis.Nil:
+		result = metrics.CacheMiss
+	case err != nil:
+		result = metrics.CacheError
 	}
+	metrics.CacheRequests.WithLabelValues(strconv.FormatUint(r.chainID, 10), name, result).Inc()
 }
 
-// SetGasPrice sets the current gas price in Redis with a specified TTL.
+// SetGasPrice sets the current gas price in Redis with a specified TTL,
+// along with its last known good copy.
 // It returns an error if the operation fails.
 func (r *repository) SetGasPrice(ctx context.Context, price string) error {
-	return r.redisDB.Set(ctx, gasPriceKey, price, r.cacheTTL).Err()
+	return wrapStoreError(r.setWithLastKnown(ctx, gasPriceKey, price), "failed to cache gas price")
 }
 
-// SetBlockNumber sets the latest block number in Redis with a specified TTL.
+// SetBlockNumber sets the latest block number in Redis with a specified TTL,
+// along with its last known good copy.
 // It returns an error if the operation fails.
 func (r *repository) SetBlockNumber(ctx context.Context, blockNumber uint64) error {
-	return r.redisDB.Set(ctx, blockNumberKey, blockNumber, r.cacheTTL).Err()
+	return wrapStoreError(r.setWithLastKnown(ctx, blockNumberKey, blockNumber), "failed to cache block number")
 }
 
 // GetGasPrice retrieves the current gas price from Redis.
 // If the value is not found, it returns an empty string.
 func (r *repository) GetGasPrice(ctx context.Context) (string, error) {
-	val, err := r.redisDB.Get(ctx, gasPriceKey).Result()
+	val, err := r.redisDB.Get(ctx, r.key(gasPriceKey)).Result()
+	r.observeCache(gasPriceKey, err)
 	if err != nil {
 		if err != redis.Nil {
-			return "", err
+			return "", wrapStoreError(err, "failed to read gas price")
 		}
 
 		// If the value is not found in Redis, return an empty string
@@ -64,10 +114,11 @@ func (r *repository) GetGasPrice(ctx context.Context) (string, error) {
 // GetBlockNumber retrieves the latest block number from Redis.
 // If the value is not found, it returns 0.
 func (r *repository) GetBlockNumber(ctx context.Context) (uint64, error) {
-	val, err := r.redisDB.Get(ctx, blockNumberKey).Result()
+	val, err := r.redisDB.Get(ctx, r.key(blockNumberKey)).Result()
+	r.observeCache(blockNumberKey, err)
 	if err != nil {
 		if err != redis.Nil {
-			return 0, err
+			return 0, wrapStoreError(err, "failed to read block number")
 		}
 
 		// If the value is not found in Redis, return 0
@@ -82,20 +133,404 @@ func (r *repository) GetBlockNumber(ctx context.Context) (uint64, error) {
 	return blockNumber, nil
 }
 
+// GetLastGasPrice retrieves the last known good gas price from Redis.
+// If the value is not found, it returns nil.
+func (r *repository) GetLastGasPrice(ctx context.Context) (*domain.LastKnown[string], error) {
+	return getLastKnown[string](ctx, r, gasPriceKey)
+}
+
+// GetLastBlockNumber retrieves the last known good block number from Redis.
+// If the value is not found, it returns nil.
+func (r *repository) GetLastBlockNumber(ctx context.Context) (*domain.LastKnown[uint64], error) {
+	return getLastKnown[uint64](ctx, r, blockNumberKey)
+}
+
+// setWithLastKnown sets a cached value and its last known good copy in a single round trip.
+func (r *repository) setWithLastKnown(ctx context.Context, name string, val interface{}) error {
+	if r.lastKnownTTL <= 0 {
+		return r.redisDB.Set(ctx, r.key(name), val, r.cacheTTL).Err()
+	}
+
+	last, err := json.Marshal(domain.LastKnown[interface{}]{Value: val, AsOf: time.Now()})
+	if err != nil {
+		return err
+	}
+
+	_, err = r.redisDB.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, r.key(name), val, r.cacheTTL)
+		pipe.Set(ctx, r.key(lastKnownKeyPrefix+name), last, r.lastKnownTTL)
+		return nil
+	})
+	return err
+}
+
+// getLastKnown retrieves the last known good copy of a cached value, nil when there is none.
+func getLastKnown[T any](ctx context.Context, r *repository, name string) (*domain.LastKnown[T], error) {
+	val, err := r.redisDB.Get(ctx, r.key(lastKnownKeyPrefix+name)).Bytes()
+	if err != nil {
+		if err != redis.Nil {
+			return nil, wrapStoreError(err, "failed to read last known %s", name)
+		}
+		return nil, nil
+	}
+
+	var last domain.LastKnown[T]
+	if err := json.Unmarshal(val, &last); err != nil {
+		return nil, err
+	}
+
+	return &last, nil
+}
+
+// SetFees sets the current fee estimates in Redis with a specified TTL.
+// It returns an error if the operation fails.
+func (r *repository) SetFees(ctx context.Context, fees domain.Fees) error {
+	val, err := json.Marshal(fees)
+	if err != nil {
+		return err
+	}
+
+	return wrapStoreError(r.redisDB.Set(ctx, r.key(feesKey), val, r.cacheTTL).Err(), "failed to cache fees")
+}
+
+// GetFees retrieves the current fee estimates from Redis.
+// If the value is not found, it returns nil.
+func (r *repository) GetFees(ctx context.Context) (*domain.Fees, error) {
+	val, err := r.redisDB.Get(ctx, r.key(feesKey)).Bytes()
+	if err != nil {
+		if err != redis.Nil {
+			return nil, wrapStoreError(err, "failed to read fees")
+		}
+
+		// If the value is not found in Redis, return nil
+		return nil, nil
+	}
+
+	var fees domain.Fees
+	if err := json.Unmarshal(val, &fees); err != nil {
+		return nil, err
+	}
+
+	return &fees, nil
+}
+
 // SaveBalance saves the balance of an Ethereum address to the database.
 // It returns the saved AddressBalance object or an error if the operation fails.
 func (r *repository) SaveBalance(ctx context.Context, address, balance string) (*domain.AddressBalance, error) {
 	query := `INSERT INTO balances 
-				(address, balance)
+				(chain_id, address, balance)
 			  VALUES 
-				($1, $2)
+				($1, $2, $3)
 			  RETURNING *;`
 
+	ctx, span := tracer.Start(ctx, "repository.SaveBalance", trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", "postgresql"),
+			attribute.String("db.operation", "INSERT"),
+			attribute.String("db.sql.table", "balances"),
+		))
+	defer span.End()
+
 	var bal domain.AddressBalance
-	err := r.db.GetContext(ctx, &bal, query, address, balance)
+	start := time.Now()
+	err := r.db.GetContext(ctx, &bal, query, r.chainID, address, balance)
+	metrics.DBQueryDuration.WithLabelValues("save_balance").Observe(metrics.Since(start))
 	if err != nil {
-		return nil, err
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "insert failed")
+		return nil, wrapStoreError(err, "failed to save balance")
+	}
+
+	return &bal, nil
+}
+
+// SaveBalances saves the balances of many Ethereum addresses with a single multi-row insert.
+// It returns an error if the operation fails.
+func (r *repository) SaveBalances(ctx context.Context, balances []domain.AddressBalance) error {
+	if len(balances) == 0 {
+		return nil
+	}
+
+	for i := range balances {
+		balances[i].ChainID = r.chainID
+	}
+
+	query := `INSERT INTO balances 
+				(chain_id, address, balance)
+			  VALUES 
+				(:chain_id, :address, :balance);`
+
+	_, err := r.db.NamedExecContext(ctx, query, balances)
+	return wrapStoreError(err, "failed to save balances")
+}
+
+// GetBalanceHistory retrieves the saved balances of an Ethereum address ordered by insertion.
+// The address is matched case-insensitively since it is stored as received.
+func (r *repository) GetBalanceHistory(ctx context.Context, query domain.BalanceHistoryQuery) ([]domain.AddressBalance, error) {
+	conditions := []string{"chain_id = $1", "LOWER(address) = LOWER($2)"}
+	args := []interface{}{r.chainID, query.Address}
+
+	if query.From != nil {
+		args = append(args, *query.From)
+		conditions = append(conditions, fmt.Sprintf("created_at >= $%d", len(args)))
+	}
+	if query.To != nil {
+		args = append(args, *query.To)
+		conditions = append(conditions, fmt.Sprintf("created_at < $%d", len(args)))
+	}
+
+	order, cmp := "ASC", ">"
+	if query.Descending {
+		order, cmp = "DESC", "<"
+	}
+	if query.AfterID > 0 {
+		args = append(args, query.AfterID)
+		conditions = append(conditions, fmt.Sprintf("id %s $%d", cmp, len(args)))
+	}
+
+	args = append(args, query.Limit)
+	stmt := fmt.Sprintf(`SELECT id, chain_id, address, balance, created_at
+				  FROM balances
+				  WHERE %s
+				  ORDER BY id %s
+				  LIMIT $%d;`, strings.Join(conditions, " AND "), order, len(args))
+
+	bals := []domain.AddressBalance{}
+	err := r.db.SelectContext(ctx, &bals, stmt, args...)
+	if err != nil {
+		return nil, wrapStoreError(err, "failed to read balance history")
+	}
+
+	return bals, nil
+}
+
+// GetLastBalance retrieves the most recently saved balance of an Ethereum address.
+// If the address has no saved balance, it returns nil.
+func (r *repository) GetLastBalance(ctx context.Context, address string) (*domain.AddressBalance, error) {
+	query := `SELECT id, chain_id, address, balance, created_at
+			  FROM balances
+			  WHERE chain_id = $1 AND LOWER(address) = LOWER($2)
+			  ORDER BY id DESC
+			  LIMIT 1;`
+
+	var bal domain.AddressBalance
+	err := r.db.GetContext(ctx, &bal, query, r.chainID, address)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, wrapStoreError(err, "failed to read last balance")
 	}
 
 	return &bal, nil
 }
+
+// GetToken retrieves the metadata of an ERC-20 token contract of the repository's chain.
+// It first checks Redis, then falls back to the database and refills Redis.
+// If the token is not found in either, it returns nil.
+func (r *repository) GetToken(ctx context.Context, contract string) (*domain.Token, error) {
+	val, err := r.redisDB.Get(ctx, r.key(tokenKeyPrefix+contract)).Bytes()
+	if err == nil {
+		var token domain.Token
+		if err := json.Unmarshal(val, &token); err == nil {
+			return &token, nil
+		}
+	} else if err != redis.Nil {
+		return nil, wrapStoreError(err, "failed to read token")
+	}
+
+	query := `SELECT chain_id, contract_address, symbol, decimals, created_at
+			  FROM tokens
+			  WHERE chain_id = $1 AND contract_address = $2;`
+
+	var token domain.Token
+	err = r.db.GetContext(ctx, &token, query, r.chainID, contract)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			// If the token is not found in the database, return nil
+			return nil, nil
+		}
+		return nil, wrapStoreError(err, "failed to read token")
+	}
+
+	if err := r.cacheToken(ctx, token); err != nil {
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+// SaveToken saves the metadata of an ERC-20 token contract to the database and Redis.
+// It returns an error if the operation fails.
+func (r *repository) SaveToken(ctx context.Context, token domain.Token) error {
+	query := `INSERT INTO tokens 
+				(chain_id, contract_address, symbol, decimals)
+			  VALUES 
+				($1, $2, $3, $4)
+			  ON CONFLICT (chain_id, contract_address) DO UPDATE
+			  SET symbol = EXCLUDED.symbol, decimals = EXCLUDED.decimals;`
+
+	_, err := r.db.ExecContext(ctx, query, r.chainID, token.Contract, token.Symbol, token.Decimals)
+	if err != nil {
+		return wrapStoreError(err, "failed to save token")
+	}
+
+	return r.cacheToken(ctx, token)
+}
+
+func (r *repository) cacheToken(ctx context.Context, token domain.Token) error {
+	val, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+
+	return wrapStoreError(r.redisDB.Set(ctx, r.key(tokenKeyPrefix+token.Contract), val, tokenCacheTTL).Err(), "failed to cache token")
+}
+
+// GetTransaction retrieves a saved transaction of the repository's chain by hash.
+// If the transaction is not saved, it returns nil.
+func (r *repository) GetTransaction(ctx context.Context, hash string) (*domain.TransactionDetails, error) {
+	query := `SELECT details
+			  FROM transactions
+			  WHERE chain_id = $1 AND hash = $2;`
+
+	var raw []byte
+	err := r.db.GetContext(ctx, &raw, query, r.chainID, strings.ToLower(hash))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, wrapStoreError(err, "failed to read transaction")
+	}
+
+	var tx domain.TransactionDetails
+	if err := json.Unmarshal(raw, &tx); err != nil {
+		return nil, err
+	}
+
+	return &tx, nil
+}
+
+// SaveTransaction saves an included transaction along with its receipt to the database.
+// Saving a transaction twice keeps the first copy.
+func (r *repository) SaveTransaction(ctx context.Context, tx domain.TransactionDetails) error {
+	if tx.Receipt == nil {
+		return fmt.Errorf("transaction %s is pending", tx.Transaction.Hash)
+	}
+
+	details, err := json.Marshal(tx)
+	if err != nil {
+		return err
+	}
+
+	query := `INSERT INTO transactions
+				(chain_id, hash, block_number, block_hash, details)
+			  VALUES
+				($1, $2, $3, $4, $5)
+			  ON CONFLICT (chain_id, hash) DO NOTHING;`
+
+	start := time.Now()
+	_, err = r.db.ExecContext(ctx, query, r.chainID, strings.ToLower(tx.Transaction.Hash),
+		tx.Receipt.BlockNumber, tx.Receipt.BlockHash, details)
+	metrics.DBQueryDuration.WithLabelValues("save_transaction").Observe(metrics.Since(start))
+
+	return wrapStoreError(err, "failed to save transaction")
+}
+
+// GetBlock retrieves a block of the repository's chain by hash when set, by number otherwise.
+// It first checks Redis, where recent blocks are, then the database, where finalized blocks are.
+// If the block is not found in either, it returns nil.
+func (r *repository) GetBlock(ctx context.Context, ref domain.BlockRef) (*domain.Block, error) {
+	id := strconv.FormatUint(ref.Number, 10)
+	if ref.Hash != "" {
+		id = strings.ToLower(ref.Hash)
+	}
+
+	val, err := r.redisDB.Get(ctx, r.key(blockKeyPrefix+id)).Bytes()
+	r.observeCache("block", err)
+	if err == nil {
+		var block domain.Block
+		if err := json.Unmarshal(val, &block); err == nil {
+			return &block, nil
+		}
+	} else if err != redis.Nil {
+		return nil, wrapStoreError(err, "failed to read block")
+	}
+
+	query := `SELECT details
+			  FROM blocks
+			  WHERE chain_id = $1 AND number = $2;`
+	args := []interface{}{r.chainID, ref.Number}
+	if ref.Hash != "" {
+		query = `SELECT details
+			  FROM blocks
+			  WHERE chain_id = $1 AND hash = $2;`
+		args = []interface{}{r.chainID, id}
+	}
+
+	var raw []byte
+	err = r.db.GetContext(ctx, &raw, query, args...)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, wrapStoreError(err, "failed to read block")
+	}
+
+	var block domain.Block
+	if err := json.Unmarshal(raw, &block); err != nil {
+		return nil, err
+	}
+
+	return &block, nil
+}
+
+// SaveBlock saves a finalized block along with its transactions to the database.
+// Saving a block twice keeps the first copy.
+func (r *repository) SaveBlock(ctx context.Context, block domain.Block) error {
+	details, err := json.Marshal(block)
+	if err != nil {
+		return err
+	}
+
+	query := `INSERT INTO blocks
+				(chain_id, number, hash, details)
+			  VALUES
+				($1, $2, $3, $4)
+			  ON CONFLICT (chain_id, number) DO NOTHING;`
+
+	start := time.Now()
+	_, err = r.db.ExecContext(ctx, query, r.chainID, block.Number, strings.ToLower(block.Hash), details)
+	metrics.DBQueryDuration.WithLabelValues("save_block").Observe(metrics.Since(start))
+
+	return wrapStoreError(err, "failed to save block")
+}
+
+// CacheBlock caches a recent block in Redis by number and by hash with the cache TTL.
+// It may still be reorged, so it expires like any other cached value.
+func (r *repository) CacheBlock(ctx context.Context, block domain.Block) error {
+	val, err := json.Marshal(block)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.redisDB.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, r.key(blockKeyPrefix+strconv.FormatUint(block.Number, 10)), val, r.cacheTTL)
+		pipe.Set(ctx, r.key(blockKeyPrefix+strings.ToLower(block.Hash)), val, r.cacheTTL)
+		return nil
+	})
+
+	return wrapStoreError(err, "failed to cache block")
+}
+
+// wrapStoreError classifies a Redis or Postgres failure, it returns nil when err is nil.
+// A store that did not answer in time is a timeout, any other failure makes it unavailable.
+func wrapStoreError(err error, format string, args ...interface{}) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return domain.NewError(domain.KindTimeout, err, format, args...)
+	}
+	return domain.NewError(domain.KindUpstreamUnavailable, err, format, args...)
+}
@@ -0,0 +1,273 @@
+package webhook
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/aisalamdag23/etherstats/internal/domain"
+	"github.com/jmoiron/sqlx"
+)
+
+type repository struct {
+	db *sqlx.DB
+}
+
+const (
+	webhookColumns  = `id, api_key_id, chain_id, address, url, secret, threshold_wei, created_at`
+	deliveryColumns = `id, webhook_id, event, payload, status, attempts, next_attempt_at, last_error, response_status, created_at, updated_at`
+)
+
+// NewRepository creates the repository of webhooks and their deliveries.
+func NewRepository(db *sqlx.DB) domain.WebhookRepository {
+	return &repository{
+		db: db,
+	}
+}
+
+// CreateWebhook saves a new webhook and returns it with its ID.
+func (r *repository) CreateWebhook(ctx context.Context, webhook domain.Webhook) (*domain.Webhook, error) {
+	query := `INSERT INTO webhooks
+				(api_key_id, chain_id, address, url, secret, threshold_wei)
+			  VALUES
+				($1, $2, $3, $4, $5, $6)
+			  RETURNING ` + webhookColumns + `;`
+
+	var created domain.Webhook
+	err := r.db.GetContext(ctx, &created, query, webhook.APIKeyID, webhook.ChainID, webhook.Address, webhook.URL, webhook.Secret, webhook.ThresholdWei)
+	if err != nil {
+		return nil, wrapStoreError(err, "failed to create webhook")
+	}
+
+	return &created, nil
+}
+
+// GetWebhook retrieves a webhook by ID.
+// If the webhook does not exist, it returns nil.
+func (r *repository) GetWebhook(ctx context.Context, id int64) (*domain.Webhook, error) {
+	query := `SELECT ` + webhookColumns + `
+			  FROM webhooks
+			  WHERE id = $1;`
+
+	var webhook domain.Webhook
+	err := r.db.GetContext(ctx, &webhook, query, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, wrapStoreError(err, "failed to read webhook")
+	}
+
+	return &webhook, nil
+}
+
+// ListWebhooks retrieves the webhooks of an API key, oldest first.
+// A nil key matches the webhooks created without authentication.
+func (r *repository) ListWebhooks(ctx context.Context, apiKeyID *int64) ([]domain.Webhook, error) {
+	query := `SELECT ` + webhookColumns + `
+			  FROM webhooks
+			  WHERE api_key_id IS NOT DISTINCT FROM $1
+			  ORDER BY id;`
+
+	webhooks := []domain.Webhook{}
+	if err := r.db.SelectContext(ctx, &webhooks, query, apiKeyID); err != nil {
+		return nil, wrapStoreError(err, "failed to read webhooks")
+	}
+
+	return webhooks, nil
+}
+
+// ListChainWebhooks retrieves the webhooks of a chain, oldest first.
+func (r *repository) ListChainWebhooks(ctx context.Context, chainID uint64) ([]domain.Webhook, error) {
+	query := `SELECT ` + webhookColumns + `
+			  FROM webhooks
+			  WHERE chain_id = $1
+			  ORDER BY id;`
+
+	webhooks := []domain.Webhook{}
+	if err := r.db.SelectContext(ctx, &webhooks, query, chainID); err != nil {
+		return nil, wrapStoreError(err, "failed to read webhooks")
+	}
+
+	return webhooks, nil
+}
+
+// DeleteWebhook deletes a webhook of an API key, its deliveries are deleted along by the foreign key.
+// It reports whether the webhook existed.
+func (r *repository) DeleteWebhook(ctx context.Context, apiKeyID *int64, id int64) (bool, error) {
+	query := `DELETE FROM webhooks
+			  WHERE id = $1 AND api_key_id IS NOT DISTINCT FROM $2;`
+
+	res, err := r.db.ExecContext(ctx, query, id, apiKeyID)
+	if err != nil {
+		return false, wrapStoreError(err, "failed to delete webhook")
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, wrapStoreError(err, "failed to delete webhook")
+	}
+
+	return n > 0, nil
+}
+
+// CreateDeliveries saves new pending deliveries, due right away.
+func (r *repository) CreateDeliveries(ctx context.Context, deliveries []domain.WebhookDelivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+
+	rows := make([]map[string]interface{}, 0, len(deliveries))
+	for _, d := range deliveries {
+		rows = append(rows, map[string]interface{}{
+			"webhook_id": d.WebhookID,
+			"event":      d.Event,
+			"payload":    []byte(d.Payload),
+		})
+	}
+
+	query := `INSERT INTO webhook_deliveries
+				(webhook_id, event, payload)
+			  VALUES
+				(:webhook_id, :event, :payload);`
+
+	_, err := r.db.NamedExecContext(ctx, query, rows)
+	return wrapStoreError(err, "failed to create webhook deliveries")
+}
+
+// ListDeliveries retrieves the most recent deliveries of a webhook, newest first.
+func (r *repository) ListDeliveries(ctx context.Context, webhookID int64, limit int) ([]domain.WebhookDelivery, error) {
+	query := `SELECT ` + deliveryColumns + `
+			  FROM webhook_deliveries
+			  WHERE webhook_id = $1
+			  ORDER BY id DESC
+			  LIMIT $2;`
+
+	deliveries := []domain.WebhookDelivery{}
+	if err := r.db.SelectContext(ctx, &deliveries, query, webhookID, limit); err != nil {
+		return nil, wrapStoreError(err, "failed to read webhook deliveries")
+	}
+
+	return deliveries, nil
+}
+
+// ClaimDueDeliveries leases up to limit pending deliveries due by now, oldest due first.
+// The lease pushes their next attempt back, so they are claimed again if the claimer dies
+// before updating them. SKIP LOCKED keeps replicas from claiming the same deliveries.
+func (r *repository) ClaimDueDeliveries(ctx context.Context, limit int, lease time.Duration) ([]domain.WebhookDelivery, error) {
+	query := `UPDATE webhook_deliveries
+			  SET next_attempt_at = NOW() + make_interval(secs => $2)
+			  WHERE id IN (
+				SELECT id
+				FROM webhook_deliveries
+				WHERE status = $3 AND next_attempt_at <= NOW()
+				ORDER BY next_attempt_at
+				LIMIT $1
+				FOR UPDATE SKIP LOCKED
+			  )
+			  RETURNING ` + deliveryColumns + `;`
+
+	deliveries := []domain.WebhookDelivery{}
+	err := r.db.SelectContext(ctx, &deliveries, query, limit, lease.Seconds(), domain.DeliveryPending)
+	if err != nil {
+		return nil, wrapStoreError(err, "failed to claim webhook deliveries")
+	}
+
+	return deliveries, nil
+}
+
+// UpdateDelivery saves the status, attempts and outcome of the last attempt of a delivery.
+func (r *repository) UpdateDelivery(ctx context.Context, delivery domain.WebhookDelivery) error {
+	query := `UPDATE webhook_deliveries
+			  SET status = $2, attempts = $3, next_attempt_at = $4, last_error = $5, response_status = $6, updated_at = NOW()
+			  WHERE id = $1;`
+
+	_, err := r.db.ExecContext(ctx, query, delivery.ID, delivery.Status, delivery.Attempts,
+		delivery.NextAttemptAt, delivery.LastError, delivery.ResponseStatus)
+	return wrapStoreError(err, "failed to update webhook delivery")
+}
+
+// ReplayDelivery makes a delivery of a webhook pending and due now, with its attempts reset.
+// If the webhook has no such delivery, it returns nil.
+func (r *repository) ReplayDelivery(ctx context.Context, webhookID, id int64) (*domain.WebhookDelivery, error) {
+	query := `UPDATE webhook_deliveries
+			  SET status = $3, attempts = 0, next_attempt_at = NOW(), updated_at = NOW()
+			  WHERE webhook_id = $1 AND id = $2
+			  RETURNING ` + deliveryColumns + `;`
+
+	var delivery domain.WebhookDelivery
+	err := r.db.GetContext(ctx, &delivery, query, webhookID, id, domain.DeliveryPending)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, wrapStoreError(err, "failed to replay webhook delivery")
+	}
+
+	return &delivery, nil
+}
+
+// GetMonitoredBalances retrieves the balances the monitor last compared addresses of a chain at,
+// keyed by lowercase address.
+func (r *repository) GetMonitoredBalances(ctx context.Context, chainID uint64, addresses []string) (map[string]string, error) {
+	lower := make([]string, 0, len(addresses))
+	for _, address := range addresses {
+		lower = append(lower, strings.ToLower(address))
+	}
+
+	query := `SELECT address, balance
+			  FROM webhook_balances
+			  WHERE chain_id = $1 AND address = ANY($2);`
+
+	rows := []domain.AddressBalance{}
+	if err := r.db.SelectContext(ctx, &rows, query, chainID, lower); err != nil {
+		return nil, wrapStoreError(err, "failed to read monitored balances")
+	}
+
+	balances := make(map[string]string, len(rows))
+	for _, row := range rows {
+		balances[row.Address] = row.Balance
+	}
+	return balances, nil
+}
+
+// SaveMonitoredBalances upserts the balances of addresses of a chain at a block with a single statement.
+// A balance read at an older block than the saved one is ignored.
+func (r *repository) SaveMonitoredBalances(ctx context.Context, chainID, block uint64, balances []domain.AddressBalance) error {
+	if len(balances) == 0 {
+		return nil
+	}
+
+	rows := make([]map[string]interface{}, 0, len(balances))
+	for _, b := range balances {
+		rows = append(rows, map[string]interface{}{
+			"chain_id":     chainID,
+			"address":      strings.ToLower(b.Address),
+			"balance":      b.Balance,
+			"block_number": block,
+		})
+	}
+
+	query := `INSERT INTO webhook_balances
+				(chain_id, address, balance, block_number)
+			  VALUES
+				(:chain_id, :address, :balance, :block_number)
+			  ON CONFLICT (chain_id, address) DO UPDATE
+			  SET balance = EXCLUDED.balance, block_number = EXCLUDED.block_number, updated_at = NOW()
+			  WHERE webhook_balances.block_number < EXCLUDED.block_number;`
+
+	_, err := r.db.NamedExecContext(ctx, query, rows)
+	return wrapStoreError(err, "failed to save monitored balances")
+}
+
+// wrapStoreError classifies a Postgres failure, it returns nil when err is nil.
+func wrapStoreError(err error, format string, args ...interface{}) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return domain.NewError(domain.KindTimeout, err, format, args...)
+	}
+	return domain.NewError(domain.KindUpstreamUnavailable, err, format, args...)
+}
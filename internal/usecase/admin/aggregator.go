@@ -0,0 +1,44 @@
+package admin
+
+import (
+	"context"
+	"time"
+
+	"github.com/aisalamdag23/etherstats/internal/domain"
+	"github.com/aisalamdag23/etherstats/internal/infrastructure/logger"
+	"go.uber.org/zap"
+)
+
+type aggregator struct {
+	service  domain.AdminService
+	interval time.Duration
+	lgr      *zap.Logger
+}
+
+// NewUsageAggregator creates a worker copying the usage counters of API keys
+// from Redis into Postgres every interval.
+func NewUsageAggregator(service domain.AdminService, interval time.Duration, lgr *zap.Logger) domain.Worker {
+	return &aggregator{
+		service:  service,
+		interval: interval,
+		lgr:      lgr,
+	}
+}
+
+// Run aggregates usage on every interval until ctx is done.
+func (a *aggregator) Run(ctx context.Context) error {
+	ctx = logger.ToContext(ctx, a.lgr)
+	ticker := time.NewTicker(a.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if err := a.service.AggregateUsage(ctx); err != nil {
+				logger.Extract(ctx).Error("failed to aggregate api key usage", zap.Error(err))
+			}
+		}
+	}
+}
@@ -0,0 +1,242 @@
+package admin
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/aisalamdag23/etherstats/internal/domain"
+	"github.com/aisalamdag23/etherstats/internal/usecase/auth"
+)
+
+const (
+	// maxNameLength is the length of the name column
+	maxNameLength = 255
+	// maxUsageDays caps the range of a single usage query
+	maxUsageDays = 366
+	// defaultUsageDays is the range of usage queries without bounds, ending today
+	defaultUsageDays = 30
+)
+
+type (
+	service struct {
+		repository domain.APIKeyRepository
+		meter      domain.UsageMeter
+		opts       Options
+	}
+
+	// Options are the defaults and constraints of new keys
+	Options struct {
+		// Chains are the names of the served chains, the only ones a key may be allowed
+		Chains []string
+		// DefaultRatePerSec, DefaultBurst and DefaultDailyQuota apply to keys created without them
+		DefaultRatePerSec int
+		DefaultBurst      int
+		DefaultDailyQuota int
+	}
+)
+
+// NewService creates the service managing API keys and aggregating their usage from meter.
+func NewService(repository domain.APIKeyRepository, meter domain.UsageMeter, opts Options) domain.AdminService {
+	return &service{
+		repository: repository,
+		meter:      meter,
+		opts:       opts,
+	}
+}
+
+// CreateKey creates a key with the given settings, defaults filling in the missing limits.
+// The secret is only returned here, it is never stored.
+func (s *service) CreateKey(ctx context.Context, params domain.APIKeyParams) (*domain.CreatedAPIKey, error) {
+	key := domain.APIKey{
+		RatePerSec: s.opts.DefaultRatePerSec,
+		Burst:      s.opts.DefaultBurst,
+		DailyQuota: s.opts.DefaultDailyQuota,
+	}
+	if err := s.apply(&key, params); err != nil {
+		return nil, err
+	}
+
+	raw, prefix, hash, err := auth.GenerateKey()
+	if err != nil {
+		return nil, err
+	}
+	key.Prefix, key.KeyHash = prefix, hash
+
+	created, err := s.repository.CreateAPIKey(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &domain.CreatedAPIKey{APIKey: *created, Key: raw}, nil
+}
+
+// ListKeys lists every key, revoked or not.
+func (s *service) ListKeys(ctx context.Context) ([]domain.APIKey, error) {
+	return s.repository.ListAPIKeys(ctx)
+}
+
+// GetKey retrieves a key by ID.
+func (s *service) GetKey(ctx context.Context, id int64) (*domain.APIKey, error) {
+	key, err := s.repository.GetAPIKey(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return nil, domain.NotFoundError("api key %d not found", id)
+	}
+
+	return key, nil
+}
+
+// UpdateKey changes the settings of an active key, leaving the ones missing from params unchanged.
+func (s *service) UpdateKey(ctx context.Context, id int64, params domain.APIKeyParams) (*domain.APIKey, error) {
+	key, err := s.activeKey(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.apply(key, params); err != nil {
+		return nil, err
+	}
+
+	updated, err := s.repository.UpdateAPIKey(ctx, *key)
+	if err != nil {
+		return nil, err
+	}
+	if updated == nil {
+		return nil, domain.NotFoundError("api key %d not found or revoked", id)
+	}
+
+	return updated, nil
+}
+
+// RotateKey replaces the secret of an active key. The old secret stops working at once.
+func (s *service) RotateKey(ctx context.Context, id int64) (*domain.CreatedAPIKey, error) {
+	raw, prefix, hash, err := auth.GenerateKey()
+	if err != nil {
+		return nil, err
+	}
+
+	rotated, err := s.repository.RotateAPIKey(ctx, id, prefix, hash)
+	if err != nil {
+		return nil, err
+	}
+	if rotated == nil {
+		return nil, domain.NotFoundError("api key %d not found or revoked", id)
+	}
+
+	return &domain.CreatedAPIKey{APIKey: *rotated, Key: raw}, nil
+}
+
+// RevokeKey revokes a key. It stops working at once and cannot be restored.
+func (s *service) RevokeKey(ctx context.Context, id int64) (*domain.APIKey, error) {
+	revoked, err := s.repository.RevokeAPIKey(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if revoked == nil {
+		return nil, domain.NotFoundError("api key %d not found", id)
+	}
+
+	return revoked, nil
+}
+
+// GetUsage retrieves the aggregated usage of a key between two UTC days, both included.
+// Missing bounds default to the last 30 days.
+func (s *service) GetUsage(ctx context.Context, query domain.UsageQuery) ([]domain.APIKeyUsage, error) {
+	if query.To.IsZero() {
+		query.To = time.Now().UTC().Truncate(24 * time.Hour)
+	}
+	if query.From.IsZero() {
+		query.From = query.To.AddDate(0, 0, -(defaultUsageDays - 1))
+	}
+	if query.To.Before(query.From) {
+		return nil, domain.InvalidInputError("from must not be after to")
+	}
+	if days := int(query.To.Sub(query.From).Hours() / 24); days >= maxUsageDays {
+		return nil, domain.InvalidInputError("at most %d days of usage can be queried at once", maxUsageDays)
+	}
+	if _, err := s.GetKey(ctx, query.APIKeyID); err != nil {
+		return nil, err
+	}
+
+	return s.repository.GetUsage(ctx, query)
+}
+
+// AggregateUsage copies the usage counters of the meter into the repository.
+// Counters are totals of the day, so aggregating the same day again only updates it.
+func (s *service) AggregateUsage(ctx context.Context) error {
+	usage, err := s.meter.Usage(ctx)
+	if err != nil {
+		return err
+	}
+
+	return s.repository.SaveUsage(ctx, usage)
+}
+
+// activeKey retrieves a key that is not revoked.
+func (s *service) activeKey(ctx context.Context, id int64) (*domain.APIKey, error) {
+	key, err := s.GetKey(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if key.RevokedAt != nil {
+		return nil, domain.InvalidInputError("api key %d is revoked", id)
+	}
+
+	return key, nil
+}
+
+// apply validates params and sets them on key.
+func (s *service) apply(key *domain.APIKey, params domain.APIKeyParams) error {
+	if params.Name != nil {
+		key.Name = strings.TrimSpace(*params.Name)
+	}
+	if key.Name == "" || len(key.Name) > maxNameLength {
+		return domain.InvalidInputError("name must be between 1 and %d characters", maxNameLength)
+	}
+
+	if params.RatePerSec != nil {
+		key.RatePerSec = *params.RatePerSec
+	}
+	if params.Burst != nil {
+		key.Burst = *params.Burst
+	}
+	if params.DailyQuota != nil {
+		key.DailyQuota = *params.DailyQuota
+	}
+	if key.RatePerSec <= 0 || key.Burst <= 0 {
+		return domain.InvalidInputError("ratePerSec and burst must be positive")
+	}
+	if key.DailyQuota < 0 {
+		return domain.InvalidInputError("dailyQuota must not be negative, 0 is unlimited")
+	}
+
+	if params.AllowedChains != nil {
+		chains := make([]string, 0, len(*params.AllowedChains))
+		for _, chain := range *params.AllowedChains {
+			name, ok := s.chainName(chain)
+			if !ok {
+				return domain.InvalidInputError("unknown chain %q", chain)
+			}
+			chains = append(chains, name)
+		}
+		key.AllowedChains = chains
+	}
+
+	if params.IsAdmin != nil {
+		key.IsAdmin = *params.IsAdmin
+	}
+
+	return nil
+}
+
+// chainName returns the configured spelling of a served chain.
+func (s *service) chainName(chain string) (string, bool) {
+	for _, name := range s.opts.Chains {
+		if strings.EqualFold(name, strings.TrimSpace(chain)) {
+			return name, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,69 @@
+package alchemy
+
+import (
+	"context"
+	"time"
+
+	"github.com/aisalamdag23/etherstats/internal/domain"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// rpcBlock is a block as returned by eth_getBlockByNumber and eth_getBlockByHash with full transactions
+type rpcBlock struct {
+	Number        *hexutil.Big     `json:"number"`
+	Hash          *common.Hash     `json:"hash"`
+	ParentHash    common.Hash      `json:"parentHash"`
+	Timestamp     hexutil.Uint64   `json:"timestamp"`
+	Miner         common.Address   `json:"miner"`
+	GasUsed       hexutil.Uint64   `json:"gasUsed"`
+	GasLimit      hexutil.Uint64   `json:"gasLimit"`
+	BaseFeePerGas *hexutil.Big     `json:"baseFeePerGas"`
+	BlobGasUsed   *hexutil.Uint64  `json:"blobGasUsed"`
+	ExcessBlobGas *hexutil.Uint64  `json:"excessBlobGas"`
+	Transactions  []rpcTransaction `json:"transactions"`
+}
+
+// GetBlock fetches a block with its full transactions, by hash when set, by number otherwise.
+func (s *service) GetBlock(ctx context.Context, ref domain.BlockRef) (*domain.Block, error) {
+	var (
+		block *rpcBlock
+		err   error
+		id    string
+	)
+	if ref.Hash != "" {
+		id = ref.Hash
+		err = s.client.Client().CallContext(ctx, &block, "eth_getBlockByHash", common.HexToHash(ref.Hash), true)
+	} else {
+		id = domain.NumberedBlock(ref.Number).String()
+		err = s.client.Client().CallContext(ctx, &block, "eth_getBlockByNumber", id, true)
+	}
+	if err != nil {
+		return nil, wrapRPCError(err, "failed to fetch block %s", id)
+	}
+	// the pending block has neither a number nor a hash, it is never asked for
+	if block == nil || block.Number == nil || block.Hash == nil {
+		return nil, domain.NotFoundError("block %s not found", id)
+	}
+
+	res := &domain.Block{
+		BlockHeader: domain.BlockHeader{
+			Number:        block.Number.ToInt().Uint64(),
+			Hash:          block.Hash.Hex(),
+			ParentHash:    block.ParentHash.Hex(),
+			Timestamp:     time.Unix(int64(block.Timestamp), 0).UTC(),
+			Miner:         block.Miner.Hex(),
+			GasUsed:       uint64(block.GasUsed),
+			GasLimit:      uint64(block.GasLimit),
+			BaseFeePerGas: feeAmount(block.BaseFeePerGas),
+			BlobGasUsed:   (*uint64)(block.BlobGasUsed),
+			ExcessBlobGas: (*uint64)(block.ExcessBlobGas),
+		},
+		Transactions: make([]domain.Transaction, 0, len(block.Transactions)),
+	}
+	for i := range block.Transactions {
+		res.Transactions = append(res.Transactions, newTransaction(&block.Transactions[i]))
+	}
+
+	return res, nil
+}
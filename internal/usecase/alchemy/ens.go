@@ -0,0 +1,76 @@
+package alchemy
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aisalamdag23/etherstats/internal/domain"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// ensRegistry is the address of the ENS registry on mainnet and its testnets
+var ensRegistry = common.HexToAddress("0x00000000000C2E074eC69A0dFb2997BA6C7d2e1e")
+
+// ensABI is the subset of the ENS registry and resolver interfaces used to resolve addresses
+const ensABI = `[
+	{"constant":true,"inputs":[{"name":"node","type":"bytes32"}],"name":"resolver","outputs":[{"name":"","type":"address"}],"type":"function"},
+	{"constant":true,"inputs":[{"name":"node","type":"bytes32"}],"name":"addr","outputs":[{"name":"","type":"address"}],"type":"function"}
+]`
+
+var ens = mustParseABI(ensABI)
+
+// ResolveENS resolves an ENS name to a checksummed address through the registry and the name's resolver.
+// The name is expected to be normalized already; only lower casing is applied here.
+func (s *service) ResolveENS(ctx context.Context, name string) (string, error) {
+	node := namehash(name)
+
+	resolver, err := s.callAddress(ctx, ensRegistry, "resolver", node)
+	if err != nil {
+		return "", err
+	}
+	if resolver == (common.Address{}) {
+		return "", domain.NotFoundError("ens name %q has no resolver", name)
+	}
+
+	addr, err := s.callAddress(ctx, resolver, "addr", node)
+	if err != nil {
+		return "", err
+	}
+	if addr == (common.Address{}) {
+		return "", domain.NotFoundError("ens name %q does not resolve to an address", name)
+	}
+
+	return addr.Hex(), nil
+}
+
+// callAddress calls an ENS method returning a single address.
+func (s *service) callAddress(ctx context.Context, contract common.Address, method string, node [32]byte) (common.Address, error) {
+	out, err := s.callContract(ctx, contract, ens, method, node)
+	if err != nil {
+		return common.Address{}, err
+	}
+	res, err := ens.Unpack(method, out)
+	if err != nil {
+		return common.Address{}, fmt.Errorf("failed to decode %s: %v", method, err)
+	}
+
+	return res[0].(common.Address), nil
+}
+
+// namehash computes the EIP-137 node of a name.
+func namehash(name string) [32]byte {
+	var node [32]byte
+	if name == "" {
+		return node
+	}
+
+	labels := strings.Split(strings.ToLower(name), ".")
+	for i := len(labels) - 1; i >= 0; i-- {
+		label := crypto.Keccak256([]byte(labels[i]))
+		copy(node[:], crypto.Keccak256(node[:], label))
+	}
+
+	return node
+}
@@ -0,0 +1,112 @@
+package alchemy
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/aisalamdag23/etherstats/internal/domain"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// erc20ABI is the subset of the ERC-20 interface used to read balances and metadata
+const erc20ABI = `[
+	{"constant":true,"inputs":[{"name":"owner","type":"address"}],"name":"balanceOf","outputs":[{"name":"","type":"uint256"}],"type":"function"},
+	{"constant":true,"inputs":[],"name":"decimals","outputs":[{"name":"","type":"uint8"}],"type":"function"},
+	{"constant":true,"inputs":[],"name":"symbol","outputs":[{"name":"","type":"string"}],"type":"function"}
+]`
+
+// erc20Bytes32ABI covers early tokens (e.g. MKR) that return their symbol as bytes32
+const erc20Bytes32ABI = `[
+	{"constant":true,"inputs":[],"name":"symbol","outputs":[{"name":"","type":"bytes32"}],"type":"function"}
+]`
+
+var (
+	erc20        = mustParseABI(erc20ABI)
+	erc20Bytes32 = mustParseABI(erc20Bytes32ABI)
+)
+
+func mustParseABI(def string) abi.ABI {
+	parsed, err := abi.JSON(strings.NewReader(def))
+	if err != nil {
+		panic(err)
+	}
+	return parsed
+}
+
+// GetTokenMetadata fetches the symbol and decimals of an ERC-20 token contract.
+func (s *service) GetTokenMetadata(ctx context.Context, contract string) (*domain.Token, error) {
+	addr := common.HexToAddress(contract)
+
+	out, err := s.callContract(ctx, addr, erc20, "decimals")
+	if err != nil {
+		return nil, err
+	}
+	decimals, err := erc20.Unpack("decimals", out)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode decimals: %v", err)
+	}
+
+	out, err = s.callContract(ctx, addr, erc20, "symbol")
+	if err != nil {
+		return nil, err
+	}
+
+	return &domain.Token{
+		Contract: addr.Hex(),
+		Symbol:   decodeSymbol(out),
+		Decimals: decimals[0].(uint8),
+	}, nil
+}
+
+// GetTokenBalance fetches the balance of a holder in an ERC-20 token contract.
+// It returns the balance in the token's smallest unit.
+func (s *service) GetTokenBalance(ctx context.Context, contract, holder string) (*big.Int, error) {
+	out, err := s.callContract(ctx, common.HexToAddress(contract), erc20, "balanceOf", common.HexToAddress(holder))
+	if err != nil {
+		return nil, err
+	}
+	balance, err := erc20.Unpack("balanceOf", out)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode balanceOf: %v", err)
+	}
+
+	return balance[0].(*big.Int), nil
+}
+
+// callContract packs the call of a read-only contract method and runs it with eth_call at the latest block.
+// It is shared by every contract read of the service.
+func (s *service) callContract(ctx context.Context, contract common.Address, contractABI abi.ABI, method string, args ...interface{}) ([]byte, error) {
+	data, err := contractABI.Pack(method, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := s.client.CallContract(ctx, ethereum.CallMsg{To: &contract, Data: data}, nil) // nil = latest block
+	if err != nil {
+		return nil, wrapRPCError(err, "failed to call %s on %s", method, contract.Hex())
+	}
+	if len(out) == 0 {
+		// calls to an address without code succeed with an empty result
+		return nil, domain.NotFoundError("no contract at %s", contract.Hex())
+	}
+
+	return out, nil
+}
+
+// decodeSymbol decodes the symbol as a string, falling back to bytes32.
+func decodeSymbol(out []byte) string {
+	if symbol, err := erc20.Unpack("symbol", out); err == nil {
+		return symbol[0].(string)
+	}
+	if symbol, err := erc20Bytes32.Unpack("symbol", out); err == nil {
+		raw := symbol[0].([32]byte)
+		return string(bytes.TrimRight(raw[:], "\x00"))
+	}
+
+	return ""
+}
@@ -0,0 +1,41 @@
+package alchemy
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+
+	"github.com/aisalamdag23/etherstats/internal/domain"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+const (
+	// errCodeExecutionReverted is returned by eth_call when the contract reverts
+	errCodeExecutionReverted = 3
+	// errCodeLimitExceeded is returned by providers throttling requests
+	errCodeLimitExceeded = -32005
+)
+
+// wrapRPCError classifies a failed JSON-RPC call into a domain error kind.
+func wrapRPCError(err error, format string, args ...interface{}) error {
+	kind := domain.KindUpstreamUnavailable
+
+	var (
+		httpErr rpc.HTTPError
+		rpcErr  rpc.Error
+	)
+	switch {
+	case errors.Is(err, context.DeadlineExceeded) || os.IsTimeout(err):
+		kind = domain.KindTimeout
+	case errors.As(err, &httpErr) && httpErr.StatusCode == http.StatusTooManyRequests:
+		kind = domain.KindUpstreamRateLimited
+	case errors.As(err, &rpcErr) && (rpcErr.ErrorCode() == errCodeLimitExceeded || rpcErr.ErrorCode() == http.StatusTooManyRequests):
+		kind = domain.KindUpstreamRateLimited
+	case errors.As(err, &rpcErr) && rpcErr.ErrorCode() == errCodeExecutionReverted:
+		// the caller pointed us at a contract that does not support the call
+		kind = domain.KindInvalidInput
+	}
+
+	return domain.NewError(kind, err, format, args...)
+}
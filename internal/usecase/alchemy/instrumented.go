@@ -0,0 +1,142 @@
+package alchemy
+
+import (
+	"context"
+	"math/big"
+	"time"
+
+	"github.com/aisalamdag23/etherstats/internal/domain"
+	"github.com/aisalamdag23/etherstats/internal/infrastructure/metrics"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("github.com/aisalamdag23/etherstats/internal/usecase/alchemy")
+
+// instrumented traces and records the latency and errors of every call of the wrapped service
+type instrumented struct {
+	next domain.AlchemyAPIService
+	// endpoint is the host of the JSON-RPC endpoint, the URL itself may hold credentials
+	endpoint string
+}
+
+func (s *instrumented) GetGasPrice(ctx context.Context) (string, error) {
+	return observe(ctx, s, "GetGasPrice", "eth_gasPrice", func(ctx context.Context) (string, error) {
+		return s.next.GetGasPrice(ctx)
+	})
+}
+
+func (s *instrumented) GetLatestBlockNumber(ctx context.Context) (uint64, error) {
+	return observe(ctx, s, "GetLatestBlockNumber", "eth_blockNumber", func(ctx context.Context) (uint64, error) {
+		return s.next.GetLatestBlockNumber(ctx)
+	})
+}
+
+func (s *instrumented) GetBalance(ctx context.Context, address string, block domain.BlockSelector) (*domain.BlockBalance, error) {
+	return observe(ctx, s, "GetBalance", "eth_getBalance", func(ctx context.Context) (*domain.BlockBalance, error) {
+		return s.next.GetBalance(ctx, address, block)
+	})
+}
+
+func (s *instrumented) GetBalances(ctx context.Context, addresses []string, block domain.BlockSelector) (*domain.BlockBalances, error) {
+	return observe(ctx, s, "GetBalances", "eth_getBalance", func(ctx context.Context) (*domain.BlockBalances, error) {
+		return s.next.GetBalances(ctx, addresses, block)
+	})
+}
+
+func (s *instrumented) GetGasTipCap(ctx context.Context) (*big.Int, error) {
+	return observe(ctx, s, "GetGasTipCap", "eth_maxPriorityFeePerGas", func(ctx context.Context) (*big.Int, error) {
+		return s.next.GetGasTipCap(ctx)
+	})
+}
+
+func (s *instrumented) GetGasPriceWei(ctx context.Context) (*big.Int, error) {
+	return observe(ctx, s, "GetGasPriceWei", "eth_gasPrice", func(ctx context.Context) (*big.Int, error) {
+		return s.next.GetGasPriceWei(ctx)
+	})
+}
+
+func (s *instrumented) GetFeeHistory(ctx context.Context, blockCount uint64, percentiles []float64) (*domain.FeeHistory, error) {
+	return observe(ctx, s, "GetFeeHistory", "eth_feeHistory", func(ctx context.Context) (*domain.FeeHistory, error) {
+		return s.next.GetFeeHistory(ctx, blockCount, percentiles)
+	})
+}
+
+func (s *instrumented) GetTokenMetadata(ctx context.Context, contract string) (*domain.Token, error) {
+	return observe(ctx, s, "GetTokenMetadata", "eth_call", func(ctx context.Context) (*domain.Token, error) {
+		return s.next.GetTokenMetadata(ctx, contract)
+	})
+}
+
+func (s *instrumented) GetTokenBalance(ctx context.Context, contract, holder string) (*big.Int, error) {
+	return observe(ctx, s, "GetTokenBalance", "eth_call", func(ctx context.Context) (*big.Int, error) {
+		return s.next.GetTokenBalance(ctx, contract, holder)
+	})
+}
+
+func (s *instrumented) SubscribeNewHeads(ctx context.Context, heads chan<- uint64) (domain.Subscription, error) {
+	return observe(ctx, s, "SubscribeNewHeads", "eth_subscribe", func(ctx context.Context) (domain.Subscription, error) {
+		return s.next.SubscribeNewHeads(ctx, heads)
+	})
+}
+
+func (s *instrumented) ResolveENS(ctx context.Context, name string) (string, error) {
+	return observe(ctx, s, "ResolveENS", "eth_call", func(ctx context.Context) (string, error) {
+		return s.next.ResolveENS(ctx, name)
+	})
+}
+
+func (s *instrumented) GetChainID(ctx context.Context) (uint64, error) {
+	return observe(ctx, s, "GetChainID", "eth_chainId", func(ctx context.Context) (uint64, error) {
+		return s.next.GetChainID(ctx)
+	})
+}
+
+func (s *instrumented) GetHead(ctx context.Context, block domain.BlockSelector) (*domain.Head, error) {
+	return observe(ctx, s, "GetHead", "eth_getBlockByNumber", func(ctx context.Context) (*domain.Head, error) {
+		return s.next.GetHead(ctx, block)
+	})
+}
+
+func (s *instrumented) GetTransaction(ctx context.Context, hash string) (*domain.TransactionDetails, error) {
+	return observe(ctx, s, "GetTransaction", "eth_getTransactionByHash", func(ctx context.Context) (*domain.TransactionDetails, error) {
+		return s.next.GetTransaction(ctx, hash)
+	})
+}
+
+func (s *instrumented) GetBlock(ctx context.Context, ref domain.BlockRef) (*domain.Block, error) {
+	rpcMethod := "eth_getBlockByNumber"
+	if ref.Hash != "" {
+		rpcMethod = "eth_getBlockByHash"
+	}
+	return observe(ctx, s, "GetBlock", rpcMethod, func(ctx context.Context) (*domain.Block, error) {
+		return s.next.GetBlock(ctx, ref)
+	})
+}
+
+// observe runs fn in a client span named after the method, recording its latency and,
+// when it fails, the kind of its error. rpcMethod is the JSON-RPC method the call is made of,
+// or the main one when it takes several.
+func observe[T any](ctx context.Context, s *instrumented, method, rpcMethod string, fn func(ctx context.Context) (T, error)) (T, error) {
+	ctx, span := tracer.Start(ctx, "rpc."+method, trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("rpc.system", "jsonrpc"),
+			attribute.String("rpc.method", rpcMethod),
+			attribute.String("server.address", s.endpoint),
+		))
+	defer span.End()
+
+	start := time.Now()
+	res, err := fn(ctx)
+	metrics.RPCRequestDuration.WithLabelValues(s.endpoint, method).Observe(metrics.Since(start))
+	if err != nil {
+		kind := domain.KindOf(err).String()
+		metrics.RPCErrors.WithLabelValues(s.endpoint, method, kind).Inc()
+		span.RecordError(err)
+		span.SetStatus(codes.Error, kind)
+	}
+
+	return res, err
+}
@@ -3,8 +3,9 @@ package alchemy
 import (
 	"context"
 	"fmt"
-	"math"
 	"math/big"
+	"net/url"
+	"time"
 
 	"github.com/aisalamdag23/etherstats/internal/domain"
 
@@ -14,21 +15,40 @@ import (
 	// and perform operations like fetching gas prices, block numbers, and balances.
 	// Visit: https://geth.ethereum.org/docs/developers/dapp-developer/native
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/rpc"
 )
 
 type service struct {
 	client *ethclient.Client
 }
 
+// NewService creates a service backed by Alchemy, whose endpoints take the API key as the last path segment.
 func NewService(baseURL, apiKey string) (domain.AlchemyAPIService, error) {
-	client, err := ethclient.Dial(baseURL + "/" + apiKey)
+	return NewServiceFromURL(baseURL + "/" + apiKey)
+}
+
+// NewServiceFromURL creates a service backed by any Ethereum JSON-RPC endpoint
+// (Alchemy, Infura, a self-hosted node...), over HTTP or WebSocket.
+func NewServiceFromURL(rpcURL string) (domain.AlchemyAPIService, error) {
+	u, err := url.Parse(rpcURL)
+	if err != nil {
+		return nil, fmt.Errorf("malformed Ethereum node URL: %v", err)
+	}
+
+	client, err := ethclient.Dial(rpcURL)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to Ethereum node: %v", err)
 	}
 
-	return &service{
-		client: client,
+	return &instrumented{
+		next: &service{
+			client: client,
+		},
+		endpoint: u.Host,
 	}, nil
 }
 
@@ -37,10 +57,10 @@ func NewService(baseURL, apiKey string) (domain.AlchemyAPIService, error) {
 func (s *service) GetGasPrice(ctx context.Context) (string, error) {
 	gasPrice, err := s.client.SuggestGasPrice(ctx)
 	if err != nil {
-		return "", fmt.Errorf("failed to fetch gas price: %v", err)
+		return "", wrapRPCError(err, "failed to fetch gas price")
 	}
 
-	return s.convertToETH(gasPrice), nil
+	return domain.FormatWei(gasPrice, domain.UnitEther), nil
 }
 
 // GetLatestBlockNumber fetches the latest block number from the Ethereum network.
@@ -48,25 +68,218 @@ func (s *service) GetGasPrice(ctx context.Context) (string, error) {
 func (s *service) GetLatestBlockNumber(ctx context.Context) (uint64, error) {
 	blockNumber, err := s.client.BlockNumber(ctx)
 	if err != nil {
-		return 0, fmt.Errorf("failed to fetch block number: %v", err)
+		return 0, wrapRPCError(err, "failed to fetch block number")
 	}
 
 	return blockNumber, nil
 }
 
-// GetBalance fetches the balance of a given Ethereum address.
-// It returns the balance in ETH for human readability
-func (s *service) GetBalance(ctx context.Context, address string) (string, error) {
+// GetGasTipCap fetches the priority fee per gas suggested by the node.
+// It returns the tip in wei.
+func (s *service) GetGasTipCap(ctx context.Context) (*big.Int, error) {
+	tip, err := s.client.SuggestGasTipCap(ctx)
+	if err != nil {
+		return nil, wrapRPCError(err, "failed to fetch gas tip cap")
+	}
+
+	return tip, nil
+}
+
+// GetGasPriceWei fetches the current suggested gas price from the Ethereum network.
+// It returns the gas price in wei.
+func (s *service) GetGasPriceWei(ctx context.Context) (*big.Int, error) {
+	gasPrice, err := s.client.SuggestGasPrice(ctx)
+	if err != nil {
+		return nil, wrapRPCError(err, "failed to fetch gas price")
+	}
+
+	return gasPrice, nil
+}
+
+// GetFeeHistory fetches the fee history of the most recent blocks up to the latest one.
+// Rewards are sampled at the given percentiles of each block's priority fees.
+func (s *service) GetFeeHistory(ctx context.Context, blockCount uint64, percentiles []float64) (*domain.FeeHistory, error) {
+	history, err := s.client.FeeHistory(ctx, blockCount, nil, percentiles) // nil = latest block
+	if err != nil {
+		return nil, wrapRPCError(err, "failed to fetch fee history")
+	}
+
+	return &domain.FeeHistory{
+		OldestBlock:  history.OldestBlock.Uint64(),
+		BaseFees:     history.BaseFee,
+		GasUsedRatio: history.GasUsedRatio,
+		Rewards:      history.Reward,
+	}, nil
+}
+
+// GetBalance fetches the balance of a given Ethereum address at the selected block.
+// It returns the exact balance in wei along with the resolved block.
+func (s *service) GetBalance(ctx context.Context, address string, block domain.BlockSelector) (*domain.BlockBalance, error) {
 	addr := common.HexToAddress(address)
-	balanceWei, err := s.client.BalanceAt(ctx, addr, nil) // nil = latest block
+	ref, err := s.resolveBlock(ctx, block)
+	if err != nil {
+		return nil, err
+	}
+
+	var balanceWei *big.Int
+	if ref.Hash == "" {
+		// the pending block has no hash yet
+		balanceWei, err = s.client.PendingBalanceAt(ctx, addr)
+	} else {
+		// pin the read to the block hash so a reorg in between cannot change the answer
+		balanceWei, err = s.client.BalanceAtHash(ctx, addr, common.HexToHash(ref.Hash))
+	}
+	if err != nil {
+		return nil, wrapRPCError(err, "failed to fetch balance")
+	}
+
+	return &domain.BlockBalance{
+		Wei:   balanceWei,
+		Block: *ref,
+	}, nil
+}
+
+// GetBalances fetches the balances of many Ethereum addresses with a single JSON-RPC batch.
+// Every balance is read at the same resolved block. Failures of a single address
+// are reported in its BalanceResult instead of failing the whole batch.
+func (s *service) GetBalances(ctx context.Context, addresses []string, block domain.BlockSelector) (*domain.BlockBalances, error) {
+	ref, err := s.resolveBlock(ctx, block)
+	if err != nil {
+		return nil, err
+	}
 
-	return s.convertToETH(balanceWei), err
+	// the pending block has no hash yet, otherwise pin every read to the block hash (EIP-1898)
+	var blockArg interface{} = block.String()
+	if ref.Hash != "" {
+		blockArg = map[string]interface{}{"blockHash": ref.Hash}
+	}
+
+	results := make([]domain.AddressWei, len(addresses))
+	balances := make([]hexutil.Big, len(addresses))
+	batch := make([]rpc.BatchElem, 0, len(addresses))
+	// index of each batch element in results
+	indexes := make([]int, 0, len(addresses))
+	for i, address := range addresses {
+		results[i].Address = address
+		if !common.IsHexAddress(address) {
+			results[i].Error = "invalid address"
+			continue
+		}
+		batch = append(batch, rpc.BatchElem{
+			Method: "eth_getBalance",
+			Args:   []interface{}{common.HexToAddress(address), blockArg},
+			Result: &balances[i],
+		})
+		indexes = append(indexes, i)
+	}
+
+	if len(batch) > 0 {
+		if err := s.client.Client().BatchCallContext(ctx, batch); err != nil {
+			return nil, wrapRPCError(err, "failed to fetch balances")
+		}
+	}
+
+	for j, elem := range batch {
+		i := indexes[j]
+		if elem.Error != nil {
+			results[i].Error = elem.Error.Error()
+			continue
+		}
+		results[i].Wei = balances[i].ToInt()
+	}
+
+	return &domain.BlockBalances{
+		Block:    *ref,
+		Balances: results,
+	}, nil
+}
+
+// GetChainID fetches the chain ID of the network the endpoint serves.
+func (s *service) GetChainID(ctx context.Context) (uint64, error) {
+	chainID, err := s.client.ChainID(ctx)
+	if err != nil {
+		return 0, wrapRPCError(err, "failed to fetch chain ID")
+	}
+
+	return chainID.Uint64(), nil
+}
+
+// GetHead fetches the number, hash, timestamp and base fee of a block.
+func (s *service) GetHead(ctx context.Context, block domain.BlockSelector) (*domain.Head, error) {
+	var head *struct {
+		Number        *hexutil.Big   `json:"number"`
+		Hash          *common.Hash   `json:"hash"`
+		Timestamp     hexutil.Uint64 `json:"timestamp"`
+		BaseFeePerGas *hexutil.Big   `json:"baseFeePerGas"`
+	}
+	err := s.client.Client().CallContext(ctx, &head, "eth_getBlockByNumber", block.String(), false)
+	if err != nil {
+		return nil, wrapRPCError(err, "failed to fetch block %s", block)
+	}
+	if head == nil || head.Number == nil || head.Hash == nil {
+		if block.Tag == "" {
+			// the endpoint has not seen the block yet
+			return nil, domain.NotFoundError("block %s not found", block)
+		}
+		return nil, domain.NewError(domain.KindUpstreamUnavailable, nil, "%s block not found", block)
+	}
+
+	return &domain.Head{
+		Number:        head.Number.ToInt().Uint64(),
+		Hash:          head.Hash.Hex(),
+		Timestamp:     time.Unix(int64(head.Timestamp), 0),
+		BaseFeePerGas: (*big.Int)(head.BaseFeePerGas),
+	}, nil
 }
 
-func (s *service) convertToETH(val *big.Int) string {
-	flt := new(big.Float).SetInt(val)
-	// Convert wei → ETH for human readability
-	eth := new(big.Float).Quo(flt, big.NewFloat(math.Pow10(18)))
+// SubscribeNewHeads subscribes to newHeads and pushes the number of every new head to heads.
+// It requires a WebSocket endpoint.
+func (s *service) SubscribeNewHeads(ctx context.Context, heads chan<- uint64) (domain.Subscription, error) {
+	headers := make(chan *types.Header)
+	sub, err := s.client.SubscribeNewHead(ctx, headers)
+	if err != nil {
+		return nil, wrapRPCError(err, "failed to subscribe to new heads")
+	}
+
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		defer sub.Unsubscribe()
+		for {
+			select {
+			case header := <-headers:
+				select {
+				case heads <- header.Number.Uint64():
+				case <-quit:
+					return nil
+				}
+			case err := <-sub.Err():
+				return err
+			case <-quit:
+				return nil
+			}
+		}
+	}), nil
+}
+
+// resolveBlock looks up the number and hash of the selected block.
+func (s *service) resolveBlock(ctx context.Context, block domain.BlockSelector) (*domain.BlockRef, error) {
+	var head *struct {
+		Number *hexutil.Big `json:"number"`
+		Hash   *common.Hash `json:"hash"`
+	}
+	err := s.client.Client().CallContext(ctx, &head, "eth_getBlockByNumber", block.String(), false)
+	if err != nil {
+		return nil, wrapRPCError(err, "failed to fetch block %s", block)
+	}
+	if head == nil || head.Number == nil {
+		return nil, domain.NotFoundError("block %s not found", block)
+	}
+
+	ref := &domain.BlockRef{
+		Number: head.Number.ToInt().Uint64(),
+	}
+	if head.Hash != nil {
+		ref.Hash = head.Hash.Hex()
+	}
 
-	return fmt.Sprintf("%.18f", eth)
+	return ref, nil
 }
@@ -0,0 +1,152 @@
+package alchemy
+
+import (
+	"context"
+	"math/big"
+
+	"github.com/aisalamdag23/etherstats/internal/domain"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// rpcTransaction is a transaction as returned by eth_getTransactionByHash
+type rpcTransaction struct {
+	Hash                 common.Hash     `json:"hash"`
+	From                 common.Address  `json:"from"`
+	To                   *common.Address `json:"to"`
+	Value                *hexutil.Big    `json:"value"`
+	Nonce                hexutil.Uint64  `json:"nonce"`
+	Type                 hexutil.Uint64  `json:"type"`
+	Gas                  hexutil.Uint64  `json:"gas"`
+	GasPrice             *hexutil.Big    `json:"gasPrice"`
+	MaxFeePerGas         *hexutil.Big    `json:"maxFeePerGas"`
+	MaxPriorityFeePerGas *hexutil.Big    `json:"maxPriorityFeePerGas"`
+	Input                hexutil.Bytes   `json:"input"`
+	BlockNumber          *hexutil.Big    `json:"blockNumber"`
+	BlockHash            *common.Hash    `json:"blockHash"`
+	TransactionIndex     *hexutil.Uint64 `json:"transactionIndex"`
+}
+
+// GetTransaction fetches a transaction and its receipt with a single JSON-RPC batch,
+// so the receipt is read from the same node as the transaction.
+// The receipt is nil while the transaction is pending.
+func (s *service) GetTransaction(ctx context.Context, hash string) (*domain.TransactionDetails, error) {
+	var (
+		tx      *rpcTransaction
+		receipt *types.Receipt
+	)
+	batch := []rpc.BatchElem{
+		{Method: "eth_getTransactionByHash", Args: []interface{}{common.HexToHash(hash)}, Result: &tx},
+		{Method: "eth_getTransactionReceipt", Args: []interface{}{common.HexToHash(hash)}, Result: &receipt},
+	}
+	if err := s.client.Client().BatchCallContext(ctx, batch); err != nil {
+		return nil, wrapRPCError(err, "failed to fetch transaction")
+	}
+	for _, elem := range batch {
+		if elem.Error != nil {
+			return nil, wrapRPCError(elem.Error, "failed to fetch transaction")
+		}
+	}
+	if tx == nil {
+		return nil, domain.NotFoundError("transaction %s not found", hash)
+	}
+
+	details := &domain.TransactionDetails{Transaction: newTransaction(tx)}
+	// the receipt of a transaction included right after the first read is ignored until the next lookup
+	if receipt != nil && details.Transaction.BlockNumber != nil {
+		details.Receipt = newReceipt(receipt)
+	}
+
+	return details, nil
+}
+
+func newTransaction(tx *rpcTransaction) domain.Transaction {
+	res := domain.Transaction{
+		Hash:                 tx.Hash.Hex(),
+		From:                 tx.From.Hex(),
+		Value:                domain.NewEtherAmount(bigOrZero(tx.Value)),
+		Nonce:                uint64(tx.Nonce),
+		Type:                 uint64(tx.Type),
+		Gas:                  uint64(tx.Gas),
+		MaxFeePerGas:         feeAmount(tx.MaxFeePerGas),
+		MaxPriorityFeePerGas: feeAmount(tx.MaxPriorityFeePerGas),
+		Input:                tx.Input.String(),
+	}
+	if tx.To != nil {
+		res.To = tx.To.Hex()
+	}
+	// nodes report the effective gas price of dynamic fee transactions as gasPrice, the receipt has it already
+	if tx.MaxFeePerGas == nil {
+		res.GasPrice = feeAmount(tx.GasPrice)
+	}
+	if tx.BlockNumber != nil && tx.BlockHash != nil {
+		number := tx.BlockNumber.ToInt().Uint64()
+		res.BlockNumber = &number
+		res.BlockHash = tx.BlockHash.Hex()
+	}
+	if tx.TransactionIndex != nil {
+		index := uint64(*tx.TransactionIndex)
+		res.TransactionIndex = &index
+	}
+
+	return res
+}
+
+func newReceipt(receipt *types.Receipt) *domain.Receipt {
+	status := domain.TxSuccess
+	switch {
+	case len(receipt.PostState) > 0:
+		// pre-Byzantium receipts have a state root instead of a status, there is nothing to tell success from failure
+		status = domain.TxUnknown
+	case receipt.Status != types.ReceiptStatusSuccessful:
+		status = domain.TxFailed
+	}
+	price := bigOrZero((*hexutil.Big)(receipt.EffectiveGasPrice))
+	fee := new(big.Int).Mul(price, new(big.Int).SetUint64(receipt.GasUsed))
+
+	res := &domain.Receipt{
+		Status:            status,
+		BlockNumber:       receipt.BlockNumber.Uint64(),
+		BlockHash:         receipt.BlockHash.Hex(),
+		GasUsed:           receipt.GasUsed,
+		CumulativeGasUsed: receipt.CumulativeGasUsed,
+		EffectiveGasPrice: domain.NewFeeAmount(price),
+		Fee:               domain.NewEtherAmount(fee),
+		Logs:              make([]domain.Log, 0, len(receipt.Logs)),
+	}
+	if receipt.ContractAddress != (common.Address{}) {
+		res.ContractAddress = receipt.ContractAddress.Hex()
+	}
+	for _, log := range receipt.Logs {
+		topics := make([]string, 0, len(log.Topics))
+		for _, topic := range log.Topics {
+			topics = append(topics, topic.Hex())
+		}
+		res.Logs = append(res.Logs, domain.Log{
+			Address:  log.Address.Hex(),
+			Topics:   topics,
+			Data:     hexutil.Encode(log.Data),
+			LogIndex: uint64(log.Index),
+		})
+	}
+
+	return res
+}
+
+// feeAmount converts an optional per gas fee, it returns nil when the fee is missing.
+func feeAmount(wei *hexutil.Big) *domain.FeeAmount {
+	if wei == nil {
+		return nil
+	}
+	amount := domain.NewFeeAmount(wei.ToInt())
+	return &amount
+}
+
+func bigOrZero(val *hexutil.Big) *big.Int {
+	if val == nil {
+		return new(big.Int)
+	}
+	return val.ToInt()
+}
@@ -0,0 +1,165 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/aisalamdag23/etherstats/internal/domain"
+	"github.com/aisalamdag23/etherstats/internal/infrastructure/logger"
+	"go.uber.org/zap"
+)
+
+const (
+	// keyPrefix starts every API key, so leaked keys are easy to scan for
+	keyPrefix = "es_"
+	// displayPrefixLen is how much of a key is stored in the clear to tell keys apart
+	displayPrefixLen = len(keyPrefix) + 8
+	// streamTokenPrefix starts every stream token, so they are not mistaken for keys
+	streamTokenPrefix = "est_"
+	// streamTokenTTL is how long a stream token may wait to be used, enough to open the stream it was issued for
+	streamTokenTTL = time.Minute
+)
+
+type service struct {
+	repository domain.APIKeyRepository
+	limiter    domain.RateLimiter
+	streams    domain.StreamLimiter
+}
+
+// NewService creates a service authenticating callers by API key and enforcing their limits.
+func NewService(repository domain.APIKeyRepository, limiter domain.RateLimiter, streams domain.StreamLimiter) domain.AuthService {
+	return &service{
+		repository: repository,
+		limiter:    limiter,
+		streams:    streams,
+	}
+}
+
+// Authenticate looks up the active API key matching rawKey.
+func (s *service) Authenticate(ctx context.Context, rawKey string) (*domain.APIKey, error) {
+	if !strings.HasPrefix(rawKey, keyPrefix) {
+		return nil, domain.NewError(domain.KindUnauthenticated, nil, "missing or malformed api key")
+	}
+
+	key, err := s.repository.GetAPIKeyByHash(ctx, HashKey(rawKey))
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return nil, domain.NewError(domain.KindUnauthenticated, nil, "unknown or revoked api key")
+	}
+
+	return key, nil
+}
+
+// Allow enforces the rate limit and daily quota of key. When the limiter is unavailable
+// the request is let through: an outage of Redis should not take the API down with it.
+func (s *service) Allow(ctx context.Context, key domain.APIKey, route string) (*domain.RateLimit, error) {
+	limit, err := s.limiter.Allow(ctx, key, route)
+	if err != nil {
+		logger.Extract(ctx).Error("failed to check rate limit, letting the request through", zap.Error(err))
+		return &domain.RateLimit{Allowed: true, Limit: key.Burst, Remaining: key.Burst}, nil
+	}
+
+	return limit, nil
+}
+
+// IssueStreamToken creates a random single-use token standing for key on a stream request.
+// Only its hash is stored.
+func (s *service) IssueStreamToken(ctx context.Context, key domain.APIKey) (*domain.StreamToken, error) {
+	var b [32]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return nil, err
+	}
+	token := streamTokenPrefix + hex.EncodeToString(b[:])
+
+	if err := s.repository.SaveStreamToken(ctx, HashKey(token), key.ID, streamTokenTTL); err != nil {
+		return nil, err
+	}
+
+	return &domain.StreamToken{Token: token, ExpiresAt: time.Now().UTC().Add(streamTokenTTL)}, nil
+}
+
+// AuthenticateStreamToken takes the stream token and looks up its key, which must still be active.
+func (s *service) AuthenticateStreamToken(ctx context.Context, token string) (*domain.APIKey, error) {
+	if !strings.HasPrefix(token, streamTokenPrefix) {
+		return nil, domain.NewError(domain.KindUnauthenticated, nil, "missing or malformed stream token")
+	}
+
+	keyID, err := s.repository.TakeStreamToken(ctx, HashKey(token))
+	if err != nil {
+		return nil, err
+	}
+	if keyID == 0 {
+		return nil, domain.NewError(domain.KindUnauthenticated, nil, "unknown, expired or used stream token")
+	}
+
+	key, err := s.repository.GetAPIKey(ctx, keyID)
+	if err != nil {
+		return nil, err
+	}
+	if key == nil || key.RevokedAt != nil {
+		return nil, domain.NewError(domain.KindUnauthenticated, nil, "unknown or revoked api key")
+	}
+
+	return key, nil
+}
+
+// OpenStream takes a stream slot of key. Like Allow, the stream is let through when the limiter is unavailable.
+func (s *service) OpenStream(ctx context.Context, key domain.APIKey, streamID string) error {
+	err := s.streams.Open(ctx, key, streamID)
+	if err != nil && domain.KindOf(err) != domain.KindRateLimited {
+		logger.Extract(ctx).Error("failed to open stream, letting it through", zap.Error(err))
+		return nil
+	}
+
+	return err
+}
+
+// RenewStream keeps the slot of a stream held.
+func (s *service) RenewStream(ctx context.Context, key domain.APIKey, streamID string) {
+	if err := s.streams.Renew(ctx, key, streamID); err != nil {
+		logger.Extract(ctx).Warn("failed to renew stream", zap.Error(err))
+	}
+}
+
+// CloseStream frees the slot of a stream.
+func (s *service) CloseStream(ctx context.Context, key domain.APIKey, streamID string) {
+	if err := s.streams.Close(ctx, key, streamID); err != nil {
+		logger.Extract(ctx).Warn("failed to close stream", zap.Error(err))
+	}
+}
+
+// ChargeStream counts n requests pushed by a stream against the daily quota of key.
+// Like Allow, they are let through when the limiter is unavailable.
+func (s *service) ChargeStream(ctx context.Context, key domain.APIKey, route string, n int) bool {
+	charged, err := s.streams.Charge(ctx, key, route, n)
+	if err != nil {
+		logger.Extract(ctx).Error("failed to charge stream, letting it through", zap.Error(err))
+		return true
+	}
+
+	return charged
+}
+
+// GenerateKey creates a new random API key, along with its display prefix and hash.
+// Only the prefix and hash are meant to be stored.
+func GenerateKey() (key, prefix, hash string, err error) {
+	var b [32]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", "", "", err
+	}
+
+	key = keyPrefix + hex.EncodeToString(b[:])
+	return key, key[:displayPrefixLen], HashKey(key), nil
+}
+
+// HashKey returns the hex SHA-256 of an API key. Keys are random enough that a slow hash is not needed.
+func HashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,81 @@
+package eth
+
+import (
+	"context"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/aisalamdag23/etherstats/internal/domain"
+	"github.com/aisalamdag23/etherstats/internal/infrastructure/logger"
+	"github.com/ethereum/go-ethereum/common"
+	"go.uber.org/zap"
+	"golang.org/x/net/idna"
+)
+
+// ensSuffix is the top level domain of the names resolved through ENS
+const ensSuffix = ".eth"
+
+var (
+	hexAddressPattern = regexp.MustCompile(`^0x[0-9a-fA-F]{40}$`)
+	// ensProfile maps names the way UTS-46 lookups do (case folding, width and compatibility mappings),
+	// keeping the underscores and emoji ENS allows
+	ensProfile = idna.New(idna.MapForLookup(), idna.Transitional(false), idna.StrictDomainName(false))
+)
+
+// parseAddress validates a 0x-prefixed hex address and returns it in EIP-55 checksummed form.
+// Mixed-case addresses must carry a valid checksum, all lower or all upper case ones are taken as is.
+func parseAddress(val string) (string, error) {
+	if !hexAddressPattern.MatchString(val) {
+		return "", domain.InvalidInputError("%q is not a 0x-prefixed hex address of 40 characters", val)
+	}
+
+	checksummed := common.HexToAddress(val).Hex()
+	digits := val[2:]
+	mixedCase := strings.ToLower(digits) != digits && strings.ToUpper(digits) != digits
+	if mixedCase && val != checksummed {
+		return "", domain.InvalidInputError("%q fails the EIP-55 checksum", val)
+	}
+
+	return checksummed, nil
+}
+
+// isENSName tells whether the value is an ENS name rather than a hex address.
+func isENSName(val string) bool {
+	return strings.HasSuffix(strings.ToLower(val), ensSuffix)
+}
+
+// normalizeENSName normalizes an ENS name with UTS-46 so that every spelling of a name hashes to the same node.
+func normalizeENSName(val string) (string, error) {
+	name, err := ensProfile.ToUnicode(val)
+	if err != nil || strings.ContainsFunc(name, unicode.IsSpace) || slices.Contains(strings.Split(name, "."), "") {
+		return "", domain.InvalidInputError("%q is not a valid ENS name", val)
+	}
+
+	return name, nil
+}
+
+// resolveAddress turns an address or an ENS name given by the caller into a checksummed address.
+// It returns the ENS name along with the address when one was given, an empty name otherwise.
+func (s *service) resolveAddress(ctx context.Context, val string) (address, name string, err error) {
+	if !isENSName(val) {
+		address, err = parseAddress(val)
+		return address, "", err
+	}
+
+	if s.ensResolver == nil {
+		return "", "", domain.InvalidInputError("ENS names cannot be resolved without the mainnet chain")
+	}
+	name, err = normalizeENSName(val)
+	if err != nil {
+		return "", "", err
+	}
+	address, err = s.ensResolver.ResolveENS(ctx, name)
+	if err != nil {
+		logger.Extract(ctx).Error("failed to resolve ens name", zap.Error(err), zap.String("name", name))
+		return "", "", err
+	}
+
+	return address, name, nil
+}
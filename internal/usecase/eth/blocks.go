@@ -0,0 +1,107 @@
+package eth
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/aisalamdag23/etherstats/internal/domain"
+	"github.com/aisalamdag23/etherstats/internal/infrastructure/logger"
+	"go.uber.org/zap"
+)
+
+// GetBlock retrieves a block by number, hash or "latest", with either the full transactions or their hashes.
+// The latest block is the cached latest block number. Blocks are read from the cache first: finalized
+// blocks are saved to the database for good, recent ones are cached in Redis since they may still be reorged.
+// Blocks fetched by hash are served but not stored, since nothing tells them from an orphan.
+func (s *service) GetBlock(ctx context.Context, id string, fullTransactions bool) (*domain.BlockResponse, error) {
+	ref, latest, err := parseBlockID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	blockNumber, err := s.getLatestBlockNumber(ctx)
+	if err != nil {
+		logger.Extract(ctx).Error("failed to get latest block number", zap.Error(err))
+		return nil, err
+	}
+	if latest {
+		ref.Number = blockNumber
+	}
+
+	block, err := s.repository.GetBlock(ctx, ref)
+	if err != nil {
+		logger.Extract(ctx).Error("failed to get cached block", zap.Error(err), zap.Uint64("number", ref.Number), zap.String("hash", ref.Hash))
+		// fetch it upstream instead
+	}
+	fetched := block == nil
+	if fetched {
+		// Concurrent lookups of the same block share a single upstream call
+		key := "block:" + strings.ToLower(ref.Hash)
+		if ref.Hash == "" {
+			key = "block:" + domain.NumberedBlock(ref.Number).String()
+		}
+		block, err = coalesced(ctx, s.coalescer, key, func(ctx context.Context) (*domain.Block, error) {
+			return s.alchemyService.GetBlock(ctx, ref)
+		})
+		if err != nil {
+			logger.Extract(ctx).Error("failed to get block", zap.Error(err), zap.Uint64("number", ref.Number), zap.String("hash", ref.Hash))
+			return nil, err
+		}
+	}
+
+	response := domain.BlockResponse{
+		BlockHeader:      block.BlockHeader,
+		TransactionCount: len(block.Transactions),
+		Confirmations:    confirmations(block.Number, blockNumber),
+	}
+	response.Finalized = s.opts.FinalityDepth > 0 && response.Confirmations >= s.opts.FinalityDepth
+
+	// a block fetched by hash may be an orphan, only the canonical block at a number is stored
+	if fetched && ref.Hash == "" {
+		s.storeBlock(ctx, *block, response.Finalized)
+	}
+
+	if fullTransactions {
+		response.Transactions = block.Transactions
+	} else {
+		response.TransactionHashes = make([]string, 0, len(block.Transactions))
+		for _, tx := range block.Transactions {
+			response.TransactionHashes = append(response.TransactionHashes, tx.Hash)
+		}
+	}
+	response.ServerTime = time.Now().Format(time.RFC3339)
+
+	return &response, nil
+}
+
+// storeBlock saves a finalized block to the database or caches a recent one in Redis.
+func (s *service) storeBlock(ctx context.Context, block domain.Block, finalized bool) {
+	store, what := s.repository.CacheBlock, "cache"
+	if finalized {
+		store, what = s.repository.SaveBlock, "save"
+	}
+
+	if err := store(ctx, block); err != nil {
+		logger.Extract(ctx).Error("failed to "+what+" block", zap.Error(err), zap.Uint64("number", block.Number))
+		// just log the error and return the block
+	}
+}
+
+// parseBlockID parses a block given as a decimal number, a 0x-prefixed hex number, a 0x-prefixed
+// hash of 64 characters or "latest". An empty value selects the latest block.
+func parseBlockID(id string) (ref domain.BlockRef, latest bool, err error) {
+	if hashPattern.MatchString(id) {
+		return domain.BlockRef{Hash: strings.ToLower(id)}, false, nil
+	}
+
+	block, err := domain.ParseBlockSelector(id)
+	if err == nil && block.Tag == domain.BlockLatest {
+		return ref, true, nil
+	}
+	if err != nil || block.Tag != "" {
+		return ref, false, domain.InvalidInputError("block must be a number, a hex number, a block hash or %s", domain.BlockLatest)
+	}
+
+	return domain.BlockRef{Number: block.Number}, false, nil
+}
@@ -0,0 +1,27 @@
+package eth
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/aisalamdag23/etherstats/internal/domain"
+)
+
+// coalesced runs load through the coalescer so concurrent callers of the same key share a single load.
+// Results are exchanged as JSON since they may come from another replica.
+func coalesced[T any](ctx context.Context, coalescer domain.Coalescer, key string, load func(ctx context.Context) (T, error)) (T, error) {
+	var res T
+	raw, err := coalescer.Do(ctx, key, func(ctx context.Context) ([]byte, error) {
+		val, err := load(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(val)
+	})
+	if err != nil {
+		return res, err
+	}
+
+	err = json.Unmarshal(raw, &res)
+	return res, err
+}
@@ -0,0 +1,114 @@
+package eth
+
+import (
+	"context"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/aisalamdag23/etherstats/internal/domain"
+	"github.com/aisalamdag23/etherstats/internal/infrastructure/logger"
+	"go.uber.org/zap"
+)
+
+const (
+	// feeHistoryBlocks is the number of recent blocks the fee estimates are computed from
+	feeHistoryBlocks = 20
+)
+
+// feePercentiles are the priority fee percentiles backing the slow, standard and fast estimates
+var feePercentiles = []float64{10, 50, 90}
+
+// GetFees retrieves the pending base fee, the suggested priority fee and
+// slow/standard/fast EIP-1559 fee estimates.
+// It first checks if the estimates are cached in Redis.
+// If not, it computes them from the Alchemy API and stores them in Redis.
+func (s *service) GetFees(ctx context.Context) (*domain.Fees, error) {
+	// Check if the fees are already cached in Redis
+	fees, err := s.repository.GetFees(ctx)
+	if err == nil && fees != nil {
+		// early return the cached fees
+		return fees, nil
+	}
+
+	// Concurrent cache misses share a single upstream computation
+	return coalesced(ctx, s.coalescer, "fees", func(ctx context.Context) (*domain.Fees, error) {
+		fees, err := s.fetchFees(ctx)
+		if err != nil {
+			logger.Extract(ctx).Error("failed to get fees", zap.Error(err))
+			return nil, err
+		}
+		// Store the fees in Redis with a TTL set from the config
+		err = s.repository.SetFees(ctx, *fees)
+		if err != nil {
+			logger.Extract(ctx).Error("failed to set fees in redis", zap.Error(err))
+			// just log the error and return the fees
+		}
+
+		return fees, nil
+	})
+}
+
+// fetchFees computes the fee estimates from the fee history of the most recent blocks.
+// The priority fee of each estimate is the median of its reward percentile over those blocks,
+// and the max fee leaves room for the base fee to double before the transaction is included.
+func (s *service) fetchFees(ctx context.Context) (*domain.Fees, error) {
+	tip, err := s.alchemyService.GetGasTipCap(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	history, err := s.alchemyService.GetFeeHistory(ctx, feeHistoryBlocks, feePercentiles)
+	if err != nil {
+		return nil, err
+	}
+	if len(history.BaseFees) == 0 || len(history.GasUsedRatio) == 0 {
+		return nil, domain.NewError(domain.KindUpstreamUnavailable, nil, "empty fee history")
+	}
+
+	baseFee := history.BaseFees[len(history.BaseFees)-1]
+	estimates := make([]domain.FeeEstimate, len(feePercentiles))
+	for i := range feePercentiles {
+		priorityFee := medianReward(history, i)
+		maxFee := new(big.Int).Mul(baseFee, big.NewInt(2))
+		maxFee.Add(maxFee, priorityFee)
+		estimates[i] = domain.FeeEstimate{
+			MaxPriorityFeePerGas: domain.NewFeeAmount(priorityFee),
+			MaxFeePerGas:         domain.NewFeeAmount(maxFee),
+		}
+	}
+
+	return &domain.Fees{
+		BlockNumber: history.OldestBlock + uint64(len(history.GasUsedRatio)) - 1,
+		BaseFee:     domain.NewFeeAmount(baseFee),
+		PriorityFee: domain.NewFeeAmount(tip),
+		Slow:        estimates[0],
+		Standard:    estimates[1],
+		Fast:        estimates[2],
+		ServerTime:  time.Now().Format(time.RFC3339),
+	}, nil
+}
+
+// medianReward returns the median of the rewards at the given percentile index.
+// Empty blocks are skipped since they report a zero reward.
+func medianReward(history *domain.FeeHistory, percentile int) *big.Int {
+	rewards := make([]*big.Int, 0, len(history.Rewards))
+	for block, reward := range history.Rewards {
+		if percentile >= len(reward) {
+			continue
+		}
+		if block < len(history.GasUsedRatio) && history.GasUsedRatio[block] == 0 {
+			continue
+		}
+		rewards = append(rewards, reward[percentile])
+	}
+	if len(rewards) == 0 {
+		return new(big.Int)
+	}
+
+	sort.Slice(rewards, func(i, j int) bool {
+		return rewards[i].Cmp(rewards[j]) < 0
+	})
+
+	return rewards[len(rewards)/2]
+}
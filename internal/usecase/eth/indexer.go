@@ -0,0 +1,200 @@
+package eth
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aisalamdag23/etherstats/internal/domain"
+	"github.com/aisalamdag23/etherstats/internal/infrastructure/logger"
+	"go.uber.org/zap"
+)
+
+type (
+	indexer struct {
+		repository     domain.IndexRepository
+		alchemyService domain.AlchemyAPIService
+		opts           IndexerOptions
+		lgr            *zap.Logger
+	}
+
+	// IndexerOptions tunes how the indexer follows the chain
+	IndexerOptions struct {
+		// Interval is how often the indexer catches up with the latest block
+		Interval time.Duration
+		// MaxBlocksPerRun caps the blocks indexed on each interval, so a backlog is worked off gradually
+		MaxBlocksPerRun int
+		// MaxReorgDepth is how many blocks may be rolled back on a reorg, deeper reorgs restart the index
+		MaxReorgDepth uint64
+	}
+)
+
+// NewIndexer creates a worker that scans every new block for the transactions to and from the
+// watched addresses of a chain and saves them as transfers. It starts from the latest block.
+// The hash of every indexed block is kept, a block whose parent hash does not match reveals
+// a reorg: the orphaned blocks are rolled back along with their transfers and indexed again.
+func NewIndexer(repository domain.IndexRepository, alchemyService domain.AlchemyAPIService, opts IndexerOptions, lgr *zap.Logger) domain.Worker {
+	return &indexer{
+		repository:     repository,
+		alchemyService: alchemyService,
+		opts:           opts,
+		lgr:            lgr,
+	}
+}
+
+// Run indexes new blocks on every interval until ctx is done.
+func (x *indexer) Run(ctx context.Context) error {
+	// every layer below logs through the context logger
+	ctx = logger.ToContext(ctx, x.lgr)
+	ticker := time.NewTicker(x.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := x.index(ctx); err != nil && ctx.Err() == nil {
+			logger.Extract(ctx).Error("failed to index blocks", zap.Error(err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// index indexes the blocks from the last indexed one up to the latest one, at most MaxBlocksPerRun.
+func (x *indexer) index(ctx context.Context) error {
+	head, err := x.alchemyService.GetLatestBlockNumber(ctx)
+	if err != nil {
+		return err
+	}
+	last, err := x.repository.GetLastIndexedBlock(ctx)
+	if err != nil {
+		return err
+	}
+	next := head
+	if last != nil {
+		next = last.Number + 1
+	}
+
+	watched, err := x.watched(ctx)
+	if err != nil {
+		return err
+	}
+
+	var rolledBack uint64
+	for indexed := 0; next <= head && indexed < x.opts.MaxBlocksPerRun; {
+		block, err := x.alchemyService.GetBlock(ctx, domain.BlockRef{Number: next})
+		if err != nil {
+			return err
+		}
+
+		if last != nil && !strings.EqualFold(block.ParentHash, last.Hash) {
+			// the last indexed block was orphaned, roll it back and check its parent instead
+			rolledBack++
+			logger.Extract(ctx).Warn("reorg detected, rolling back indexed block",
+				zap.Uint64("number", last.Number), zap.String("hash", last.Hash), zap.String("new_parent_hash", block.ParentHash))
+			if rolledBack > x.opts.MaxReorgDepth {
+				// the reorg goes deeper than finality, which should never happen: go on from the
+				// current block and leave the blocks below as they are
+				logger.Extract(ctx).Error("reorg deeper than the maximum depth, restarting the index", zap.Uint64("depth", rolledBack))
+				last = nil
+				continue
+			}
+			orphaned := last.Number
+			if err := x.repository.RollbackFrom(ctx, orphaned); err != nil {
+				return err
+			}
+			if last, err = x.repository.GetLastIndexedBlock(ctx); err != nil {
+				return err
+			}
+			next = orphaned
+			if last != nil {
+				next = last.Number + 1
+			}
+			continue
+		}
+
+		transfers, err := x.transfers(ctx, block, watched)
+		if err != nil {
+			return err
+		}
+		indexedBlock := domain.IndexedBlock{
+			Number:     block.Number,
+			Hash:       block.Hash,
+			ParentHash: block.ParentHash,
+		}
+		if err := x.repository.SaveIndexedBlock(ctx, indexedBlock, transfers); err != nil {
+			return err
+		}
+
+		last = &indexedBlock
+		next++
+		indexed++
+	}
+
+	return nil
+}
+
+// watched returns the watched addresses keyed by their lowercase form.
+func (x *indexer) watched(ctx context.Context) (map[string]string, error) {
+	list, err := x.repository.ListWatched(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	watched := make(map[string]string, len(list))
+	for _, w := range list {
+		watched[strings.ToLower(w.Address)] = w.Address
+	}
+
+	return watched, nil
+}
+
+// transfers lists the transactions of the block to or from a watched address.
+// The receipt of each of them is fetched to tell whether the value actually moved.
+func (x *indexer) transfers(ctx context.Context, block *domain.Block, watched map[string]string) ([]domain.Transfer, error) {
+	var transfers []domain.Transfer
+	for _, tx := range block.Transactions {
+		from, fromWatched := watched[strings.ToLower(tx.From)]
+		to, toWatched := watched[strings.ToLower(tx.To)]
+		if !fromWatched && !toWatched {
+			continue
+		}
+
+		details, err := x.alchemyService.GetTransaction(ctx, tx.Hash)
+		if err != nil {
+			return nil, err
+		}
+		if details.Receipt == nil || !strings.EqualFold(details.Receipt.BlockHash, block.Hash) {
+			// the block was reorged in between, it is indexed again on the next run
+			return nil, fmt.Errorf("receipt of %s does not belong to block %s", tx.Hash, block.Hash)
+		}
+
+		transfer := domain.Transfer{
+			BlockNumber:    block.Number,
+			BlockHash:      block.Hash,
+			BlockTimestamp: block.Timestamp,
+			TxHash:         tx.Hash,
+			Value:          tx.Value.Wei,
+			Status:         details.Receipt.Status,
+		}
+		switch {
+		case fromWatched && toWatched && from == to:
+			transfer.Address, transfer.Counterparty, transfer.Direction = from, to, domain.DirectionSelf
+			transfers = append(transfers, transfer)
+		default:
+			if fromWatched {
+				transfer.Address, transfer.Counterparty, transfer.Direction = from, tx.To, domain.DirectionOut
+				transfers = append(transfers, transfer)
+			}
+			if toWatched {
+				transfer.Address, transfer.Counterparty, transfer.Direction = to, tx.From, domain.DirectionIn
+				transfers = append(transfers, transfer)
+			}
+		}
+	}
+
+	return transfers, nil
+}
@@ -0,0 +1,129 @@
+package eth
+
+import (
+	"context"
+	"time"
+
+	"github.com/aisalamdag23/etherstats/internal/domain"
+	"github.com/aisalamdag23/etherstats/internal/infrastructure/logger"
+	"go.uber.org/zap"
+)
+
+const (
+	// resubscribeAfter is how long the poller polls before it retries a failed subscription
+	resubscribeAfter = time.Minute
+)
+
+type poller struct {
+	*service
+	lgr      *zap.Logger
+	interval time.Duration
+	// head is the last block number the cache was refreshed for
+	head uint64
+}
+
+// NewPoller creates a worker that keeps the gas price, block number and fee cache warm.
+// It follows newHeads when an endpoint supports subscriptions, and polls the block number
+// every interval otherwise. Cached values are also refreshed every interval so they never
+// expire between blocks, as long as interval is shorter than the cache TTL.
+func NewPoller(repository domain.Repository, alchemyService domain.AlchemyAPIService, interval time.Duration, lgr *zap.Logger) domain.Worker {
+	return &poller{
+		service: &service{
+			repository:     repository,
+			alchemyService: alchemyService,
+		},
+		lgr:      lgr,
+		interval: interval,
+	}
+}
+
+// Run refreshes the cache on every new block until ctx is done.
+func (p *poller) Run(ctx context.Context) error {
+	// every layer below logs through the context logger
+	ctx = logger.ToContext(ctx, p.lgr)
+	for ctx.Err() == nil {
+		if err := p.follow(ctx); err != nil {
+			logger.Extract(ctx).Info("new heads subscription unavailable, polling", zap.Error(err))
+			p.poll(ctx, resubscribeAfter)
+		}
+	}
+
+	return nil
+}
+
+// follow refreshes the cache on every head pushed by a newHeads subscription.
+// It returns nil once ctx is done, or the error that ended the subscription.
+func (p *poller) follow(ctx context.Context) error {
+	heads := make(chan uint64)
+	sub, err := p.alchemyService.SubscribeNewHeads(ctx, heads)
+	if err != nil {
+		return err
+	}
+	defer sub.Unsubscribe()
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-sub.Err():
+			return err
+		case head := <-heads:
+			p.refresh(ctx, head)
+		case <-ticker.C:
+			p.refresh(ctx, p.head)
+		}
+	}
+}
+
+// poll refreshes the cache on every interval for the given duration, or until ctx is done.
+func (p *poller) poll(ctx context.Context, duration time.Duration) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	deadline := time.After(duration)
+
+	for {
+		head, err := p.alchemyService.GetLatestBlockNumber(ctx)
+		if err != nil {
+			logger.Extract(ctx).Error("failed to poll latest block number", zap.Error(err))
+			head = p.head
+		}
+		p.refresh(ctx, head)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-deadline:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// refresh stores the block number along with a freshly fetched gas price and fee estimates.
+func (p *poller) refresh(ctx context.Context, head uint64) {
+	if head > p.head {
+		p.head = head
+	}
+	if p.head != 0 {
+		if err := p.repository.SetBlockNumber(ctx, p.head); err != nil {
+			logger.Extract(ctx).Error("failed to set block number in redis", zap.Error(err))
+		}
+	}
+
+	price, err := p.alchemyService.GetGasPrice(ctx)
+	if err != nil {
+		logger.Extract(ctx).Error("failed to get gas price", zap.Error(err))
+	} else if err := p.repository.SetGasPrice(ctx, price); err != nil {
+		logger.Extract(ctx).Error("failed to set gas price in redis", zap.Error(err))
+	}
+
+	fees, err := p.fetchFees(ctx)
+	if err != nil {
+		logger.Extract(ctx).Error("failed to get fees", zap.Error(err))
+	} else if err := p.repository.SetFees(ctx, *fees); err != nil {
+		logger.Extract(ctx).Error("failed to set fees in redis", zap.Error(err))
+	}
+}
@@ -0,0 +1,160 @@
+package eth
+
+import (
+	"context"
+	"time"
+
+	"github.com/aisalamdag23/etherstats/internal/domain"
+	"github.com/aisalamdag23/etherstats/internal/infrastructure/logger"
+	"go.uber.org/zap"
+)
+
+type publisher struct {
+	chainID        uint64
+	alchemyService domain.AlchemyAPIService
+	feed           domain.HeadFeed
+	elector        domain.Elector
+	interval       time.Duration
+	lgr            *zap.Logger
+	// last and lastHash are the number and hash of the last published head
+	last     uint64
+	lastHash string
+}
+
+// maxHeadBacklog caps the heads published at once after a gap, e.g. a lost subscription
+const maxHeadBacklog = 64
+
+// NewHeadPublisher creates a worker that publishes every new head of a chain to feed, along
+// with its base fee and the suggested gas price. Only the replica elected by elector follows
+// the chain, so a single upstream subscription feeds the streams of every replica.
+// Like the poller, it follows newHeads when an endpoint supports subscriptions and polls
+// the latest block every interval otherwise.
+func NewHeadPublisher(chainID uint64, alchemyService domain.AlchemyAPIService, feed domain.HeadFeed, elector domain.Elector,
+	interval time.Duration, lgr *zap.Logger) domain.Worker {
+	return &publisher{
+		chainID:        chainID,
+		alchemyService: alchemyService,
+		feed:           feed,
+		elector:        elector,
+		interval:       interval,
+		lgr:            lgr,
+	}
+}
+
+// Run publishes new heads whenever this replica is elected, until ctx is done.
+func (p *publisher) Run(ctx context.Context) error {
+	ctx = logger.ToContext(ctx, p.lgr)
+	return p.elector.Lead(ctx, func(ctx context.Context) error {
+		logger.Extract(ctx).Info("elected to publish new heads")
+		for ctx.Err() == nil {
+			if err := p.follow(ctx); err != nil {
+				logger.Extract(ctx).Info("new heads subscription unavailable, polling", zap.Error(err))
+				p.poll(ctx, resubscribeAfter)
+			}
+		}
+		return nil
+	})
+}
+
+// follow publishes the heads pushed by a newHeads subscription.
+// It returns nil once ctx is done, or the error that ended the subscription.
+func (p *publisher) follow(ctx context.Context) error {
+	heads := make(chan uint64)
+	sub, err := p.alchemyService.SubscribeNewHeads(ctx, heads)
+	if err != nil {
+		return err
+	}
+	defer sub.Unsubscribe()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-sub.Err():
+			return err
+		case head := <-heads:
+			p.publish(ctx, head)
+		}
+	}
+}
+
+// poll publishes the latest head on every interval for the given duration, or until ctx is done.
+func (p *publisher) poll(ctx context.Context, duration time.Duration) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	deadline := time.After(duration)
+
+	for {
+		head, err := p.alchemyService.GetLatestBlockNumber(ctx)
+		if err != nil {
+			logger.Extract(ctx).Error("failed to poll latest block number", zap.Error(err))
+		} else {
+			p.publish(ctx, head)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-deadline:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// publish publishes every head from the one after the last published up to number, so a
+// client sees each block, at most maxHeadBacklog of them after a gap. A head at the last
+// published number is published again when its hash differs from the last published one:
+// the chain was reorged onto another block at that height. Lower heads come from an endpoint
+// lagging behind and are ignored.
+func (p *publisher) publish(ctx context.Context, number uint64) {
+	if number < p.last {
+		return
+	}
+	from := number
+	if p.last != 0 && number > p.last {
+		from = max(p.last+1, number-min(number, maxHeadBacklog-1))
+	}
+
+	for n := from; n <= number; n++ {
+		head, err := p.alchemyService.GetHead(ctx, domain.NumberedBlock(n))
+		if err != nil {
+			logger.Extract(ctx).Error("failed to get head", zap.Error(err), zap.Uint64("number", n))
+			return
+		}
+		if head.Number == p.last && head.Hash == p.lastHash {
+			continue
+		}
+		// the suggested gas price is current, it only goes along with the newest head
+		if err := p.publishHead(ctx, head, n == number); err != nil {
+			logger.Extract(ctx).Error("failed to publish head", zap.Error(err), zap.Uint64("number", n))
+			return
+		}
+		p.last, p.lastHash = head.Number, head.Hash
+	}
+}
+
+// publishHead publishes a head along with its base fee and, when asked, the suggested gas price.
+func (p *publisher) publishHead(ctx context.Context, head *domain.Head, withGasPrice bool) error {
+	event := domain.HeadEvent{
+		ChainID:   p.chainID,
+		Number:    head.Number,
+		Hash:      head.Hash,
+		Timestamp: head.Timestamp,
+	}
+	if head.BaseFeePerGas != nil {
+		fee := domain.NewFeeAmount(head.BaseFeePerGas)
+		event.BaseFeePerGas = &fee
+	}
+	if withGasPrice {
+		// a head without a gas price is still worth publishing
+		if price, err := p.alchemyService.GetGasPriceWei(ctx); err != nil {
+			logger.Extract(ctx).Error("failed to get gas price", zap.Error(err))
+		} else {
+			fee := domain.NewFeeAmount(price)
+			event.GasPrice = &fee
+		}
+	}
+
+	return p.feed.Publish(ctx, event)
+}
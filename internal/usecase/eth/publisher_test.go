@@ -0,0 +1,93 @@
+package eth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aisalamdag23/etherstats/internal/domain"
+	"github.com/aisalamdag23/etherstats/internal/usecase/alchemy"
+	"go.uber.org/zap"
+)
+
+// leader is an elector that always holds the lead
+type leader struct{}
+
+func (leader) Lead(ctx context.Context, task func(ctx context.Context) error) error {
+	return task(ctx)
+}
+
+// recordingFeed records the published heads, it stops the publisher after the first one
+type recordingFeed struct {
+	domain.HeadFeed
+	heads  []domain.HeadEvent
+	cancel context.CancelFunc
+}
+
+func (f *recordingFeed) Publish(_ context.Context, head domain.HeadEvent) error {
+	f.heads = append(f.heads, head)
+	f.cancel()
+	return nil
+}
+
+// newRPCServer is a stand-in JSON-RPC endpoint over HTTP, which supports no subscriptions
+func newRPCServer(t *testing.T, results map[string]interface{}) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"jsonrpc": "2.0", "id": req.ID, "result": results[req.Method]})
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// TestPublishGasPrice checks that a published head carries the suggested gas price in wei and gwei.
+func TestPublishGasPrice(t *testing.T) {
+	srv := newRPCServer(t, map[string]interface{}{
+		"eth_blockNumber": "0x10",
+		"eth_gasPrice":    "0x3b9aca00",
+		"eth_getBlockByNumber": map[string]string{
+			"number":        "0x10",
+			"hash":          "0x" + strings.Repeat("a", 64),
+			"timestamp":     "0x64",
+			"baseFeePerGas": "0x7",
+		},
+	})
+	provider, err := alchemy.NewServiceFromURL(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	feed := &recordingFeed{cancel: cancel}
+	if err := NewHeadPublisher(1, provider, feed, leader{}, time.Second, zap.NewNop()).Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(feed.heads) != 1 {
+		t.Fatalf("%d heads published, want 1", len(feed.heads))
+	}
+	head := feed.heads[0]
+	if head.Number != 16 {
+		t.Errorf("head number = %d, want 16", head.Number)
+	}
+	if head.GasPrice == nil {
+		t.Fatal("head has no gas price")
+	}
+	if head.GasPrice.Wei != "1000000000" || head.GasPrice.Gwei != "1.000000000" {
+		t.Errorf("gas price = %+v, want 1000000000 wei, 1.000000000 gwei", *head.GasPrice)
+	}
+}
@@ -2,60 +2,253 @@ package eth
 
 import (
 	"context"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/aisalamdag23/etherstats/internal/domain"
+	"github.com/aisalamdag23/etherstats/internal/infrastructure/logger"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
+	"golang.org/x/sync/errgroup"
 )
 
-type service struct {
-	lgr            *zap.Logger
-	repository     domain.Repository
-	alchemyService domain.AlchemyAPIService
-}
+var tracer = otel.Tracer("github.com/aisalamdag23/etherstats/internal/usecase/eth")
+
+const (
+	// defaultHistoryLimit is the page size used when the caller does not set one
+	defaultHistoryLimit = 50
+	// maxHistoryLimit caps the page size of a single history request
+	maxHistoryLimit = 500
+	// maxBatchAddresses caps the number of addresses of a single batch balance request
+	maxBatchAddresses = 500
+	// maxConcurrentResolves caps the ENS names of a batch request resolved at once
+	maxConcurrentResolves = 8
+)
+
+type (
+	service struct {
+		repository domain.Repository
+		// indexRepository holds the watch-list and the transfers indexed for it
+		indexRepository domain.IndexRepository
+		alchemyService  domain.AlchemyAPIService
+		// ensResolver resolves ENS names on mainnet, it is nil when mainnet is not configured
+		ensResolver domain.ENSResolver
+		// coalescer shares a single upstream call between concurrent cache misses of the same key
+		coalescer domain.Coalescer
+		// headFeed streams the new heads, it is nil when streaming is disabled
+		headFeed domain.HeadFeed
+		opts     Options
+	}
+
+	// Options tunes how the service fetches and falls back
+	Options struct {
+		// MaxStaleness is the age up to which last known good values stand in for failed upstream calls, 0 disables them
+		MaxStaleness time.Duration
+		// CallTimeout bounds each value fetched by Get, across every failover attempt, 0 disables it
+		CallTimeout time.Duration
+		// FinalityDepth is how many confirmations make a transaction final, 0 never saves transactions
+		FinalityDepth uint64
+	}
+)
 
-func NewService(repository domain.Repository, alchemyService domain.AlchemyAPIService, lgr *zap.Logger) domain.Service {
+func NewService(repository domain.Repository, indexRepository domain.IndexRepository, alchemyService domain.AlchemyAPIService,
+	ensResolver domain.ENSResolver, coalescer domain.Coalescer, headFeed domain.HeadFeed, opts Options) domain.Service {
 	return &service{
-		repository:     repository,
-		alchemyService: alchemyService,
-		lgr:            lgr,
+		repository:      repository,
+		indexRepository: indexRepository,
+		alchemyService:  alchemyService,
+		ensResolver:     ensResolver,
+		coalescer:       coalescer,
+		headFeed:        headFeed,
+		opts:            opts,
 	}
 }
 
-// Get retrieves the gas price, latest block number, and balance of a given Ethereum address.
-// It returns a Response struct containing the gas price, block number, balance, and server time.
-func (s *service) Get(ctx context.Context, address string) (*domain.Response, error) {
-	var response domain.Response
-	// 1. Get the gas price
-	gasPrice, err := s.getGasPrice(ctx)
-	if err != nil {
-		s.lgr.Error("failed to get gas price", zap.Error(err))
-		return nil, err
-	}
-	response.GasPrice = gasPrice
+// Get retrieves the gas price, latest block number, and balance of a given Ethereum address or ENS name.
+// The gas price is fetched concurrently with the block number, and the balance is read right after
+// at that same block number unless another block is selected, so the values are consistent.
+// A stale block number is not pinned to, the balance is then read at the latest block.
+// Each field is reported independently: a field whose upstream call fails falls back to its
+// last known good value, marked stale, or is left out with an error in the response status.
+// It only returns an error when the request is invalid or every field is missing.
+func (s *service) Get(ctx context.Context, address string, block domain.BlockSelector) (*domain.Response, error) {
+	ctx, span := tracer.Start(ctx, "eth.Get", trace.WithAttributes(attribute.String("block", block.String())))
+	defer span.End()
 
-	// 2. Get the latest block number
-	blockNumber, err := s.getLatestBlockNumber(ctx)
+	resolveCtx, done := s.step(ctx, "eth.resolveAddress")
+	address, name, err := s.resolveAddress(resolveCtx, address)
+	done(err)
 	if err != nil {
-		s.lgr.Error("failed to get latest block number", zap.Error(err))
 		return nil, err
 	}
-	response.BlockNumber = blockNumber
 
-	// 3. Get the balance of the address
-	balance, err := s.getBalance(ctx, address)
-	if err != nil {
-		s.lgr.Error("failed to get balance", zap.Error(err), zap.String("address", address))
+	var (
+		response   domain.Response
+		balanceErr error
+	)
+	// the group is only cancelled by errors of the caller's making, which fail the whole request
+	g, gctx := errgroup.WithContext(ctx)
+
+	// 1. Get the gas price
+	g.Go(func() error {
+		callCtx, done := s.step(gctx, "eth.getGasPrice")
+		gasPrice, err := s.getGasPrice(callCtx)
+		done(err)
+		if err != nil {
+			logger.Extract(ctx).Error("failed to get gas price", zap.Error(err))
+			gasPrice, response.Status.GasPrice = lastKnown(gctx, s, err, s.repository.GetLastGasPrice)
+		}
+		if gasPrice != "" {
+			response.GasPrice = &gasPrice
+		}
+		return nil
+	})
+
+	// 2. Get the latest block number, then the balance of the address at that block
+	g.Go(func() error {
+		callCtx, done := s.step(gctx, "eth.getLatestBlockNumber")
+		blockNumber, err := s.getLatestBlockNumber(callCtx)
+		done(err)
+		// the balance is pinned to the current block number only, never to a stale one
+		pinned := blockNumber
+		if err != nil {
+			logger.Extract(ctx).Error("failed to get latest block number", zap.Error(err))
+			blockNumber, response.Status.BlockNumber = lastKnown(gctx, s, err, s.repository.GetLastBlockNumber)
+		}
+		if blockNumber != 0 {
+			response.BlockNumber = &blockNumber
+		}
+
+		balance, ref, err := s.getPinnedBalance(gctx, address, block, pinned)
+		if err != nil {
+			logger.Extract(ctx).Error("failed to get balance", zap.Error(err), zap.String("address", address))
+			if isCallerError(err) {
+				// a stale value would not fix the caller's mistake
+				return err
+			}
+			balanceErr = err
+			balance, response.Status.Balance = s.lastBalance(gctx, address, block, err)
+		}
+		if balance != nil {
+			balance.ENSName = name
+		}
+		response.Balance = balance
+		response.Block = ref
+		return nil
+	})
+
+	if err := g.Wait(); err != nil {
 		return nil, err
 	}
-	response.Balance = *balance
+	if response.GasPrice == nil && response.BlockNumber == nil && response.Balance == nil {
+		return nil, balanceErr
+	}
 
-	// 4. Set the server time
+	// 3. Set the server time
 	response.ServerTime = time.Now().Format(time.RFC3339)
 
 	return &response, nil
 }
 
+// getPinnedBalance reads the balance at the given block number when the latest block is selected.
+// The read falls back to the latest block when the number is unknown, or when the node serving
+// the read has not caught up with it yet.
+func (s *service) getPinnedBalance(ctx context.Context, address string, block domain.BlockSelector, blockNumber uint64) (*domain.Balance, *domain.BlockRef, error) {
+	if block.Tag == domain.BlockLatest && blockNumber != 0 {
+		callCtx, done := s.step(ctx, "eth.getBalance")
+		balance, ref, err := s.getBalance(callCtx, address, domain.NumberedBlock(blockNumber), true)
+		done(err)
+		if err == nil || domain.KindOf(err) != domain.KindNotFound {
+			return balance, ref, err
+		}
+	}
+
+	callCtx, done := s.step(ctx, "eth.getBalance")
+	balance, ref, err := s.getBalance(callCtx, address, block, block.Tag == domain.BlockLatest)
+	done(err)
+	return balance, ref, err
+}
+
+// step starts the span of a single step of Get, bounded by the call timeout.
+// The returned function ends both, recording err on the span.
+func (s *service) step(ctx context.Context, name string) (context.Context, func(err error)) {
+	ctx, span := tracer.Start(ctx, name)
+	ctx, cancel := s.callContext(ctx)
+	return ctx, func(err error) {
+		cancel()
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, domain.KindOf(err).String())
+		}
+		span.End()
+	}
+}
+
+// callContext bounds a single value fetched by Get with the call timeout.
+func (s *service) callContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if s.opts.CallTimeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, s.opts.CallTimeout)
+}
+
+// isCallerError reports whether err is of the caller's making, which no retry or fallback fixes.
+func isCallerError(err error) bool {
+	kind := domain.KindOf(err)
+	return kind == domain.KindInvalidInput || kind == domain.KindNotFound
+}
+
+// lastKnown returns the last known good value after err, as long as it is no older than the
+// maximum staleness. The status marks the value stale, or carries err when there is none.
+func lastKnown[T any](ctx context.Context, s *service, err error, get func(ctx context.Context) (*domain.LastKnown[T], error)) (T, domain.FieldStatus) {
+	var zero T
+	missing := domain.FieldStatus{Error: domain.MessageOf(err)}
+	if s.opts.MaxStaleness <= 0 {
+		return zero, missing
+	}
+
+	last, err := get(ctx)
+	if err != nil {
+		logger.Extract(ctx).Error("failed to get last known value", zap.Error(err))
+		return zero, missing
+	}
+	if last == nil || time.Since(last.AsOf) > s.opts.MaxStaleness {
+		return zero, missing
+	}
+
+	return last.Value, domain.FieldStatus{Stale: true, AsOf: &last.AsOf}
+}
+
+// lastBalance returns the last saved balance of an address after err, as long as it is no
+// older than the maximum staleness. Only the latest block falls back, since the saved balance
+// was read at an unknown recent block.
+func (s *service) lastBalance(ctx context.Context, address string, block domain.BlockSelector, err error) (*domain.Balance, domain.FieldStatus) {
+	if block.Tag != domain.BlockLatest {
+		return nil, domain.FieldStatus{Error: domain.MessageOf(err)}
+	}
+
+	bal, status := lastKnown(ctx, s, err, func(ctx context.Context) (*domain.LastKnown[*domain.Balance], error) {
+		last, err := s.repository.GetLastBalance(ctx, address)
+		if err != nil || last == nil {
+			return nil, err
+		}
+		wei, err := domain.ParseWei(last.Balance)
+		if err != nil {
+			return nil, err
+		}
+		bal := domain.NewBalance(address, wei)
+		return &domain.LastKnown[*domain.Balance]{Value: &bal, AsOf: last.CreatedAt}, nil
+	})
+
+	return bal, status
+}
+
 // getGasPrice retrieves the current gas price from the Ethereum network.
 // It first checks if the gas price is cached in Redis.
 // If not, it fetches the gas price from the Alchemy API and stores it in Redis.
@@ -68,16 +261,21 @@ func (s *service) getGasPrice(ctx context.Context) (string, error) {
 		// early return the cached gas price
 		return price, nil
 	}
-	// If the gas price is not found in Redis, fetch it from the Alchemy API
-	price, err = s.alchemyService.GetGasPrice(ctx)
+	// Concurrent cache misses share a single upstream call
+	return coalesced(ctx, s.coalescer, "gas_price", s.fetchGasPrice)
+}
+
+// fetchGasPrice fetches the gas price from the Alchemy API and stores it in Redis.
+func (s *service) fetchGasPrice(ctx context.Context) (string, error) {
+	price, err := s.alchemyService.GetGasPrice(ctx)
 	if err != nil {
-		s.lgr.Error("failed to get gas price", zap.Error(err))
+		logger.Extract(ctx).Error("failed to get gas price", zap.Error(err))
 		return "", err
 	}
 	// Store the gas price in Redis with a TTL set from the config
 	err = s.repository.SetGasPrice(ctx, price)
 	if err != nil {
-		s.lgr.Error("failed to set gas price in redis", zap.Error(err))
+		logger.Extract(ctx).Error("failed to set gas price in redis", zap.Error(err))
 		// just log the error and return the price
 	}
 
@@ -96,40 +294,252 @@ func (s *service) getLatestBlockNumber(ctx context.Context) (uint64, error) {
 		// early return the cached block number
 		return blockNumber, nil
 	}
-	// If the block number is not found in Redis, fetch it from the Alchemy API
-	blockNumber, err = s.alchemyService.GetLatestBlockNumber(ctx)
+	// Concurrent cache misses share a single upstream call
+	return coalesced(ctx, s.coalescer, "block_number", s.fetchLatestBlockNumber)
+}
+
+// fetchLatestBlockNumber fetches the block number from the Alchemy API and stores it in Redis.
+func (s *service) fetchLatestBlockNumber(ctx context.Context) (uint64, error) {
+	blockNumber, err := s.alchemyService.GetLatestBlockNumber(ctx)
 	if err != nil {
-		s.lgr.Error("failed to get latest block number", zap.Error(err))
+		logger.Extract(ctx).Error("failed to get latest block number", zap.Error(err))
 		return 0, err
 	}
 	// Store the block number in Redis with a TTL set from the config
 	err = s.repository.SetBlockNumber(ctx, blockNumber)
 	if err != nil {
-		s.lgr.Error("failed to set block number in redis", zap.Error(err))
+		logger.Extract(ctx).Error("failed to set block number in redis", zap.Error(err))
 		// just log the error and return the block number
 	}
 
 	return blockNumber, nil
 }
 
-// getBalance retrieves the balance of a given Ethereum address in both Wei and Eth.
-// It uses the Alchemy API service to fetch the balance and returns it as a Balance struct.
-func (s *service) getBalance(ctx context.Context, address string) (*domain.Balance, error) {
+// getBalance retrieves the balance of a given Ethereum address at the selected block.
+// It uses the Alchemy API service to fetch the balance and returns it as a Balance struct
+// along with the block it was read at.
+// Concurrent lookups of the same address at the same block share a single upstream call.
+// Only current balances are saved, see fetchBalance.
+func (s *service) getBalance(ctx context.Context, address string, block domain.BlockSelector, current bool) (*domain.Balance, *domain.BlockRef, error) {
+	key := "balance:" + block.String() + ":" + strings.ToLower(address)
+	if current {
+		// a current read saves the balance, it must not be served by a historical one that does not
+		key += ":current"
+	}
+	balance, err := coalesced(ctx, s.coalescer, key, func(ctx context.Context) (*domain.BlockBalance, error) {
+		return s.fetchBalance(ctx, address, block, current)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	bal := domain.NewBalance(address, balance.Wei)
+	return &bal, &balance.Block, nil
+}
+
+// fetchBalance fetches the balance from the Alchemy API. A current balance, read at the latest
+// block, is saved to the database: the saved rows are the history of the address and the last
+// known good value, so a balance read at any other block must not be saved as of now.
+func (s *service) fetchBalance(ctx context.Context, address string, block domain.BlockSelector, current bool) (*domain.BlockBalance, error) {
 	// Get the balance from the Alchemy API
-	balance, err := s.alchemyService.GetBalance(ctx, address)
+	balance, err := s.alchemyService.GetBalance(ctx, address, block)
 	if err != nil {
-		s.lgr.Error("failed to get balance", zap.Error(err), zap.String("address", address))
+		logger.Extract(ctx).Error("failed to get balance", zap.Error(err), zap.String("address", address))
 		return nil, err
 	}
-	// Save the balance to the database
-	_, err = s.repository.SaveBalance(ctx, address, balance)
+	if !current {
+		return balance, nil
+	}
+	// Save the exact wei balance to the database
+	_, err = s.repository.SaveBalance(ctx, address, balance.Wei.String())
 	if err != nil {
-		s.lgr.Error("failed to save balance", zap.Error(err), zap.String("address", address))
+		logger.Extract(ctx).Error("failed to save balance", zap.Error(err), zap.String("address", address))
 		// return the balance even if saving fails
 	}
 
-	return &domain.Balance{
-		Address: address,
-		Eth:     balance,
+	return balance, nil
+}
+
+// GetBalances retrieves the balances of many Ethereum addresses at the same block.
+// Balances are fetched upstream in a single batch and saved with a single insert.
+// Addresses that fail are reported individually in the response.
+func (s *service) GetBalances(ctx context.Context, addresses []string, block domain.BlockSelector) (*domain.BatchBalanceResponse, error) {
+	if len(addresses) == 0 || len(addresses) > maxBatchAddresses {
+		return nil, domain.InvalidInputError("between 1 and %d addresses are required", maxBatchAddresses)
+	}
+
+	// Addresses that cannot be resolved are reported without being sent upstream
+	results := make([]domain.BalanceResult, len(addresses))
+	names := make([]string, len(addresses))
+	all := make([]string, len(addresses))
+	var g errgroup.Group
+	g.SetLimit(maxConcurrentResolves)
+	for i, val := range addresses {
+		g.Go(func() error {
+			address, name, err := s.resolveAddress(ctx, val)
+			if err != nil {
+				results[i] = domain.BalanceResult{Address: val, Error: err.Error()}
+				return nil
+			}
+			all[i], names[i] = address, name
+			return nil
+		})
+	}
+	_ = g.Wait()
+
+	resolved := make([]string, 0, len(addresses))
+	// index of each resolved address in results
+	indexes := make([]int, 0, len(addresses))
+	for i, address := range all {
+		if address != "" {
+			resolved = append(resolved, address)
+			indexes = append(indexes, i)
+		}
+	}
+
+	balances, err := s.alchemyService.GetBalances(ctx, resolved, block)
+	if err != nil {
+		logger.Extract(ctx).Error("failed to get balances", zap.Error(err), zap.Int("addresses", len(resolved)))
+		return nil, err
+	}
+
+	rows := make([]domain.AddressBalance, 0, len(balances.Balances))
+	for j, bal := range balances.Balances {
+		i := indexes[j]
+		results[i] = domain.BalanceResult{
+			Address: bal.Address,
+			Error:   bal.Error,
+		}
+		if bal.Error == "" {
+			b := domain.NewBalance(bal.Address, bal.Wei)
+			b.ENSName = names[i]
+			results[i].Balance = &b
+			rows = append(rows, domain.AddressBalance{
+				Address: bal.Address,
+				Balance: bal.Wei.String(),
+			})
+		}
+	}
+	// Save the balances to the database, only current ones belong to the history (see fetchBalance)
+	if block.Tag != domain.BlockLatest {
+		rows = nil
+	}
+	if err := s.repository.SaveBalances(ctx, rows); err != nil {
+		logger.Extract(ctx).Error("failed to save balances", zap.Error(err), zap.Int("addresses", len(rows)))
+		// return the balances even if saving fails
+	}
+
+	return &domain.BatchBalanceResponse{
+		Block:      balances.Block,
+		Balances:   results,
+		ServerTime: time.Now().Format(time.RFC3339),
 	}, nil
 }
+
+// GetHistory retrieves a page of the balances saved for a given Ethereum address.
+// Pages are chained through the NextCursor of the response, which is empty on the last page.
+func (s *service) GetHistory(ctx context.Context, filter domain.HistoryFilter) (*domain.HistoryResponse, error) {
+	address, name, err := s.resolveAddress(ctx, filter.Address)
+	if err != nil {
+		return nil, err
+	}
+	filter.Address = address
+
+	query, err := newBalanceHistoryQuery(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fetch one extra row to find out whether there is a next page
+	limit := query.Limit
+	query.Limit++
+	bals, err := s.repository.GetBalanceHistory(ctx, query)
+	if err != nil {
+		logger.Extract(ctx).Error("failed to get balance history", zap.Error(err), zap.String("address", filter.Address))
+		return nil, err
+	}
+
+	response := domain.HistoryResponse{
+		Address:  filter.Address,
+		ENSName:  name,
+		Balances: make([]domain.HistoryEntry, 0, len(bals)),
+	}
+	if len(bals) > limit {
+		bals = bals[:limit]
+		response.NextCursor = encodeCursor(bals[limit-1].ID)
+	}
+	for _, bal := range bals {
+		wei, err := domain.ParseWei(bal.Balance)
+		if err != nil {
+			logger.Extract(ctx).Error("failed to parse saved balance", zap.Error(err), zap.Int("id", bal.ID))
+			return nil, err
+		}
+		response.Balances = append(response.Balances, domain.HistoryEntry{
+			Balance:   domain.NewBalance(bal.Address, wei),
+			CreatedAt: bal.CreatedAt,
+		})
+	}
+
+	return &response, nil
+}
+
+// newBalanceHistoryQuery validates the filter and resolves it into a repository query.
+func newBalanceHistoryQuery(filter domain.HistoryFilter) (domain.BalanceHistoryQuery, error) {
+	query := domain.BalanceHistoryQuery{
+		Address: filter.Address,
+		From:    filter.From,
+		To:      filter.To,
+		Limit:   filter.Limit,
+	}
+
+	if filter.From != nil && filter.To != nil && !filter.From.Before(*filter.To) {
+		return query, domain.InvalidInputError("from must be before to")
+	}
+
+	switch {
+	case query.Limit == 0:
+		query.Limit = defaultHistoryLimit
+	case query.Limit < 0 || query.Limit > maxHistoryLimit:
+		return query, domain.InvalidInputError("limit must be between 1 and %d", maxHistoryLimit)
+	}
+
+	switch filter.Order {
+	case "", domain.OrderAsc:
+	case domain.OrderDesc:
+		query.Descending = true
+	default:
+		return query, domain.InvalidInputError("order must be %q or %q", domain.OrderAsc, domain.OrderDesc)
+	}
+
+	if filter.Cursor != "" {
+		id, err := decodeCursor(filter.Cursor)
+		if err != nil {
+			return query, domain.InvalidInputError("malformed cursor")
+		}
+		query.AfterID = id
+	}
+
+	return query, nil
+}
+
+// encodeCursor turns the id of the last returned row into an opaque cursor.
+func encodeCursor(id int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(id)))
+}
+
+// decodeCursor reverses encodeCursor.
+func decodeCursor(cursor string) (int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, err
+	}
+	id, err := strconv.Atoi(string(raw))
+	if err != nil {
+		return 0, err
+	}
+	if id <= 0 {
+		return 0, fmt.Errorf("cursor id %d out of range", id)
+	}
+
+	return id, nil
+}
@@ -0,0 +1,34 @@
+package eth
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/aisalamdag23/etherstats/internal/domain"
+)
+
+// SubscribeHeads subscribes to the heads published for the chain by any replica.
+func (s *service) SubscribeHeads() (<-chan domain.HeadEvent, func(), error) {
+	if s.headFeed == nil {
+		return nil, nil, domain.NotFoundError("streaming is not enabled")
+	}
+
+	heads, unsubscribe := s.headFeed.Subscribe()
+	return heads, unsubscribe, nil
+}
+
+// ResolveAddress validates an address or resolves an ENS name.
+// Stream clients resolve their subscriptions once rather than on every head.
+func (s *service) ResolveAddress(ctx context.Context, address string) (string, string, error) {
+	return s.resolveAddress(ctx, address)
+}
+
+// GetBalanceAt reads the balance of an address at a block. Every stream client subscribed to
+// the same address shares a single upstream call per block. Balances are not saved, a stream
+// would otherwise add a row per client and block.
+func (s *service) GetBalanceAt(ctx context.Context, address string, block uint64) (*domain.BlockBalance, error) {
+	key := "balance:" + address + ":" + strconv.FormatUint(block, 10)
+	return coalesced(ctx, s.coalescer, key, func(ctx context.Context) (*domain.BlockBalance, error) {
+		return s.alchemyService.GetBalance(ctx, address, domain.NumberedBlock(block))
+	})
+}
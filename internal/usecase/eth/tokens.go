@@ -0,0 +1,95 @@
+package eth
+
+import (
+	"context"
+	"time"
+
+	"github.com/aisalamdag23/etherstats/internal/domain"
+	"github.com/aisalamdag23/etherstats/internal/infrastructure/logger"
+	"go.uber.org/zap"
+)
+
+const (
+	// maxTokenContracts caps the number of token contracts of a single request
+	maxTokenContracts = 50
+)
+
+// GetTokenBalances retrieves the balances of a given Ethereum address or ENS name in ERC-20 token contracts.
+// Each balance is formatted with the decimals of its token.
+// Contracts that fail are reported individually in the response.
+func (s *service) GetTokenBalances(ctx context.Context, address string, contracts []string) (*domain.TokenBalancesResponse, error) {
+	if len(contracts) == 0 || len(contracts) > maxTokenContracts {
+		return nil, domain.InvalidInputError("between 1 and %d contracts are required", maxTokenContracts)
+	}
+
+	address, name, err := s.resolveAddress(ctx, address)
+	if err != nil {
+		return nil, err
+	}
+
+	response := domain.TokenBalancesResponse{
+		Address: address,
+		ENSName: name,
+		Tokens:  make([]domain.TokenBalance, 0, len(contracts)),
+	}
+	for _, contract := range contracts {
+		response.Tokens = append(response.Tokens, s.getTokenBalance(ctx, address, contract))
+	}
+	response.ServerTime = time.Now().Format(time.RFC3339)
+
+	return &response, nil
+}
+
+// getTokenBalance retrieves the balance of a given Ethereum address in a single token contract.
+func (s *service) getTokenBalance(ctx context.Context, address, contract string) domain.TokenBalance {
+	result := domain.TokenBalance{Contract: contract}
+	checksummed, err := parseAddress(contract)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.Contract = checksummed
+
+	token, err := s.getToken(ctx, result.Contract)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.Symbol = token.Symbol
+	result.Decimals = token.Decimals
+
+	balance, err := s.alchemyService.GetTokenBalance(ctx, result.Contract, address)
+	if err != nil {
+		logger.Extract(ctx).Error("failed to get token balance", zap.Error(err), zap.String("address", address), zap.String("contract", result.Contract))
+		result.Error = err.Error()
+		return result
+	}
+	result.RawBalance = balance.String()
+	result.Balance = domain.FormatUnits(balance, int(token.Decimals))
+
+	return result
+}
+
+// getToken retrieves the metadata of a token contract.
+// It first checks if the metadata is cached in Redis or the database.
+// If not, it fetches the metadata from the Alchemy API and stores it in both.
+func (s *service) getToken(ctx context.Context, contract string) (*domain.Token, error) {
+	token, err := s.repository.GetToken(ctx, contract)
+	if err == nil && token != nil {
+		// early return the cached token
+		return token, nil
+	}
+
+	token, err = s.alchemyService.GetTokenMetadata(ctx, contract)
+	if err != nil {
+		logger.Extract(ctx).Error("failed to get token metadata", zap.Error(err), zap.String("contract", contract))
+		return nil, err
+	}
+	err = s.repository.SaveToken(ctx, *token)
+	if err != nil {
+		logger.Extract(ctx).Error("failed to save token", zap.Error(err), zap.String("contract", contract))
+		// just log the error and return the token
+	}
+
+	return token, nil
+}
@@ -0,0 +1,78 @@
+package eth
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/aisalamdag23/etherstats/internal/domain"
+	"github.com/aisalamdag23/etherstats/internal/infrastructure/logger"
+	"go.uber.org/zap"
+)
+
+// hashPattern matches a 32-byte hash, e.g. of a transaction or a block
+var hashPattern = regexp.MustCompile(`^0x[0-9a-fA-F]{64}$`)
+
+// GetTransaction retrieves a transaction, its receipt and its number of confirmations.
+// Confirmations are counted against the cached latest block number. Transactions that are
+// FinalityDepth blocks deep are saved to the database and never fetched upstream again.
+func (s *service) GetTransaction(ctx context.Context, hash string) (*domain.TransactionResponse, error) {
+	if !hashPattern.MatchString(hash) {
+		return nil, domain.InvalidInputError("%q is not a 0x-prefixed hex transaction hash of 64 characters", hash)
+	}
+	hash = strings.ToLower(hash)
+
+	tx, err := s.repository.GetTransaction(ctx, hash)
+	if err != nil {
+		logger.Extract(ctx).Error("failed to get saved transaction", zap.Error(err), zap.String("hash", hash))
+		// fetch it upstream instead
+	}
+	saved := tx != nil
+	if !saved {
+		// Concurrent lookups of the same transaction share a single upstream call
+		tx, err = coalesced(ctx, s.coalescer, "tx:"+hash, func(ctx context.Context) (*domain.TransactionDetails, error) {
+			return s.alchemyService.GetTransaction(ctx, hash)
+		})
+		if err != nil {
+			logger.Extract(ctx).Error("failed to get transaction", zap.Error(err), zap.String("hash", hash))
+			return nil, err
+		}
+	}
+
+	response := domain.TransactionResponse{
+		TransactionDetails: *tx,
+		Status:             domain.TxPending,
+	}
+	if tx.Receipt != nil {
+		blockNumber, err := s.getLatestBlockNumber(ctx)
+		if err != nil {
+			logger.Extract(ctx).Error("failed to get latest block number", zap.Error(err))
+			return nil, err
+		}
+
+		response.Status = tx.Receipt.Status
+		response.Confirmations = confirmations(tx.Receipt.BlockNumber, blockNumber)
+		response.Finalized = saved || (s.opts.FinalityDepth > 0 && response.Confirmations >= s.opts.FinalityDepth)
+	}
+
+	if response.Finalized && !saved {
+		if err := s.repository.SaveTransaction(ctx, *tx); err != nil {
+			logger.Extract(ctx).Error("failed to save transaction", zap.Error(err), zap.String("hash", hash))
+			// return the transaction even if saving fails
+		}
+	}
+	response.ServerTime = time.Now().Format(time.RFC3339)
+
+	return &response, nil
+}
+
+// confirmations counts the blocks from the including block up to the latest one, both included.
+// The cached latest block number may trail the node the receipt came from, the including block
+// then counts as the only confirmation.
+func confirmations(included, latest uint64) uint64 {
+	if latest < included {
+		return 1
+	}
+	return latest - included + 1
+}
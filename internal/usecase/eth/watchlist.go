@@ -0,0 +1,168 @@
+package eth
+
+import (
+	"context"
+
+	"github.com/aisalamdag23/etherstats/internal/domain"
+	"github.com/aisalamdag23/etherstats/internal/infrastructure/logger"
+	"go.uber.org/zap"
+)
+
+const (
+	// maxLabelLength is the length of the label column
+	maxLabelLength = 255
+)
+
+// ListWatched retrieves every watched address of the chain.
+func (s *service) ListWatched(ctx context.Context) ([]domain.WatchedAddress, error) {
+	return s.indexRepository.ListWatched(ctx)
+}
+
+// GetWatched retrieves a watched address or ENS name.
+func (s *service) GetWatched(ctx context.Context, address string) (*domain.WatchedAddress, error) {
+	address, _, err := s.resolveAddress(ctx, address)
+	if err != nil {
+		return nil, err
+	}
+
+	watched, err := s.indexRepository.GetWatched(ctx, address)
+	if err != nil {
+		return nil, err
+	}
+	if watched == nil {
+		return nil, domain.NotFoundError("%s is not watched", address)
+	}
+
+	return watched, nil
+}
+
+// WatchAddress adds an address or ENS name to the watch-list. ENS names are resolved once,
+// the indexer follows the address they point to at the time.
+// Transfers are indexed from the next indexed block on.
+func (s *service) WatchAddress(ctx context.Context, address, label string) (*domain.WatchedAddress, error) {
+	if len(label) > maxLabelLength {
+		return nil, domain.InvalidInputError("label must be at most %d characters", maxLabelLength)
+	}
+	address, _, err := s.resolveAddress(ctx, address)
+	if err != nil {
+		return nil, err
+	}
+
+	watched, err := s.indexRepository.AddWatched(ctx, domain.WatchedAddress{Address: address, Label: label})
+	if err != nil {
+		logger.Extract(ctx).Error("failed to add watched address", zap.Error(err), zap.String("address", address))
+		return nil, err
+	}
+	if watched == nil {
+		return nil, domain.InvalidInputError("%s is already watched", address)
+	}
+
+	return watched, nil
+}
+
+// UpdateWatched changes the label of a watched address or ENS name.
+func (s *service) UpdateWatched(ctx context.Context, address, label string) (*domain.WatchedAddress, error) {
+	if len(label) > maxLabelLength {
+		return nil, domain.InvalidInputError("label must be at most %d characters", maxLabelLength)
+	}
+	address, _, err := s.resolveAddress(ctx, address)
+	if err != nil {
+		return nil, err
+	}
+
+	watched, err := s.indexRepository.UpdateWatched(ctx, domain.WatchedAddress{Address: address, Label: label})
+	if err != nil {
+		logger.Extract(ctx).Error("failed to update watched address", zap.Error(err), zap.String("address", address))
+		return nil, err
+	}
+	if watched == nil {
+		return nil, domain.NotFoundError("%s is not watched", address)
+	}
+
+	return watched, nil
+}
+
+// UnwatchAddress removes an address or ENS name from the watch-list.
+// The transfers indexed so far are kept.
+func (s *service) UnwatchAddress(ctx context.Context, address string) error {
+	address, _, err := s.resolveAddress(ctx, address)
+	if err != nil {
+		return err
+	}
+
+	removed, err := s.indexRepository.RemoveWatched(ctx, address)
+	if err != nil {
+		logger.Extract(ctx).Error("failed to remove watched address", zap.Error(err), zap.String("address", address))
+		return err
+	}
+	if !removed {
+		return domain.NotFoundError("%s is not watched", address)
+	}
+
+	return nil
+}
+
+// GetTransfers retrieves a page of the indexed transfers of an address or ENS name, newest first.
+// Pages are chained through the NextCursor of the response, which is empty on the last page.
+func (s *service) GetTransfers(ctx context.Context, filter domain.TransferFilter) (*domain.TransfersResponse, error) {
+	address, name, err := s.resolveAddress(ctx, filter.Address)
+	if err != nil {
+		return nil, err
+	}
+
+	query := domain.TransferQuery{
+		Address: address,
+		Limit:   filter.Limit,
+	}
+	switch {
+	case query.Limit == 0:
+		query.Limit = defaultHistoryLimit
+	case query.Limit < 0 || query.Limit > maxHistoryLimit:
+		return nil, domain.InvalidInputError("limit must be between 1 and %d", maxHistoryLimit)
+	}
+	if filter.Cursor != "" {
+		id, err := decodeCursor(filter.Cursor)
+		if err != nil {
+			return nil, domain.InvalidInputError("malformed cursor")
+		}
+		query.BeforeID = int64(id)
+	}
+
+	// Fetch one extra row to find out whether there is a next page
+	limit := query.Limit
+	query.Limit++
+	transfers, err := s.indexRepository.GetTransfers(ctx, query)
+	if err != nil {
+		logger.Extract(ctx).Error("failed to get transfers", zap.Error(err), zap.String("address", address))
+		return nil, err
+	}
+
+	response := domain.TransfersResponse{
+		Address:   address,
+		ENSName:   name,
+		Transfers: make([]domain.TransferEntry, 0, len(transfers)),
+	}
+	if len(transfers) > limit {
+		transfers = transfers[:limit]
+		response.NextCursor = encodeCursor(int(transfers[limit-1].ID))
+	}
+	for _, transfer := range transfers {
+		wei, err := domain.ParseWei(transfer.Value)
+		if err != nil {
+			logger.Extract(ctx).Error("failed to parse saved transfer value", zap.Error(err), zap.Int64("id", transfer.ID))
+			return nil, err
+		}
+		response.Transfers = append(response.Transfers, domain.TransferEntry{
+			BlockNumber:    transfer.BlockNumber,
+			BlockHash:      transfer.BlockHash,
+			BlockTimestamp: transfer.BlockTimestamp,
+			TxHash:         transfer.TxHash,
+			Counterparty:   transfer.Counterparty,
+			Direction:      transfer.Direction,
+			Value:          domain.NewEtherAmount(wei),
+			Status:         transfer.Status,
+		})
+	}
+
+	return &response, nil
+}
@@ -0,0 +1,118 @@
+package health
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/aisalamdag23/etherstats/internal/domain"
+	"github.com/aisalamdag23/etherstats/internal/infrastructure/logger"
+	"go.uber.org/zap"
+)
+
+type (
+	// Check is a named check of a single dependency.
+	// The message of a domain.Error it fails with is reported, the full error is only logged.
+	Check struct {
+		Name  string
+		Check func(ctx context.Context) error
+	}
+
+	service struct {
+		checks  []Check
+		timeout time.Duration
+	}
+)
+
+// NewService creates a service running every check concurrently, each bounded by timeout.
+func NewService(checks []Check, timeout time.Duration) domain.HealthService {
+	return &service{
+		checks:  checks,
+		timeout: timeout,
+	}
+}
+
+// Ready runs every check and reports their status and latency.
+// The report is ok only when every check passes.
+func (s *service) Ready(ctx context.Context) domain.HealthReport {
+	results := make([]domain.CheckResult, len(s.checks))
+
+	var wg sync.WaitGroup
+	for i, check := range s.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = s.run(ctx, check)
+		}()
+	}
+	wg.Wait()
+
+	report := domain.HealthReport{
+		Status: domain.HealthOK,
+		Checks: make(map[string]domain.CheckResult, len(s.checks)),
+	}
+	for i, check := range s.checks {
+		report.Checks[check.Name] = results[i]
+		if results[i].Status != domain.HealthOK {
+			report.Status = domain.HealthFail
+		}
+	}
+
+	return report
+}
+
+func (s *service) run(ctx context.Context, check Check) domain.CheckResult {
+	if s.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	err := check.Check(ctx)
+	result := domain.CheckResult{
+		Status:    domain.HealthOK,
+		LatencyMs: time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		logger.Extract(ctx).Warn("health check failed", zap.String("check", check.Name), zap.Error(err))
+		result.Status = domain.HealthFail
+		result.Error = domain.MessageOf(err)
+	}
+
+	return result
+}
+
+// RPCCheck checks that the provider serves the expected chain and that its head block
+// is no older than maxHeadAge, so a stalled node is not mistaken for a healthy one.
+func RPCCheck(provider domain.AlchemyAPIService, chainID uint64, maxHeadAge time.Duration) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		id, err := provider.GetChainID(ctx)
+		if err != nil {
+			return err
+		}
+		if id != chainID {
+			return domain.NewError(domain.KindUpstreamUnavailable, nil, "provider serves chain %d instead of %d", id, chainID)
+		}
+
+		head, err := provider.GetHead(ctx, domain.LatestBlock())
+		if err != nil {
+			return err
+		}
+		if age := time.Since(head.Timestamp); maxHeadAge > 0 && age > maxHeadAge {
+			return domain.NewError(domain.KindUpstreamUnavailable, nil, "head block %d is %s old", head.Number, age.Round(time.Second))
+		}
+
+		return nil
+	}
+}
+
+// PingCheck checks a store with its ping function.
+func PingCheck(name string, ping func(ctx context.Context) error) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		if err := ping(ctx); err != nil {
+			return domain.NewError(domain.KindUpstreamUnavailable, err, "%s is unreachable", name)
+		}
+		return nil
+	}
+}
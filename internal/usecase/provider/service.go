@@ -0,0 +1,333 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aisalamdag23/etherstats/internal/domain"
+	"github.com/aisalamdag23/etherstats/internal/infrastructure/logger"
+	"go.uber.org/zap"
+)
+
+type (
+	// Endpoint is a single JSON-RPC endpoint of the provider
+	Endpoint struct {
+		Name    string
+		Service domain.AlchemyAPIService
+	}
+
+	// Options tunes failover and health checking
+	Options struct {
+		// RequestTimeout bounds a single attempt on a single endpoint
+		RequestTimeout time.Duration
+		// HealthCheckInterval is how often every endpoint's head block is polled
+		HealthCheckInterval time.Duration
+		// MaxBlockLag is how many blocks an endpoint may trail the best head before it is deprioritized
+		MaxBlockLag uint64
+	}
+
+	service struct {
+		lgr       *zap.Logger
+		opts      Options
+		endpoints []*endpoint
+	}
+
+	endpoint struct {
+		Endpoint
+		// index is the position of the endpoint in the configured order
+		index int
+
+		mu sync.RWMutex
+		// failed is set by the last call or health check that errored
+		failed bool
+		// lagging is set when the head trails the best head by more than MaxBlockLag
+		lagging bool
+		head    uint64
+	}
+)
+
+// NewService creates a service that spreads calls over an ordered list of endpoints.
+// Each call goes to the healthy endpoint with the highest head block first and fails over
+// to the next one on errors or timeouts. Endpoints are health checked until ctx is done.
+func NewService(ctx context.Context, endpoints []Endpoint, opts Options, lgr *zap.Logger) (domain.AlchemyAPIService, error) {
+	if len(endpoints) == 0 {
+		return nil, errors.New("at least one endpoint is required")
+	}
+
+	s := &service{
+		lgr:       lgr,
+		opts:      opts,
+		endpoints: make([]*endpoint, 0, len(endpoints)),
+	}
+	for i, ep := range endpoints {
+		s.endpoints = append(s.endpoints, &endpoint{
+			Endpoint: ep,
+			index:    i,
+		})
+	}
+
+	if opts.HealthCheckInterval > 0 {
+		go s.healthCheckLoop(ctx)
+	}
+
+	return s, nil
+}
+
+// GetGasPrice fetches the current suggested gas price from the best endpoint.
+func (s *service) GetGasPrice(ctx context.Context) (string, error) {
+	return call(ctx, s, "GetGasPrice", func(ctx context.Context, svc domain.AlchemyAPIService) (string, error) {
+		return svc.GetGasPrice(ctx)
+	})
+}
+
+// GetLatestBlockNumber fetches the latest block number from the best endpoint.
+func (s *service) GetLatestBlockNumber(ctx context.Context) (uint64, error) {
+	return call(ctx, s, "GetLatestBlockNumber", func(ctx context.Context, svc domain.AlchemyAPIService) (uint64, error) {
+		return svc.GetLatestBlockNumber(ctx)
+	})
+}
+
+// GetBalance fetches the balance of a given Ethereum address from the best endpoint.
+func (s *service) GetBalance(ctx context.Context, address string, block domain.BlockSelector) (*domain.BlockBalance, error) {
+	return call(ctx, s, "GetBalance", func(ctx context.Context, svc domain.AlchemyAPIService) (*domain.BlockBalance, error) {
+		return svc.GetBalance(ctx, address, block)
+	})
+}
+
+// GetBalances fetches the balances of many Ethereum addresses from the best endpoint.
+func (s *service) GetBalances(ctx context.Context, addresses []string, block domain.BlockSelector) (*domain.BlockBalances, error) {
+	return call(ctx, s, "GetBalances", func(ctx context.Context, svc domain.AlchemyAPIService) (*domain.BlockBalances, error) {
+		return svc.GetBalances(ctx, addresses, block)
+	})
+}
+
+// GetGasTipCap fetches the suggested priority fee from the best endpoint.
+func (s *service) GetGasTipCap(ctx context.Context) (*big.Int, error) {
+	return call(ctx, s, "GetGasTipCap", func(ctx context.Context, svc domain.AlchemyAPIService) (*big.Int, error) {
+		return svc.GetGasTipCap(ctx)
+	})
+}
+
+// GetGasPriceWei fetches the suggested gas price in wei from the best endpoint.
+func (s *service) GetGasPriceWei(ctx context.Context) (*big.Int, error) {
+	return call(ctx, s, "GetGasPriceWei", func(ctx context.Context, svc domain.AlchemyAPIService) (*big.Int, error) {
+		return svc.GetGasPriceWei(ctx)
+	})
+}
+
+// GetFeeHistory fetches the fee history of the most recent blocks from the best endpoint.
+func (s *service) GetFeeHistory(ctx context.Context, blockCount uint64, percentiles []float64) (*domain.FeeHistory, error) {
+	return call(ctx, s, "GetFeeHistory", func(ctx context.Context, svc domain.AlchemyAPIService) (*domain.FeeHistory, error) {
+		return svc.GetFeeHistory(ctx, blockCount, percentiles)
+	})
+}
+
+// GetTokenMetadata fetches the metadata of an ERC-20 token contract from the best endpoint.
+func (s *service) GetTokenMetadata(ctx context.Context, contract string) (*domain.Token, error) {
+	return call(ctx, s, "GetTokenMetadata", func(ctx context.Context, svc domain.AlchemyAPIService) (*domain.Token, error) {
+		return svc.GetTokenMetadata(ctx, contract)
+	})
+}
+
+// GetTokenBalance fetches the balance of a holder in an ERC-20 token contract from the best endpoint.
+func (s *service) GetTokenBalance(ctx context.Context, contract, holder string) (*big.Int, error) {
+	return call(ctx, s, "GetTokenBalance", func(ctx context.Context, svc domain.AlchemyAPIService) (*big.Int, error) {
+		return svc.GetTokenBalance(ctx, contract, holder)
+	})
+}
+
+// ResolveENS resolves an ENS name through the best endpoint.
+func (s *service) ResolveENS(ctx context.Context, name string) (string, error) {
+	return call(ctx, s, "ResolveENS", func(ctx context.Context, svc domain.AlchemyAPIService) (string, error) {
+		return svc.ResolveENS(ctx, name)
+	})
+}
+
+// GetChainID fetches the chain ID from the best endpoint.
+func (s *service) GetChainID(ctx context.Context) (uint64, error) {
+	return call(ctx, s, "GetChainID", func(ctx context.Context, svc domain.AlchemyAPIService) (uint64, error) {
+		return svc.GetChainID(ctx)
+	})
+}
+
+// GetHead fetches a block header from the best endpoint.
+func (s *service) GetHead(ctx context.Context, block domain.BlockSelector) (*domain.Head, error) {
+	return call(ctx, s, "GetHead", func(ctx context.Context, svc domain.AlchemyAPIService) (*domain.Head, error) {
+		return svc.GetHead(ctx, block)
+	})
+}
+
+// GetTransaction fetches a transaction and its receipt from the best endpoint.
+func (s *service) GetTransaction(ctx context.Context, hash string) (*domain.TransactionDetails, error) {
+	return call(ctx, s, "GetTransaction", func(ctx context.Context, svc domain.AlchemyAPIService) (*domain.TransactionDetails, error) {
+		return svc.GetTransaction(ctx, hash)
+	})
+}
+
+// GetBlock fetches a block with its full transactions from the best endpoint.
+func (s *service) GetBlock(ctx context.Context, ref domain.BlockRef) (*domain.Block, error) {
+	return call(ctx, s, "GetBlock", func(ctx context.Context, svc domain.AlchemyAPIService) (*domain.Block, error) {
+		return svc.GetBlock(ctx, ref)
+	})
+}
+
+// SubscribeNewHeads subscribes to new heads on the first endpoint that supports subscriptions.
+func (s *service) SubscribeNewHeads(ctx context.Context, heads chan<- uint64) (domain.Subscription, error) {
+	var errs []error
+	for _, ep := range s.ordered() {
+		sub, err := ep.Service.SubscribeNewHeads(ctx, heads)
+		if err == nil {
+			return sub, nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", ep.Name, err))
+	}
+
+	return nil, fmt.Errorf("no endpoint supports subscriptions: %w", errors.Join(errs...))
+}
+
+// call runs fn against every endpoint in order of preference until one succeeds.
+// Each attempt is bounded by the request timeout. It gives up as soon as ctx is done,
+// or on errors of the caller's making, which another endpoint would repeat.
+func call[T any](ctx context.Context, s *service, method string, fn func(ctx context.Context, svc domain.AlchemyAPIService) (T, error)) (T, error) {
+	var (
+		zero T
+		errs []error
+	)
+	for _, ep := range s.ordered() {
+		attemptCtx, cancel := ctx, context.CancelFunc(func() {})
+		if s.opts.RequestTimeout > 0 {
+			attemptCtx, cancel = context.WithTimeout(ctx, s.opts.RequestTimeout)
+		}
+		res, err := fn(attemptCtx, ep.Service)
+		cancel()
+		if err == nil {
+			ep.setFailed(false)
+			return res, nil
+		}
+		if ctx.Err() != nil {
+			// the caller gave up, there is no point in trying another endpoint
+			return zero, err
+		}
+		if kind := domain.KindOf(err); kind == domain.KindInvalidInput || kind == domain.KindNotFound {
+			// every endpoint would give the same answer
+			return zero, err
+		}
+
+		ep.setFailed(true)
+		logger.Extract(ctx).Warn("rpc endpoint failed, failing over",
+			zap.String("endpoint", ep.Name), zap.String("method", method), zap.Error(err))
+		errs = append(errs, fmt.Errorf("%s: %w", ep.Name, err))
+	}
+
+	// report the kind of the last failure, e.g. a timeout of the last resort endpoint
+	return zero, domain.NewError(domain.KindOf(errs[len(errs)-1]), errors.Join(errs...), "all endpoints failed")
+}
+
+// ordered returns the endpoints in order of preference: healthy endpoints by highest
+// head block first, then unhealthy ones as a last resort, each in configured order on ties.
+func (s *service) ordered() []*endpoint {
+	eps := make([]*endpoint, len(s.endpoints))
+	copy(eps, s.endpoints)
+
+	type state struct {
+		healthy bool
+		head    uint64
+	}
+	states := make(map[*endpoint]state, len(eps))
+	for _, ep := range eps {
+		ep.mu.RLock()
+		states[ep] = state{healthy: !ep.failed && !ep.lagging, head: ep.head}
+		ep.mu.RUnlock()
+	}
+
+	sort.SliceStable(eps, func(i, j int) bool {
+		a, b := states[eps[i]], states[eps[j]]
+		if a.healthy != b.healthy {
+			return a.healthy
+		}
+		if a.head != b.head {
+			return a.head > b.head
+		}
+		return eps[i].index < eps[j].index
+	})
+
+	return eps
+}
+
+// healthCheckLoop checks every endpoint on each tick until ctx is done.
+func (s *service) healthCheckLoop(ctx context.Context) {
+	ticker := time.NewTicker(s.opts.HealthCheckInterval)
+	defer ticker.Stop()
+
+	s.checkEndpoints(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.checkEndpoints(ctx)
+		}
+	}
+}
+
+// checkEndpoints polls the head block of every endpoint concurrently.
+// Endpoints that fail, or trail the best head by more than MaxBlockLag, are marked unhealthy.
+func (s *service) checkEndpoints(ctx context.Context) {
+	heads := make([]uint64, len(s.endpoints))
+	errs := make([]error, len(s.endpoints))
+
+	var wg sync.WaitGroup
+	for i, ep := range s.endpoints {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			checkCtx, cancel := context.WithTimeout(ctx, s.checkTimeout())
+			defer cancel()
+			heads[i], errs[i] = ep.Service.GetLatestBlockNumber(checkCtx)
+		}()
+	}
+	wg.Wait()
+
+	var best uint64
+	for i := range s.endpoints {
+		if errs[i] == nil && heads[i] > best {
+			best = heads[i]
+		}
+	}
+
+	for i, ep := range s.endpoints {
+		failed := errs[i] != nil
+		lagging := !failed && s.opts.MaxBlockLag > 0 && best-heads[i] > s.opts.MaxBlockLag
+		if failed || lagging {
+			s.lgr.Warn("rpc endpoint is unhealthy",
+				zap.String("endpoint", ep.Name), zap.Uint64("head", heads[i]), zap.Uint64("bestHead", best), zap.Error(errs[i]))
+		}
+
+		ep.mu.Lock()
+		ep.failed = failed
+		ep.lagging = lagging
+		if !failed {
+			ep.head = heads[i]
+		}
+		ep.mu.Unlock()
+	}
+}
+
+// checkTimeout bounds a single health check, falling back to the health check interval.
+func (s *service) checkTimeout() time.Duration {
+	if s.opts.RequestTimeout > 0 {
+		return s.opts.RequestTimeout
+	}
+	return s.opts.HealthCheckInterval
+}
+
+func (ep *endpoint) setFailed(failed bool) {
+	ep.mu.Lock()
+	ep.failed = failed
+	ep.mu.Unlock()
+}
@@ -0,0 +1,241 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aisalamdag23/etherstats/internal/domain"
+	"github.com/aisalamdag23/etherstats/internal/infrastructure/logger"
+	"github.com/aisalamdag23/etherstats/internal/usecase/alchemy"
+	"go.uber.org/zap"
+)
+
+// rpcServer is a stand-in JSON-RPC endpoint answering every request with respond
+type rpcServer struct {
+	*httptest.Server
+	calls atomic.Int32
+}
+
+type rpcRequest struct {
+	ID     json.RawMessage `json:"id"`
+	Method string          `json:"method"`
+}
+
+// respond returns the result of a method, or the HTTP status and JSON-RPC error to fail with
+type respond func(method string) (result interface{}, status int, rpcErr map[string]interface{})
+
+func newRPCServer(t *testing.T, fn respond) *rpcServer {
+	t.Helper()
+	s := &rpcServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.calls.Add(1)
+		var req rpcRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		result, status, rpcErr := fn(req.Method)
+		if status != 0 && status != http.StatusOK {
+			http.Error(w, http.StatusText(status), status)
+			return
+		}
+		resp := map[string]interface{}{"jsonrpc": "2.0", "id": req.ID}
+		if rpcErr != nil {
+			resp["error"] = rpcErr
+		} else {
+			resp["result"] = result
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// testContext silences the failover warnings
+func testContext() context.Context {
+	return logger.ToContext(context.Background(), zap.NewNop())
+}
+
+func newTestService(t *testing.T, servers ...*rpcServer) domain.AlchemyAPIService {
+	t.Helper()
+	return newOptionsService(t, Options{}, servers...)
+}
+
+// newOptionsService creates a service without health check loop, heads are only checked by calling checkEndpoints
+func newOptionsService(t *testing.T, opts Options, servers ...*rpcServer) *service {
+	t.Helper()
+	endpoints := make([]Endpoint, 0, len(servers))
+	for i, srv := range servers {
+		svc, err := alchemy.NewServiceFromURL(srv.URL)
+		if err != nil {
+			t.Fatalf("endpoint %d: %v", i, err)
+		}
+		endpoints = append(endpoints, Endpoint{Name: srv.URL, Service: svc})
+	}
+
+	opts.RequestTimeout = 500 * time.Millisecond
+	svc, err := NewService(context.Background(), endpoints, opts, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return svc.(*service)
+}
+
+// headServer answers eth_blockNumber with head and any other method with a gas price
+func headServer(t *testing.T, head string) *rpcServer {
+	t.Helper()
+	return newRPCServer(t, func(method string) (interface{}, int, map[string]interface{}) {
+		if method == "eth_blockNumber" {
+			return head, 0, nil
+		}
+		return "0x3b9aca00", 0, nil
+	})
+}
+
+func TestFailover(t *testing.T) {
+	down := newRPCServer(t, func(string) (interface{}, int, map[string]interface{}) {
+		return nil, http.StatusBadGateway, nil
+	})
+	up := newRPCServer(t, func(string) (interface{}, int, map[string]interface{}) {
+		return "0x10", 0, nil
+	})
+	svc := newTestService(t, down, up)
+
+	number, err := svc.GetLatestBlockNumber(testContext())
+	if err != nil {
+		t.Fatalf("GetLatestBlockNumber() error = %v", err)
+	}
+	if number != 16 {
+		t.Errorf("GetLatestBlockNumber() = %d, want 16", number)
+	}
+	if down.calls.Load() != 1 || up.calls.Load() != 1 {
+		t.Errorf("calls = %d, %d, want 1, 1", down.calls.Load(), up.calls.Load())
+	}
+
+	// the failed endpoint is only tried as a last resort from now on
+	if _, err := svc.GetLatestBlockNumber(testContext()); err != nil {
+		t.Fatalf("GetLatestBlockNumber() error = %v", err)
+	}
+	if down.calls.Load() != 1 || up.calls.Load() != 2 {
+		t.Errorf("calls = %d, %d, want 1, 2", down.calls.Load(), up.calls.Load())
+	}
+}
+
+func TestRetryOnTransientError(t *testing.T) {
+	for name, fail := range map[string]respond{
+		"rate limited": func(string) (interface{}, int, map[string]interface{}) {
+			return nil, http.StatusTooManyRequests, nil
+		},
+		"limit exceeded": func(string) (interface{}, int, map[string]interface{}) {
+			return nil, 0, map[string]interface{}{"code": -32005, "message": "limit exceeded"}
+		},
+		"timeout": func(string) (interface{}, int, map[string]interface{}) {
+			time.Sleep(time.Second)
+			return "0x1", 0, nil
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			flaky := newRPCServer(t, fail)
+			up := newRPCServer(t, func(string) (interface{}, int, map[string]interface{}) {
+				return "0x3b9aca00", 0, nil
+			})
+			svc := newTestService(t, flaky, up)
+
+			price, err := svc.GetGasPrice(testContext())
+			if err != nil {
+				t.Fatalf("GetGasPrice() error = %v", err)
+			}
+			// the gas price is reported in ETH
+			if price != "0.000000001000000000" {
+				t.Errorf("GetGasPrice() = %s, want 0.000000001000000000", price)
+			}
+			if flaky.calls.Load() != 1 || up.calls.Load() != 1 {
+				t.Errorf("calls = %d, %d, want 1, 1", flaky.calls.Load(), up.calls.Load())
+			}
+		})
+	}
+}
+
+func TestNoRetryOnExecutionReverted(t *testing.T) {
+	reverted := newRPCServer(t, func(string) (interface{}, int, map[string]interface{}) {
+		return nil, 0, map[string]interface{}{"code": 3, "message": "execution reverted", "data": "0x"}
+	})
+	other := newRPCServer(t, func(string) (interface{}, int, map[string]interface{}) {
+		return "0x", 0, nil
+	})
+	svc := newTestService(t, reverted, other)
+
+	_, err := svc.GetTokenBalance(testContext(),
+		"0x0000000000000000000000000000000000000001", "0x0000000000000000000000000000000000000002")
+	if err == nil {
+		t.Fatal("GetTokenBalance() error = nil, want execution reverted")
+	}
+	if kind := domain.KindOf(err); kind != domain.KindInvalidInput {
+		t.Errorf("KindOf() = %v, want %v", kind, domain.KindInvalidInput)
+	}
+	if reverted.calls.Load() != 1 || other.calls.Load() != 0 {
+		t.Errorf("calls = %d, %d, want 1, 0", reverted.calls.Load(), other.calls.Load())
+	}
+}
+
+func TestAllEndpointsFail(t *testing.T) {
+	down := newRPCServer(t, func(string) (interface{}, int, map[string]interface{}) {
+		return nil, http.StatusServiceUnavailable, nil
+	})
+	svc := newTestService(t, down, down)
+
+	if _, err := svc.GetLatestBlockNumber(testContext()); domain.KindOf(err) != domain.KindUpstreamUnavailable {
+		t.Errorf("GetLatestBlockNumber() error = %v, want upstream unavailable", err)
+	}
+	if down.calls.Load() != 2 {
+		t.Errorf("calls = %d, want 2", down.calls.Load())
+	}
+}
+
+func TestPreferHighestHead(t *testing.T) {
+	behind := headServer(t, "0x10")
+	ahead := headServer(t, "0x12")
+	svc := newOptionsService(t, Options{}, behind, ahead)
+
+	svc.checkEndpoints(testContext())
+	if _, err := svc.GetGasPrice(testContext()); err != nil {
+		t.Fatalf("GetGasPrice() error = %v", err)
+	}
+	// one health check each, then the gas price from the endpoint with the higher head
+	if behind.calls.Load() != 1 || ahead.calls.Load() != 2 {
+		t.Errorf("calls = %d, %d, want 1, 2", behind.calls.Load(), ahead.calls.Load())
+	}
+}
+
+func TestDemoteLaggingEndpoint(t *testing.T) {
+	lagging := headServer(t, "0x10")
+	best := headServer(t, "0x20")
+	near := headServer(t, "0x1e")
+	svc := newOptionsService(t, Options{MaxBlockLag: 4}, lagging, best, near)
+
+	// before any health check the configured order holds
+	if first := svc.ordered()[0]; first.Name != lagging.URL {
+		t.Fatalf("first endpoint = %s, want the lagging one %s", first.Name, lagging.URL)
+	}
+
+	svc.checkEndpoints(testContext())
+	ordered := svc.ordered()
+	if got := ordered[len(ordered)-1]; got.Name != lagging.URL {
+		t.Errorf("last endpoint = %s, want the lagging one %s", got.Name, lagging.URL)
+	}
+	for _, ep := range svc.endpoints {
+		ep.mu.RLock()
+		isLagging := ep.lagging
+		ep.mu.RUnlock()
+		if want := ep.Name == lagging.URL; isLagging != want {
+			t.Errorf("%s lagging = %v, want %v", ep.Name, isLagging, want)
+		}
+	}
+}
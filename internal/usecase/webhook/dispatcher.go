@@ -0,0 +1,190 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/aisalamdag23/etherstats/internal/domain"
+	"github.com/aisalamdag23/etherstats/internal/infrastructure/logger"
+	"go.uber.org/zap"
+)
+
+const (
+	// Headers of a delivery request
+	headerEvent     = "X-Etherstats-Event"
+	headerDelivery  = "X-Etherstats-Delivery"
+	headerSignature = "X-Etherstats-Signature"
+)
+
+type (
+	dispatcher struct {
+		repository domain.WebhookRepository
+		client     *http.Client
+		opts       DispatcherOptions
+		lgr        *zap.Logger
+	}
+
+	// DispatcherOptions tunes how deliveries are attempted and retried
+	DispatcherOptions struct {
+		// Interval is how often due deliveries are polled
+		Interval time.Duration
+		// BatchSize caps the deliveries attempted on each interval
+		BatchSize int
+		// MaxAttempts is how many times a delivery is attempted before it fails for good
+		MaxAttempts int
+		// RetryBase is the delay before the first retry, doubled on each of the next ones up to RetryMax
+		RetryBase time.Duration
+		RetryMax  time.Duration
+		// Lease is how long claimed deliveries are held before another replica may attempt them.
+		// It must be longer than the timeout of client.
+		Lease time.Duration
+	}
+)
+
+// NewDispatcher creates a worker POSTing the due deliveries to their webhooks.
+// Every request is signed with the secret of the webhook: the X-Etherstats-Signature header
+// holds t=<unix time>,v1=<hex HMAC-SHA256 of "<unix time>.<body>">.
+// A 2xx response succeeds, anything else is retried with exponential backoff until MaxAttempts.
+// Replicas share the work, each delivery is leased to a single one at a time.
+func NewDispatcher(repository domain.WebhookRepository, client *http.Client, opts DispatcherOptions, lgr *zap.Logger) domain.Worker {
+	return &dispatcher{
+		repository: repository,
+		client:     client,
+		opts:       opts,
+		lgr:        lgr,
+	}
+}
+
+// Run attempts the due deliveries on every interval until ctx is done.
+func (d *dispatcher) Run(ctx context.Context) error {
+	ctx = logger.ToContext(ctx, d.lgr)
+	ticker := time.NewTicker(d.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := d.dispatch(ctx); err != nil && ctx.Err() == nil {
+			logger.Extract(ctx).Error("failed to dispatch webhook deliveries", zap.Error(err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// dispatch attempts a batch of due deliveries.
+func (d *dispatcher) dispatch(ctx context.Context) error {
+	deliveries, err := d.repository.ClaimDueDeliveries(ctx, d.opts.BatchSize, d.opts.Lease)
+	if err != nil {
+		return err
+	}
+
+	// webhooks are looked up once per batch
+	webhooks := make(map[int64]*domain.Webhook)
+	for _, delivery := range deliveries {
+		webhook, ok := webhooks[delivery.WebhookID]
+		if !ok {
+			if webhook, err = d.repository.GetWebhook(ctx, delivery.WebhookID); err != nil {
+				return err
+			}
+			webhooks[delivery.WebhookID] = webhook
+		}
+		if webhook == nil {
+			// deleted in between, its deliveries are gone along with it
+			continue
+		}
+
+		if err := d.repository.UpdateDelivery(ctx, d.attempt(ctx, webhook, delivery)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// attempt POSTs a delivery to its webhook and returns it with the outcome.
+func (d *dispatcher) attempt(ctx context.Context, webhook *domain.Webhook, delivery domain.WebhookDelivery) domain.WebhookDelivery {
+	delivery.Attempts++
+	delivery.ResponseStatus = nil
+
+	status, err := d.post(ctx, webhook, delivery)
+	if status != 0 {
+		delivery.ResponseStatus = &status
+	}
+	if err == nil {
+		delivery.Status, delivery.LastError = domain.DeliverySucceeded, ""
+		return delivery
+	}
+
+	delivery.LastError = err.Error()
+	lgr := logger.Extract(ctx).With(zap.Int64("webhook_id", webhook.ID), zap.Int64("delivery_id", delivery.ID),
+		zap.Int("attempts", delivery.Attempts), zap.Error(err))
+	if delivery.Attempts >= d.opts.MaxAttempts {
+		delivery.Status = domain.DeliveryFailed
+		lgr.Warn("webhook delivery failed for good")
+		return delivery
+	}
+	delivery.NextAttemptAt = time.Now().Add(d.backoff(delivery.Attempts))
+	lgr.Info("webhook delivery failed, retrying", zap.Time("next_attempt_at", delivery.NextAttemptAt))
+
+	return delivery
+}
+
+// post sends a delivery, it returns the response status when there is one.
+func (d *dispatcher) post(ctx context.Context, webhook *domain.Webhook, delivery domain.WebhookDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(headerEvent, delivery.Event)
+	req.Header.Set(headerDelivery, strconv.FormatInt(delivery.ID, 10))
+	req.Header.Set(headerSignature, Sign(webhook.Secret, time.Now(), delivery.Payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	// drain the body so the connection is reused, it is never kept: the receiver may not be who it claims
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	return resp.StatusCode, nil
+}
+
+// backoff returns the delay before the retry following the given number of attempts.
+func (d *dispatcher) backoff(attempts int) time.Duration {
+	delay := d.opts.RetryBase
+	for i := 1; i < attempts && delay < d.opts.RetryMax; i++ {
+		delay *= 2
+	}
+
+	return min(delay, d.opts.RetryMax)
+}
+
+// Sign returns the signature header of a payload sent at t.
+// Receivers recompute the HMAC over "<t>.<body>" with their secret, compare it in constant time
+// and reject timestamps too far in the past to prevent replays.
+func Sign(secret string, t time.Time, payload []byte) string {
+	ts := strconv.FormatInt(t.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+
+	return "t=" + ts + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
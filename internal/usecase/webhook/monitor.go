@@ -0,0 +1,223 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/aisalamdag23/etherstats/internal/domain"
+	"github.com/aisalamdag23/etherstats/internal/infrastructure/logger"
+	"go.uber.org/zap"
+)
+
+// Sides of a threshold a balance may be on
+const (
+	sideAbove = "above"
+	sideBelow = "below"
+)
+
+type (
+	monitor struct {
+		chainID        uint64
+		repository     domain.WebhookRepository
+		ethRepository  domain.Repository
+		alchemyService domain.AlchemyAPIService
+		opts           MonitorOptions
+		lgr            *zap.Logger
+		// last is the last block checked, 0 until the first run
+		last uint64
+	}
+
+	// MonitorOptions tunes how the monitor follows the chain
+	MonitorOptions struct {
+		// Interval is how often the monitor catches up with the latest block
+		Interval time.Duration
+		// MaxBlocksPerRun caps the blocks checked on each interval, so a backlog is worked off gradually
+		MaxBlocksPerRun int
+	}
+)
+
+// NewMonitor creates a worker that reads the balances of the addresses with a webhook at every
+// new block of a chain, starting from the latest one. Each balance is compared with the baseline
+// the monitor keeps apart from the balance history, which any request may write to, including
+// balances read at past blocks: a change becomes the new baseline, is saved in balances like the
+// current balances read by requests, and is queued as a delivery to the webhooks of the address,
+// those with a threshold only when the balance crosses it. An address without a baseline starts
+// from its last row in balances, the balance the API serves, or without notifying when it has none.
+func NewMonitor(chainID uint64, repository domain.WebhookRepository, ethRepository domain.Repository,
+	alchemyService domain.AlchemyAPIService, opts MonitorOptions, lgr *zap.Logger) domain.Worker {
+	return &monitor{
+		chainID:        chainID,
+		repository:     repository,
+		ethRepository:  ethRepository,
+		alchemyService: alchemyService,
+		opts:           opts,
+		lgr:            lgr,
+	}
+}
+
+// Run checks new blocks on every interval until ctx is done.
+func (m *monitor) Run(ctx context.Context) error {
+	ctx = logger.ToContext(ctx, m.lgr)
+	ticker := time.NewTicker(m.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := m.check(ctx); err != nil && ctx.Err() == nil {
+			logger.Extract(ctx).Error("failed to check webhook balances", zap.Error(err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// check checks the blocks from the last checked one up to the latest one, at most MaxBlocksPerRun.
+func (m *monitor) check(ctx context.Context) error {
+	head, err := m.alchemyService.GetLatestBlockNumber(ctx)
+	if err != nil {
+		return err
+	}
+	next := head
+	if m.last != 0 {
+		next = m.last + 1
+	}
+	if next > head {
+		return nil
+	}
+
+	webhooks, err := m.repository.ListChainWebhooks(ctx, m.chainID)
+	if err != nil {
+		return err
+	}
+	if len(webhooks) == 0 {
+		// nothing to compare, pick up from the head once there is
+		m.last = head
+		return nil
+	}
+	byAddress := make(map[string][]domain.Webhook)
+	addresses := make([]string, 0, len(webhooks))
+	for _, w := range webhooks {
+		key := strings.ToLower(w.Address)
+		if _, ok := byAddress[key]; !ok {
+			addresses = append(addresses, w.Address)
+		}
+		byAddress[key] = append(byAddress[key], w)
+	}
+
+	for checked := 0; next <= head && checked < m.opts.MaxBlocksPerRun; checked++ {
+		if err := m.checkBlock(ctx, next, addresses, byAddress); err != nil {
+			return err
+		}
+		m.last = next
+		next++
+	}
+
+	return nil
+}
+
+// checkBlock compares the balances at a block with the baselines.
+// Deliveries are queued before the new baselines are saved: a failure in between notifies
+// the change again on the next run rather than not at all.
+// An address first seen is compared with its last row in balances instead.
+func (m *monitor) checkBlock(ctx context.Context, number uint64, addresses []string, byAddress map[string][]domain.Webhook) error {
+	balances, err := m.alchemyService.GetBalances(ctx, addresses, domain.NumberedBlock(number))
+	if err != nil {
+		return err
+	}
+	baselines, err := m.repository.GetMonitoredBalances(ctx, m.chainID, addresses)
+	if err != nil {
+		return err
+	}
+
+	var (
+		changed    []domain.AddressBalance
+		deliveries []domain.WebhookDelivery
+	)
+	now := time.Now().UTC()
+	for _, bal := range balances.Balances {
+		if bal.Wei == nil {
+			logger.Extract(ctx).Warn("failed to read webhook address balance",
+				zap.String("address", bal.Address), zap.Uint64("block", number), zap.String("error", bal.Error))
+			continue
+		}
+
+		current := domain.AddressBalance{Address: bal.Address, Balance: bal.Wei.String()}
+		last, ok := baselines[strings.ToLower(bal.Address)]
+		if !ok {
+			row, err := m.ethRepository.GetLastBalance(ctx, bal.Address)
+			if err != nil {
+				return err
+			}
+			if row == nil {
+				changed = append(changed, current)
+				continue
+			}
+			last = row.Balance
+		}
+		if last == current.Balance {
+			continue
+		}
+		changed = append(changed, current)
+
+		previous, err := domain.ParseWei(last)
+		if err != nil {
+			return err
+		}
+		for _, w := range byAddress[strings.ToLower(bal.Address)] {
+			payload := domain.BalancePayload{
+				Event:           domain.EventBalanceChanged,
+				ChainID:         m.chainID,
+				Address:         w.Address,
+				Block:           balances.Block,
+				PreviousBalance: domain.NewEtherAmount(previous),
+				Balance:         domain.NewEtherAmount(bal.Wei),
+				CreatedAt:       now,
+			}
+			if w.ThresholdWei != nil {
+				threshold, err := domain.ParseWei(*w.ThresholdWei)
+				if err != nil {
+					return err
+				}
+				from, to := side(previous, threshold), side(bal.Wei, threshold)
+				if from == to {
+					continue
+				}
+				payload.Event, payload.ThresholdWei, payload.Crossed = domain.EventThresholdCrossed, *w.ThresholdWei, to
+			}
+
+			body, err := json.Marshal(payload)
+			if err != nil {
+				return err
+			}
+			deliveries = append(deliveries, domain.WebhookDelivery{
+				WebhookID: w.ID,
+				Event:     payload.Event,
+				Payload:   body,
+			})
+		}
+	}
+
+	if err := m.repository.CreateDeliveries(ctx, deliveries); err != nil {
+		return err
+	}
+
+	if err := m.repository.SaveMonitoredBalances(ctx, m.chainID, number, changed); err != nil {
+		return err
+	}
+
+	return m.ethRepository.SaveBalances(ctx, changed)
+}
+
+// side tells which side of the threshold a balance is on, a balance equal to it is above.
+func side(wei, threshold *big.Int) string {
+	if wei.Cmp(threshold) < 0 {
+		return sideBelow
+	}
+	return sideAbove
+}
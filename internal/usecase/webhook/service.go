@@ -0,0 +1,172 @@
+package webhook
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/netip"
+	"net/url"
+	"strings"
+
+	"github.com/aisalamdag23/etherstats/internal/domain"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const (
+	// secretPrefix tells webhook secrets apart from API keys
+	secretPrefix = "whsec_"
+	// maxURLLength caps the length of a webhook URL
+	maxURLLength = 2048
+	// maxListedDeliveries is how many of the most recent deliveries of a webhook are listed
+	maxListedDeliveries = 100
+)
+
+type service struct {
+	repository domain.WebhookRepository
+	chains     map[string]uint64
+}
+
+// NewService creates the service managing webhooks.
+// chains maps the lowercase names and IDs of the served chains, and "" for the default chain, to the chain ID.
+func NewService(repository domain.WebhookRepository, chains map[string]uint64) domain.WebhookService {
+	return &service{
+		repository: repository,
+		chains:     chains,
+	}
+}
+
+// CreateWebhook registers a URL notified of the balance changes of an address.
+// The signing secret is generated here and only returned once.
+func (s *service) CreateWebhook(ctx context.Context, apiKeyID *int64, params domain.WebhookParams) (*domain.CreatedWebhook, error) {
+	chainID, ok := s.chains[strings.ToLower(params.Chain)]
+	if !ok {
+		return nil, domain.InvalidInputError("unknown chain %s", params.Chain)
+	}
+	if !common.IsHexAddress(params.Address) {
+		return nil, domain.InvalidInputError("invalid Ethereum address")
+	}
+	if err := validateURL(params.URL); err != nil {
+		return nil, err
+	}
+	if params.ThresholdWei != nil {
+		threshold, err := domain.ParseWei(*params.ThresholdWei)
+		if err != nil || threshold.Sign() < 0 {
+			return nil, domain.InvalidInputError("thresholdWei must be a non-negative integer amount of wei")
+		}
+		normalized := threshold.String()
+		params.ThresholdWei = &normalized
+	}
+
+	secret, err := generateSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	created, err := s.repository.CreateWebhook(ctx, domain.Webhook{
+		APIKeyID:     apiKeyID,
+		ChainID:      chainID,
+		Address:      common.HexToAddress(params.Address).Hex(),
+		URL:          params.URL,
+		Secret:       secret,
+		ThresholdWei: params.ThresholdWei,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &domain.CreatedWebhook{Webhook: *created, Secret: secret}, nil
+}
+
+// ListWebhooks lists the webhooks of an API key.
+func (s *service) ListWebhooks(ctx context.Context, apiKeyID *int64) ([]domain.Webhook, error) {
+	return s.repository.ListWebhooks(ctx, apiKeyID)
+}
+
+// GetWebhook retrieves a webhook of an API key by ID.
+// The webhooks of other keys are not found, so their IDs are not given away.
+func (s *service) GetWebhook(ctx context.Context, apiKeyID *int64, id int64) (*domain.Webhook, error) {
+	webhook, err := s.repository.GetWebhook(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if webhook == nil || !sameKey(webhook.APIKeyID, apiKeyID) {
+		return nil, domain.NotFoundError("webhook %d not found", id)
+	}
+
+	return webhook, nil
+}
+
+// DeleteWebhook deletes a webhook of an API key along with its deliveries, pending ones included.
+func (s *service) DeleteWebhook(ctx context.Context, apiKeyID *int64, id int64) error {
+	deleted, err := s.repository.DeleteWebhook(ctx, apiKeyID, id)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return domain.NotFoundError("webhook %d not found", id)
+	}
+
+	return nil
+}
+
+// ListDeliveries lists the most recent deliveries of a webhook, newest first.
+func (s *service) ListDeliveries(ctx context.Context, apiKeyID *int64, webhookID int64) ([]domain.WebhookDelivery, error) {
+	if _, err := s.GetWebhook(ctx, apiKeyID, webhookID); err != nil {
+		return nil, err
+	}
+
+	return s.repository.ListDeliveries(ctx, webhookID, maxListedDeliveries)
+}
+
+// ReplayDelivery queues a delivery again with fresh attempts, whatever its status.
+// The same payload is sent, with the same delivery ID, so receivers can deduplicate it.
+func (s *service) ReplayDelivery(ctx context.Context, apiKeyID *int64, webhookID, id int64) (*domain.WebhookDelivery, error) {
+	if _, err := s.GetWebhook(ctx, apiKeyID, webhookID); err != nil {
+		return nil, err
+	}
+
+	delivery, err := s.repository.ReplayDelivery(ctx, webhookID, id)
+	if err != nil {
+		return nil, err
+	}
+	if delivery == nil {
+		return nil, domain.NotFoundError("delivery %d of webhook %d not found", id, webhookID)
+	}
+
+	return delivery, nil
+}
+
+// sameKey tells whether two optional API key IDs are the same, both nil included.
+func sameKey(a, b *int64) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// validateURL accepts absolute https URLs. Hosts given as an internal IP address are rejected
+// right away, names resolving to one are refused when delivering, see NewClient.
+func validateURL(val string) error {
+	if len(val) > maxURLLength {
+		return domain.InvalidInputError("url must be at most %d characters", maxURLLength)
+	}
+	u, err := url.Parse(val)
+	if err != nil || u.Scheme != "https" || u.Hostname() == "" {
+		return domain.InvalidInputError("url must be an absolute https URL")
+	}
+	if addr, err := netip.ParseAddr(u.Hostname()); err == nil && !isPublicAddr(addr) {
+		return domain.InvalidInputError("url must not point to an internal address")
+	}
+
+	return nil
+}
+
+// generateSecret returns a random signing secret.
+func generateSecret() (string, error) {
+	var b [32]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+
+	return secretPrefix + hex.EncodeToString(b[:]), nil
+}